@@ -0,0 +1,137 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"pr-reviewer-service/internal/roster"
+	"pr-reviewer-service/internal/storage"
+)
+
+// runCommand runs a CLI subcommand instead of the server and returns the
+// exit code
+func runCommand(store storage.Store, args []string) int {
+	switch args[0] {
+	case "import":
+		return runImport(store, args[1:])
+	case "export":
+		return runExport(store, args[1:])
+	default:
+		fmt.Fprintf(os.Stderr, "unknown command %q; available: import, export\n", args[0])
+		return 2
+	}
+}
+
+// runImport: import [-format yaml|json|csv] [-dry-run] FILE ("-" for stdin)
+func runImport(store storage.Store, args []string) int {
+	fs := flag.NewFlagSet("import", flag.ContinueOnError)
+	format := fs.String("format", "", "roster format: yaml, json or csv (default: from the file extension)")
+	dryRun := fs.Bool("dry-run", false, "validate and report changes without applying them")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if fs.NArg() != 1 {
+		fmt.Fprintln(os.Stderr, "usage: service import [-format yaml|json|csv] [-dry-run] FILE")
+		return 2
+	}
+
+	path := fs.Arg(0)
+	var in io.Reader = os.Stdin
+	if path != "-" {
+		f, err := os.Open(path)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		defer f.Close()
+		in = f
+	}
+	if *format == "" {
+		*format = formatFromPath(path)
+	}
+
+	parsed, err := roster.Parse(*format, in)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	result, err := store.ImportRoster(parsed, *dryRun)
+	var invalid *storage.InvalidRosterError
+	if errors.As(err, &invalid) {
+		for _, problem := range invalid.Problems {
+			fmt.Fprintln(os.Stderr, problem)
+		}
+		return 1
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	enc.Encode(result)
+	return 0
+}
+
+// runExport: export [-format yaml|json|csv] [-assignments] [-o FILE]
+func runExport(store storage.Store, args []string) int {
+	fs := flag.NewFlagSet("export", flag.ContinueOnError)
+	format := fs.String("format", "", "output format: yaml, json or csv (default: from -o, else yaml)")
+	assignments := fs.Bool("assignments", false, "include reviewers of open PRs")
+	output := fs.String("o", "", "output file (default: stdout)")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if *format == "" {
+		*format = formatFromPath(*output)
+	}
+
+	snapshot, err := store.ExportRoster(*assignments)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	// Render first so a refused CSV export leaves no partial file behind
+	var buf bytes.Buffer
+	if err := roster.Write(*format, &buf, snapshot); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	var out io.Writer = os.Stdout
+	if *output != "" {
+		f, err := os.Create(*output)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		defer f.Close()
+		out = f
+	}
+	if _, err := out.Write(buf.Bytes()); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	return 0
+}
+
+func formatFromPath(path string) string {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".csv":
+		return roster.FormatCSV
+	case ".json":
+		return roster.FormatJSON
+	default:
+		return roster.FormatYAML
+	}
+}
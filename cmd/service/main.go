@@ -6,6 +6,7 @@ import (
 	"net/http"
 	"os"
 	"time"
+	_ "time/tzdata"
 
 	"pr-reviewer-service/internal/api"
     "pr-reviewer-service/internal/storage"
@@ -28,7 +29,20 @@ func main() {
 	db.SetConnMaxIdleTime(5 * time.Minute)
 
 	store := storage.NewSQLStore(db)
-	handler := api.NewHandler(store)
+
+	// Subcommands such as "import" run against the database and exit
+	if len(os.Args) > 1 {
+		os.Exit(runCommand(store, os.Args[1:]))
+	}
+
+	handler := api.NewHandler(store,
+		api.WithAdminToken(os.Getenv("ADMIN_TOKEN")),
+		api.WithSCIMToken(os.Getenv("SCIM_TOKEN")),
+		api.WithGitHubWebhookSecret(os.Getenv("GITHUB_WEBHOOK_SECRET")),
+		api.WithGitLabWebhookToken(os.Getenv("GITLAB_WEBHOOK_TOKEN")),
+	)
+
+	startWorkers(store)
 
 	r := mux.NewRouter()
 	handler.RegisterRoutes(r)
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"context"
+	"log"
+	"os"
+	"time"
+
+	"pr-reviewer-service/internal/models"
+	"pr-reviewer-service/internal/storage"
+	"pr-reviewer-service/internal/vcs"
+)
+
+// defaultWorkerInterval is how often background workers run unless
+// WORKER_INTERVAL says otherwise
+const defaultWorkerInterval = time.Minute
+
+// runEvery calls fn in the background every interval and logs its errors
+func runEvery(name string, interval time.Duration, fn func() error) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for range ticker.C {
+			if err := fn(); err != nil {
+				log.Printf("%s worker: %v", name, err)
+			}
+		}
+	}()
+}
+
+// durationEnv parses a duration variable such as "24h"; empty means zero
+func durationEnv(name string) time.Duration {
+	value := os.Getenv(name)
+	if value == "" {
+		return 0
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		log.Fatalf("invalid %s: %v", name, err)
+	}
+	return d
+}
+
+func startWorkers(store storage.Store) {
+	interval := durationEnv("WORKER_INTERVAL")
+	if interval <= 0 {
+		interval = defaultWorkerInterval
+	}
+
+	// Assignments nobody acknowledged within ACK_TIMEOUT go to someone else
+	if timeout := durationEnv("ACK_TIMEOUT"); timeout > 0 {
+		runEvery("ack-timeout", interval, func() error {
+			reassignments, err := store.ReassignStaleAssignments(timeout)
+			for _, r := range reassignments {
+				if r.NewReviewerID != "" {
+					log.Printf("ack-timeout: %s reassigned from %s to %s", r.PullRequestID, r.OldReviewerID, r.NewReviewerID)
+				}
+			}
+			return err
+		})
+	}
+
+	// Overdue reviews are recorded as SLA breaches
+	runEvery("sla-breaches", interval, func() error {
+		n, err := store.RecordSLABreaches()
+		if n > 0 {
+			log.Printf("sla-breaches: recorded %d new breaches", n)
+		}
+		return err
+	})
+
+	// Scheduled deactivations, reactivations and team moves
+	runEvery("scheduled-changes", interval, func() error {
+		changes, err := store.ApplyScheduledChanges()
+		for _, c := range changes {
+			log.Printf("scheduled-changes: %s of %s %s %s", c.Kind, c.UserID, c.Status, c.Error)
+		}
+		return err
+	})
+
+	// Assigned reviewers are pushed to GitHub and GitLab
+	syncer := vcs.NewSyncer(store, vcsClients())
+	runEvery("reviewer-sync", interval, func() error {
+		tasks, err := syncer.Run(context.Background())
+		for _, t := range tasks {
+			if t.Status != models.ReviewerSyncSynced {
+				log.Printf("reviewer-sync: %s %s %s", t.PullRequestID, t.Status, t.Error)
+			}
+		}
+		return err
+	})
+
+	// Unanswered assignments are escalated to the team lead
+	runEvery("escalation", interval, func() error {
+		escalations, err := store.EscalateStaleReviews()
+		for _, e := range escalations {
+			if e.Error != "" {
+				continue
+			}
+			log.Printf("escalation: %s stalled on %s, escalated to %q", e.PullRequestID, e.StalledReviewerID, e.EscalatedTo)
+		}
+		return err
+	})
+}
+
+// vcsClients returns a client for every code host with a token configured
+func vcsClients() map[string]vcs.Client {
+	clients := map[string]vcs.Client{}
+	if token := os.Getenv("GITHUB_TOKEN"); token != "" {
+		clients[models.ProviderGitHub] = vcs.NewGitHub(os.Getenv("GITHUB_API_URL"), token)
+	}
+	if token := os.Getenv("GITLAB_TOKEN"); token != "" {
+		clients[models.ProviderGitLab] = vcs.NewGitLab(os.Getenv("GITLAB_URL"), token)
+	}
+	return clients
+}
@@ -0,0 +1,125 @@
+package api
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"pr-reviewer-service/internal/roster"
+	"pr-reviewer-service/internal/storage"
+
+	"github.com/gorilla/mux"
+)
+
+// maxImportSize caps roster uploads
+const maxImportSize = 10 << 20
+
+// importRoster takes a roster in the format given by ?format= (yaml by
+// default) as the raw request body
+func (h *Handler) importRoster(w http.ResponseWriter, r *http.Request) {
+	if !h.isAdmin(r) {
+		respondError(w, "403", "FORBIDDEN", "import requires admin token")
+		return
+	}
+
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = roster.FormatYAML
+	}
+	parsed, err := roster.Parse(format, http.MaxBytesReader(w, r.Body, maxImportSize))
+	if err != nil {
+		respondError(w, "400", "BAD_REQUEST", err.Error())
+		return
+	}
+
+	result, err := h.store.ImportRoster(parsed, r.URL.Query().Get("dry_run") == "true")
+	if err != nil {
+		var invalid *storage.InvalidRosterError
+		if errors.As(err, &invalid) {
+			respondJSON(w, http.StatusBadRequest, map[string]interface{}{
+				"error": map[string]string{
+					"code":    "INVALID_ROSTER",
+					"message": "roster did not pass validation",
+				},
+				"problems": invalid.Problems,
+			})
+			return
+		}
+		respondError(w, "500", "INTERNAL_ERROR", err.Error())
+		return
+	}
+
+	respondJSON(w, 200, map[string]interface{}{"result": result})
+}
+
+// exportContentTypes maps export formats to their Content-Type
+var exportContentTypes = map[string]string{
+	roster.FormatYAML: "application/yaml",
+	roster.FormatJSON: "application/json",
+	roster.FormatCSV:  "text/csv",
+}
+
+func (h *Handler) exportRoster(w http.ResponseWriter, r *http.Request) {
+	if !h.isAdmin(r) {
+		respondError(w, "403", "FORBIDDEN", "export requires admin token")
+		return
+	}
+
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = roster.FormatYAML
+	}
+	contentType, ok := exportContentTypes[format]
+	if !ok {
+		respondError(w, "400", "BAD_REQUEST", "format must be yaml, json or csv")
+		return
+	}
+
+	snapshot, err := h.store.ExportRoster(r.URL.Query().Get("assignments") == "true")
+	if err != nil {
+		respondError(w, "500", "INTERNAL_ERROR", err.Error())
+		return
+	}
+
+	var buf bytes.Buffer
+	if err := roster.Write(format, &buf, snapshot); errors.Is(err, roster.ErrCSVExport) {
+		respondError(w, "400", "BAD_REQUEST", err.Error())
+		return
+	} else if err != nil {
+		respondError(w, "500", "INTERNAL_ERROR", err.Error())
+		return
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.Write(buf.Bytes())
+}
+
+// revertOperation undoes a mass deactivation or reassignment by the
+// operation ID it returned
+func (h *Handler) revertOperation(w http.ResponseWriter, r *http.Request) {
+	if !h.isAdmin(r) {
+		respondError(w, "403", "FORBIDDEN", "revert requires admin token")
+		return
+	}
+
+	id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		respondError(w, "400", "BAD_REQUEST", "operation id must be a number")
+		return
+	}
+
+	result, err := h.store.RevertOperation(id)
+	if err != nil {
+		switch err {
+		case storage.ErrNotFound:
+			respondError(w, "404", "NOT_FOUND", "operation not found")
+		case storage.ErrReverted:
+			respondError(w, "409", "ALREADY_REVERTED", "operation was already reverted")
+		default:
+			respondError(w, "500", "INTERNAL_ERROR", err.Error())
+		}
+		return
+	}
+
+	respondJSON(w, 200, result)
+}
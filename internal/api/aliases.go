@@ -0,0 +1,77 @@
+package api
+
+import (
+	"net/http"
+
+	"pr-reviewer-service/internal/models"
+	"pr-reviewer-service/internal/storage"
+)
+
+func validProvider(provider string) bool {
+	switch provider {
+	case models.ProviderGitHub, models.ProviderGitLab, models.ProviderEmail:
+		return true
+	}
+	return false
+}
+
+func (h *Handler) addUserAlias(w http.ResponseWriter, r *http.Request) {
+	var in models.UserAlias
+	if err := decode(r, &in); err != nil || in.UserID == "" || in.ExternalID == "" {
+		respondError(w, "400", "BAD_REQUEST", "Invalid request body")
+		return
+	}
+	if !validProvider(in.Provider) {
+		respondError(w, "400", "BAD_REQUEST", "provider must be github, gitlab or email")
+		return
+	}
+
+	alias, err := h.store.AddUserAlias(in)
+	if err != nil {
+		switch err {
+		case storage.ErrNotFound:
+			respondError(w, "404", "NOT_FOUND", "user not found")
+		case storage.ErrAliasExists:
+			respondError(w, "409", "ALIAS_EXISTS", "identity is already an alias")
+		default:
+			respondError(w, "500", "INTERNAL_ERROR", err.Error())
+		}
+		return
+	}
+
+	respondJSON(w, 201, map[string]interface{}{"alias": alias})
+}
+
+func (h *Handler) listUserAliases(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	aliases, err := h.store.ListUserAliases(q.Get("user_id"), q.Get("provider"))
+	if err != nil {
+		respondError(w, "500", "INTERNAL_ERROR", "Failed to get aliases")
+		return
+	}
+
+	respondJSON(w, 200, map[string]interface{}{"aliases": aliases})
+}
+
+func (h *Handler) deleteUserAlias(w http.ResponseWriter, r *http.Request) {
+	var in struct {
+		Provider   string `json:"provider"`
+		ExternalID string `json:"external_id"`
+	}
+	if err := decode(r, &in); err != nil || in.Provider == "" || in.ExternalID == "" {
+		respondError(w, "400", "BAD_REQUEST", "Invalid request body")
+		return
+	}
+
+	alias, err := h.store.DeleteUserAlias(in.Provider, in.ExternalID)
+	if err != nil {
+		if err == storage.ErrNotFound {
+			respondError(w, "404", "NOT_FOUND", "alias not found")
+		} else {
+			respondError(w, "500", "INTERNAL_ERROR", err.Error())
+		}
+		return
+	}
+
+	respondJSON(w, 200, map[string]interface{}{"alias": alias})
+}
@@ -3,12 +3,18 @@ package api
 import (
 	"bytes"
 	"encoding/json"
+	"fmt"
+	"io"
 	"net/http"
 	"net/http/httptest"
+	"sort"
+	"strings"
 	"testing"
 	"time"
 
 	"pr-reviewer-service/internal/models"
+	"pr-reviewer-service/internal/policy"
+	"pr-reviewer-service/internal/roster"
 	"pr-reviewer-service/internal/storage"
 
 	"github.com/gorilla/mux"
@@ -16,16 +22,36 @@ import (
 
 // MockStore for testing
 type MockStore struct {
-	teams map[string]models.Team
-	users map[string]models.User
-	prs   map[string]models.PullRequest
+	teams    map[string]models.Team
+	users    map[string]models.User
+	prs      map[string]models.PullRequest
+	policies map[string]models.MergePolicy
+	freezes  []models.MergeFreeze
+	repos    map[string]models.Repository
+	declines []string
+
+	// operations[id-1] is the change set of operation id
+	operations []mockOperation
+	changes    []models.ScheduledChange
+	aliases    []models.UserAlias
+	deliveries map[string]bool
+}
+
+type mockOperation struct {
+	deactivated []string
+	swaps       []models.Reassignment
+	reverted    bool
 }
 
 func NewMockStore() *MockStore {
 	return &MockStore{
-		teams: make(map[string]models.Team),
-		users: make(map[string]models.User),
-		prs:   make(map[string]models.PullRequest),
+		teams:    make(map[string]models.Team),
+		users:    make(map[string]models.User),
+		prs:      make(map[string]models.PullRequest),
+		policies: make(map[string]models.MergePolicy),
+		repos:    make(map[string]models.Repository),
+
+		deliveries: make(map[string]bool),
 	}
 }
 
@@ -45,6 +71,15 @@ func (m *MockStore) GetTeam(name string) (models.Team, error) {
 	if !exists {
 		return models.Team{}, storage.ErrNotFound
 	}
+	team.Children, team.Path = []string{}, []string{}
+	for _, other := range m.teams {
+		if other.ParentTeam == name {
+			team.Children = append(team.Children, other.Name)
+		}
+	}
+	for parent := team.ParentTeam; parent != ""; parent = m.teams[parent].ParentTeam {
+		team.Path = append([]string{parent}, team.Path...)
+	}
 	return team, nil
 }
 
@@ -58,38 +93,100 @@ func (m *MockStore) SetUserActive(userID string, active bool) (models.User, erro
 	return user, nil
 }
 
-func (m *MockStore) CreatePR(pr models.PullRequest) error {
+func (m *MockStore) CreatePR(pr models.PullRequest) (models.PullRequest, error) {
+	authorID, err := m.ResolveUser("", pr.AuthorID)
+	if err != nil {
+		return models.PullRequest{}, err
+	}
+	pr.AuthorID = authorID
+	limit := 2
+	reviewerTeam := m.findUserTeam(pr.AuthorID)
+	if pr.Repository != "" {
+		repo, exists := m.repos[pr.Repository]
+		if !exists {
+			return models.PullRequest{}, storage.ErrNotFound
+		}
+		limit = repo.ReviewerCount
+		if repo.OwningTeam != "" {
+			reviewerTeam = m.teams[repo.OwningTeam]
+		}
+		pr.Alias = pr.ID
+		pr.ID = fmt.Sprintf("%s#%d", pr.Repository, pr.Number)
+	}
+	if author := m.users[pr.AuthorID]; author.IsBot && author.BotTeam != "" {
+		reviewerTeam = m.teams[author.BotTeam]
+	}
+	if reviewerTeam.Name == "" {
+		return models.PullRequest{}, storage.ErrNoTeam
+	}
 	if _, exists := m.prs[pr.ID]; exists {
-		return storage.ErrPRExists
+		return models.PullRequest{}, storage.ErrPRExists
 	}
 	
 	// Simple auto-assignment logic for testing
-	authorTeam := m.findUserTeam(pr.AuthorID)
+	limit = policy.ReviewerCount(limit, reviewerTeam.SizeRules, pr.LinesAdded+pr.LinesRemoved)
 	var reviewers []models.User
-	for _, member := range authorTeam.Members {
-		if member.UserID != pr.AuthorID && member.IsActive && len(reviewers) < 2 {
+	picked := map[string]bool{pr.AuthorID: true}
+	if parent, exists := m.prs[pr.DependsOn]; exists {
+		for _, reviewer := range parent.Reviewers {
+			if !picked[reviewer.UserID] && len(reviewers) < limit {
+				reviewers = append(reviewers, reviewer)
+				picked[reviewer.UserID] = true
+			}
+		}
+	} else if pr.DependsOn != "" {
+		return models.PullRequest{}, storage.ErrNotFound
+	}
+	for _, member := range reviewerTeam.Members {
+		if !picked[member.UserID] && member.IsActive && !m.users[member.UserID].IsBot && member.Role != models.RoleObserver && len(reviewers) < limit {
 			reviewers = append(reviewers, member)
 		}
 	}
 	
 	pr.Reviewers = reviewers
+	if m.repos[pr.Repository].Provider != "" {
+		pr.ReviewerSync = models.ReviewerSyncPending
+	}
 	m.prs[pr.ID] = pr
-	return nil
+	return pr, nil
 }
 
 func (m *MockStore) GetPR(id string) (models.PullRequest, error) {
 	pr, exists := m.prs[id]
 	if !exists {
+		for _, candidate := range m.prs {
+			if candidate.Alias == id {
+				return candidate, nil
+			}
+		}
 		return models.PullRequest{}, storage.ErrNotFound
 	}
 	return pr, nil
 }
 
-func (m *MockStore) MergePR(id string) (models.PullRequest, error) {
+func (m *MockStore) MergePR(id string, force bool) (models.PullRequest, error) {
 	pr, exists := m.prs[id]
 	if !exists {
 		return models.PullRequest{}, storage.ErrNotFound
 	}
+	if pr.Status == models.MERGED {
+		return pr, nil
+	}
+	if pr.Status == models.CLOSED {
+		return models.PullRequest{}, storage.ErrPRClosed
+	}
+	if parent, exists := m.prs[pr.DependsOn]; exists && parent.Status == models.OPEN {
+		return models.PullRequest{}, storage.ErrParentOpen
+	}
+	violations := policy.Evaluate(m.policies[m.findUserTeam(pr.AuthorID).Name], policy.Input{
+		CreatedAt: pr.CreatedAt,
+		Reviews:   pr.Reviews,
+		Freezes:   m.freezes,
+		Now:       time.Now(),
+	})
+	if len(violations) > 0 && !force {
+		return models.PullRequest{}, &storage.PolicyViolationError{Violations: violations}
+	}
 	pr.Status = models.MERGED
 	now := time.Now()
 	pr.MergedAt = &now
@@ -97,30 +194,95 @@ func (m *MockStore) MergePR(id string) (models.PullRequest, error) {
 	return pr, nil
 }
 
-func (m *MockStore) ReassignReviewer(prID, oldReviewerID string) (models.PullRequest, string, error) {
+func (m *MockStore) ClosePR(id, provider string) (models.PullRequest, error) {
+	return m.setPRClosed(id, true)
+}
+
+func (m *MockStore) RecordExternalMerge(id, provider string) (models.PullRequest, error) {
+	pr, exists := m.prs[id]
+	if !exists {
+		return models.PullRequest{}, storage.ErrNotFound
+	}
+	if pr.Status != models.MERGED {
+		now := time.Now()
+		pr.Status, pr.MergedAt, pr.ClosedAt = models.MERGED, &now, nil
+		m.prs[id] = pr
+	}
+	return pr, nil
+}
+
+func (m *MockStore) ReopenPR(id, provider string) (models.PullRequest, error) {
+	return m.setPRClosed(id, false)
+}
+
+func (m *MockStore) setPRClosed(id string, closed bool) (models.PullRequest, error) {
+	pr, exists := m.prs[id]
+	if !exists {
+		return models.PullRequest{}, storage.ErrNotFound
+	}
+	switch {
+	case pr.Status == models.MERGED:
+		return models.PullRequest{}, storage.ErrPRMerged
+	case closed && pr.Status == models.OPEN:
+		now := time.Now()
+		pr.Status, pr.ClosedAt = models.CLOSED, &now
+	case !closed && pr.Status == models.CLOSED:
+		pr.Status, pr.ClosedAt = models.OPEN, nil
+	}
+	m.prs[id] = pr
+	return pr, nil
+}
+
+func (m *MockStore) SubmitReview(prID, userID string, state models.ReviewState) (models.PullRequest, error) {
+	pr, exists := m.prs[prID]
+	if !exists {
+		return models.PullRequest{}, storage.ErrNotFound
+	}
+	if pr.Status == models.MERGED {
+		return models.PullRequest{}, storage.ErrPRMerged
+	}
+	for _, reviewer := range pr.Reviewers {
+		if reviewer.UserID == userID {
+			now := time.Now()
+			pr.Reviews = append(pr.Reviews, models.Review{UserID: userID, State: state, ReviewedAt: &now})
+			m.prs[prID] = pr
+			return pr, nil
+		}
+	}
+	return models.PullRequest{}, storage.ErrNotAssigned
+}
+
+func (m *MockStore) ReassignReviewer(prID, oldReviewerID string) (models.PullRequest, string, int64, error) {
 	pr, exists := m.prs[prID]
 	if !exists {
-		return models.PullRequest{}, "", storage.ErrNotFound
+		return models.PullRequest{}, "", 0, storage.ErrNotFound
 	}
 	
 	if pr.Status == models.MERGED {
-		return models.PullRequest{}, "", storage.ErrPRMerged
+		return models.PullRequest{}, "", 0, storage.ErrPRMerged
+	}
+	if pr.Status == models.CLOSED {
+		return models.PullRequest{}, "", 0, storage.ErrPRClosed
 	}
 	
 	for i, reviewer := range pr.Reviewers {
 		if reviewer.UserID == oldReviewerID {
 			team := m.findUserTeam(oldReviewerID)
 			for _, member := range team.Members {
-				if member.UserID != oldReviewerID && member.IsActive {
+				if member.UserID != oldReviewerID && member.IsActive && !m.users[member.UserID].IsBot && member.Role != models.RoleObserver {
 					pr.Reviewers[i] = member
-					return pr, member.UserID, nil
+					if m.repos[pr.Repository].Provider != "" {
+						pr.ReviewerSync = models.ReviewerSyncPending
+					}
+					swap := models.Reassignment{PullRequestID: prID, OldReviewerID: oldReviewerID, NewReviewerID: member.UserID}
+					return pr, member.UserID, m.recordOperation(nil, []models.Reassignment{swap}), nil
 				}
 			}
-			return models.PullRequest{}, "", storage.ErrNoCandidate
+			return models.PullRequest{}, "", 0, storage.ErrNoCandidate
 		}
 	}
 	
-	return models.PullRequest{}, "", storage.ErrNotAssigned
+	return models.PullRequest{}, "", 0, storage.ErrNotAssigned
 }
 
 func (m *MockStore) ListPRsAssignedTo(userID string) ([]models.PullRequest, error) {
@@ -133,9 +295,35 @@ func (m *MockStore) ListPRsAssignedTo(userID string) ([]models.PullRequest, erro
 			}
 		}
 	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Priority != result[j].Priority {
+			return priorityRank[result[i].Priority] > priorityRank[result[j].Priority]
+		}
+		if result[i].CreatedAt == nil || result[j].CreatedAt == nil {
+			return result[i].ID < result[j].ID
+		}
+		return result[i].CreatedAt.Before(*result[j].CreatedAt)
+	})
 	return result, nil
 }
 
+var priorityRank = map[models.PRPriority]int{
+	models.PriorityLow:      0,
+	models.PriorityMedium:   1,
+	models.PriorityHigh:     2,
+	models.PriorityCritical: 3,
+}
+
+func (m *MockStore) SetTeamSizeRules(teamName string, rules []models.SizeRule) ([]models.SizeRule, error) {
+	team, exists := m.teams[teamName]
+	if !exists {
+		return nil, storage.ErrNotFound
+	}
+	team.SizeRules = rules
+	m.teams[teamName] = team
+	return rules, nil
+}
+
 func (m *MockStore) GetStats() (map[string]interface{}, error) {
 	stats := map[string]interface{}{
 		"total_teams": len(m.teams),
@@ -146,151 +334,2094 @@ func (m *MockStore) GetStats() (map[string]interface{}, error) {
 			{"user_id": "u2", "username": "Bob", "assignment_count": 1},
 		},
 	}
+	bots := []map[string]interface{}{}
+	for _, user := range m.users {
+		if !user.IsBot {
+			continue
+		}
+		count := 0
+		for _, pr := range m.prs {
+			if pr.AuthorID == user.UserID {
+				count++
+			}
+		}
+		bots = append(bots, map[string]interface{}{"user_id": user.UserID, "bot_team": user.BotTeam, "pr_count": count})
+	}
+	stats["bot_statistics"] = bots
 	return stats, nil
 }
 
-func (m *MockStore) MassDeactivate(teamName string, excludeUsers []string) (map[string]interface{}, error) {
+func (m *MockStore) MassDeactivate(teamName string, excludeUsers []string, dryRun, abortIfUnresolved bool) (map[string]interface{}, error) {
 	team, exists := m.teams[teamName]
 	if !exists {
 		return nil, storage.ErrNotFound
 	}
-	
-	deactivated := 0
-	for i, member := range team.Members {
-		shouldExclude := false
-		for _, exclude := range excludeUsers {
-			if member.UserID == exclude {
-				shouldExclude = true
-				break
+
+	deactivated := map[string]bool{}
+	for _, member := range team.Members {
+		if !contains(excludeUsers, member.UserID) && m.users[member.UserID].IsActive {
+			deactivated[member.UserID] = true
+		}
+	}
+
+	// Plan the hand-offs first so that a dry run or an abort changes nothing
+	type change struct {
+		prID  string
+		index int
+		to    models.User
+	}
+	var changes []change
+	reassignments := []models.Reassignment{}
+	reassignedPRs := []string{}
+	var ids []string
+	for id := range m.prs {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	for _, id := range ids {
+		pr := m.prs[id]
+		if pr.Status != models.OPEN {
+			continue
+		}
+		taken := map[string]bool{pr.AuthorID: true}
+		for _, reviewer := range pr.Reviewers {
+			taken[reviewer.UserID] = true
+		}
+		for i, reviewer := range pr.Reviewers {
+			if !deactivated[reviewer.UserID] {
+				continue
 			}
+			r := models.Reassignment{PullRequestID: id, OldReviewerID: reviewer.UserID, Reason: storage.ErrNoCandidate.Error()}
+			for _, member := range team.Members {
+				if !taken[member.UserID] && !deactivated[member.UserID] && member.IsActive && !m.users[member.UserID].IsBot && member.Role != models.RoleObserver {
+					taken[member.UserID] = true
+					changes = append(changes, change{id, i, member})
+					r.NewReviewerID, r.Reason = member.UserID, ""
+					reassignedPRs = append(reassignedPRs, id)
+					break
+				}
+			}
+			reassignments = append(reassignments, r)
 		}
-		
-		if !shouldExclude {
+	}
+
+	unresolved := len(reassignments) - len(reassignedPRs)
+	if abortIfUnresolved && unresolved > 0 {
+		return nil, &storage.UnresolvedReviewsError{Reassignments: reassignments}
+	}
+	result := map[string]interface{}{
+		"team_name":         teamName,
+		"dry_run":           dryRun,
+		"deactivated_users": len(deactivated),
+		"reassigned_prs":    reassignedPRs,
+		"reassigned_count":  len(reassignedPRs),
+		"unresolved_count":  unresolved,
+		"reassignments":     reassignments,
+	}
+	if dryRun {
+		return result, nil
+	}
+
+	var users []string
+	for i, member := range team.Members {
+		if deactivated[member.UserID] {
 			member.IsActive = false
 			team.Members[i] = member
 			m.users[member.UserID] = member
-			deactivated++
+			users = append(users, member.UserID)
 		}
 	}
-	
-	m.teams[teamName] = team
-	
-	return map[string]interface{}{
-		"deactivated_users": deactivated,
-		"team_name": teamName,
-		"reassigned_prs": []string{},
-		"reassigned_count": 0,
-	}, nil
+	for _, c := range changes {
+		m.prs[c.prID].Reviewers[c.index] = c.to
+	}
+	result["operation_id"] = m.recordOperation(users, reassignments)
+	return result, nil
 }
 
-func (m *MockStore) findUserTeam(userID string) models.Team {
-	for _, team := range m.teams {
-		for _, member := range team.Members {
-			if member.UserID == userID {
-				return team
-			}
-		}
+func (m *MockStore) SetMergePolicy(p models.MergePolicy) (models.MergePolicy, error) {
+	if _, exists := m.teams[p.TeamName]; !exists {
+		return models.MergePolicy{}, storage.ErrNotFound
 	}
-	return models.Team{}
+	m.policies[p.TeamName] = p
+	return p, nil
 }
 
-// Tests
-func TestCreateTeam(t *testing.T) {
-	store := NewMockStore()
-	handler := NewHandler(store)
-	router := mux.NewRouter()
-	handler.RegisterRoutes(router)
+func (m *MockStore) GetMergePolicy(teamName string) (models.MergePolicy, error) {
+	p, exists := m.policies[teamName]
+	if !exists {
+		return models.MergePolicy{}, storage.ErrNotFound
+	}
+	return p, nil
+}
 
-	teamData := map[string]interface{}{
-		"team_name": "backend",
-		"members": []map[string]interface{}{
-			{"user_id": "u1", "username": "Alice", "is_active": true},
-		},
+func (m *MockStore) AddMergeFreeze(f models.MergeFreeze) (models.MergeFreeze, error) {
+	f.ID = int64(len(m.freezes) + 1)
+	m.freezes = append(m.freezes, f)
+	return f, nil
+}
+
+func (m *MockStore) ListMergeFreezes(teamName string) ([]models.MergeFreeze, error) {
+	return m.freezes, nil
+}
+
+func (m *MockStore) CreateRepository(repo models.Repository) (models.Repository, error) {
+	if _, exists := m.repos[repo.Name]; exists {
+		return models.Repository{}, storage.ErrRepoExists
 	}
-	
-	body, _ := json.Marshal(teamData)
-	req := httptest.NewRequest("POST", "/team/add", bytes.NewReader(body))
-	req.Header.Set("Content-Type", "application/json")
-	
-	rr := httptest.NewRecorder()
-	router.ServeHTTP(rr, req)
-	
-	if rr.Code != http.StatusCreated {
-		t.Errorf("Expected status 201, got %d", rr.Code)
+	m.repos[repo.Name] = repo
+	return repo, nil
+}
+
+func (m *MockStore) GetRepository(name string) (models.Repository, error) {
+	repo, exists := m.repos[name]
+	if !exists {
+		return models.Repository{}, storage.ErrNotFound
 	}
+	return repo, nil
 }
 
-func TestCreatePR(t *testing.T) {
-	store := NewMockStore()
-	
-	// Create team first
-	store.CreateTeam("backend", []models.User{
-		{UserID: "u1", Username: "Alice", IsActive: true},
-		{UserID: "u2", Username: "Bob", IsActive: true},
-	})
-	
-	handler := NewHandler(store)
-	router := mux.NewRouter()
-	handler.RegisterRoutes(router)
+func (m *MockStore) UpdateRepository(repo models.Repository) (models.Repository, error) {
+	if _, exists := m.repos[repo.Name]; !exists {
+		return models.Repository{}, storage.ErrNotFound
+	}
+	m.repos[repo.Name] = repo
+	return repo, nil
+}
 
-	prData := map[string]interface{}{
-		"pull_request_id":   "pr-1",
-		"pull_request_name": "Test PR",
-		"author_id":         "u1",
+func (m *MockStore) ListRepositories() ([]models.Repository, error) {
+	var repos []models.Repository
+	for _, repo := range m.repos {
+		repos = append(repos, repo)
 	}
-	
-	body, _ := json.Marshal(prData)
-	req := httptest.NewRequest("POST", "/pullRequest/create", bytes.NewReader(body))
-	req.Header.Set("Content-Type", "application/json")
-	
-	rr := httptest.NewRecorder()
-	router.ServeHTTP(rr, req)
-	
-	if rr.Code != http.StatusCreated {
-		t.Errorf("Expected status 201, got %d", rr.Code)
+	return repos, nil
+}
+
+func (m *MockStore) LinkPR(prID, parentID string) (models.PullRequest, error) {
+	pr, exists := m.prs[prID]
+	if !exists {
+		return models.PullRequest{}, storage.ErrNotFound
+	}
+	for id := parentID; id != ""; id = m.prs[id].DependsOn {
+		if _, exists := m.prs[id]; !exists {
+			return models.PullRequest{}, storage.ErrNotFound
+		}
+		if id == prID {
+			return models.PullRequest{}, storage.ErrDepCycle
+		}
 	}
+	pr.DependsOn = parentID
+	m.prs[prID] = pr
+	return pr, nil
 }
 
-func TestGetStats(t *testing.T) {
-	store := NewMockStore()
-	handler := NewHandler(store)
-	router := mux.NewRouter()
-	handler.RegisterRoutes(router)
+func (m *MockStore) RespondToAssignment(prID, userID string, accept bool, reason string) (models.PullRequest, string, error) {
+	if accept {
+		pr, err := m.GetPR(prID)
+		if err == nil && pr.Status == models.CLOSED {
+			return models.PullRequest{}, "", storage.ErrPRClosed
+		}
+		return pr, "", err
+	}
+	pr, newReviewerID, _, err := m.ReassignReviewer(prID, userID)
+	if err != nil {
+		return models.PullRequest{}, "", err
+	}
+	m.declines = append(m.declines, prID+"/"+userID)
+	return pr, newReviewerID, nil
+}
 
-	req := httptest.NewRequest("GET", "/stats/assignments", nil)
-	rr := httptest.NewRecorder()
-	router.ServeHTTP(rr, req)
-	
-	if rr.Code != http.StatusOK {
-		t.Errorf("Expected status 200, got %d", rr.Code)
+func (m *MockStore) ReassignStaleAssignments(timeout time.Duration) ([]models.Reassignment, error) {
+	return []models.Reassignment{}, nil
+}
+
+func (m *MockStore) SetTeamSLA(teamName string, hours int, holidays []string) (models.Team, error) {
+	team, exists := m.teams[teamName]
+	if !exists {
+		return models.Team{}, storage.ErrNotFound
 	}
+	team.ReviewSLAHours = hours
+	team.Holidays = holidays
+	m.teams[teamName] = team
+	return team, nil
 }
 
-func TestMassDeactivate(t *testing.T) {
-	store := NewMockStore()
-	
-	// Create team first
-	store.CreateTeam("backend", []models.User{
-		{UserID: "u1", Username: "Alice", IsActive: true},
-		{UserID: "u2", Username: "Bob", IsActive: true},
-	})
-	
-	handler := NewHandler(store)
-	router := mux.NewRouter()
-	handler.RegisterRoutes(router)
+func (m *MockStore) ListSLABreaches(teamName, userID string) ([]models.SLABreach, error) {
+	return []models.SLABreach{}, nil
+}
 
-	deactivateData := map[string]interface{}{
-		"exclude_users": []string{"u1"},
+func (m *MockStore) RecordSLABreaches() (int, error) {
+	return 0, nil
+}
+
+func (m *MockStore) SetTeamEscalation(teamName string, minutes int, userID string) (models.Team, error) {
+	team, exists := m.teams[teamName]
+	if !exists {
+		return models.Team{}, storage.ErrNotFound
 	}
-	
-	body, _ := json.Marshal(deactivateData)
-	req := httptest.NewRequest("POST", "/team/backend/deactivate", bytes.NewReader(body))
-	req.Header.Set("Content-Type", "application/json")
-	
-	rr := httptest.NewRecorder()
-	router.ServeHTTP(rr, req)
-	
-	if rr.Code != http.StatusOK {
-		t.Errorf("Expected status 200, got %d", rr.Code)
+	if _, exists := m.users[userID]; userID != "" && !exists {
+		return models.Team{}, storage.ErrNotFound
+	}
+	team.EscalationMinutes = minutes
+	team.EscalationUserID = userID
+	m.teams[teamName] = team
+	return team, nil
+}
+
+func (m *MockStore) EscalateStaleReviews() ([]models.Escalation, error) {
+	return []models.Escalation{}, nil
+}
+
+func (m *MockStore) ListEscalations(teamName string) ([]models.Escalation, error) {
+	return []models.Escalation{}, nil
+}
+
+func (m *MockStore) AddTeamMember(teamName string, member models.User) (models.Team, error) {
+	team, exists := m.teams[teamName]
+	if !exists {
+		return models.Team{}, storage.ErrNotFound
+	}
+	for _, existing := range team.Members {
+		if existing.UserID == member.UserID {
+			return models.Team{}, storage.ErrMemberExists
+		}
+	}
+	if user, exists := m.users[member.UserID]; exists {
+		user.Role = member.Role
+		member = user
+	}
+	team.Members = append(team.Members, member)
+	m.teams[teamName] = team
+	m.users[member.UserID] = member
+	return team, nil
+}
+
+func (m *MockStore) RemoveTeamMember(teamName, userID string) ([]models.Reassignment, error) {
+	team := m.teams[teamName]
+	var remaining []models.User
+	for _, member := range team.Members {
+		if member.UserID != userID {
+			remaining = append(remaining, member)
+		}
+	}
+	if len(remaining) == len(team.Members) {
+		return nil, storage.ErrNotFound
 	}
-}
\ No newline at end of file
+
+	reassignments := []models.Reassignment{}
+	for id, pr := range m.prs {
+		if pr.Status != models.OPEN || m.findUserTeam(pr.AuthorID).Name != teamName {
+			continue
+		}
+		for i, reviewer := range pr.Reviewers {
+			if reviewer.UserID != userID {
+				continue
+			}
+			r := models.Reassignment{PullRequestID: id, OldReviewerID: userID, Reason: storage.ErrNoCandidate.Error()}
+			for _, member := range remaining {
+				if member.UserID != pr.AuthorID && member.IsActive && !m.users[member.UserID].IsBot && member.Role != models.RoleObserver && !hasReviewer(pr, member.UserID) {
+					pr.Reviewers[i] = member
+					r.NewReviewerID, r.Reason = member.UserID, ""
+					break
+				}
+			}
+			reassignments = append(reassignments, r)
+		}
+	}
+
+	team.Members = remaining
+	m.teams[teamName] = team
+	return reassignments, nil
+}
+
+// UpdateTeam checks what can fail up front and then applies the changes,
+// which leaves the team untouched like the storage transaction does
+func (m *MockStore) UpdateTeam(name string, changes []models.TeamChange) (models.Team, error) {
+	if _, exists := m.teams[name]; !exists {
+		return models.Team{}, storage.ErrNotFound
+	}
+	final := name
+	for _, c := range changes {
+		if c.Op == models.TeamRename && c.NewName != "" && c.NewName != final {
+			if _, exists := m.teams[c.NewName]; exists {
+				return models.Team{}, storage.ErrTeamExists
+			}
+			final = c.NewName
+		}
+	}
+
+	for _, c := range changes {
+		var err error
+		switch c.Op {
+		case models.TeamAddMembers:
+			for _, spec := range c.Members {
+				if _, err := m.AddTeamMember(name, m.users[spec.UserID]); err != nil && err != storage.ErrMemberExists {
+					return models.Team{}, err
+				}
+			}
+		case models.TeamRemoveMembers:
+			for _, spec := range c.Members {
+				if _, err := m.RemoveTeamMember(name, spec.UserID); err != nil && err != storage.ErrNotFound {
+					return models.Team{}, err
+				}
+			}
+		case models.TeamReplaceMembers:
+			_, err = m.ReconcileTeam(name, c.Members, false)
+		case models.TeamRename:
+			if c.NewName != "" && c.NewName != name {
+				_, err = m.RenameTeam(name, c.NewName)
+				name = c.NewName
+			}
+		}
+		if err != nil {
+			return models.Team{}, err
+		}
+	}
+	return m.GetTeam(name)
+}
+
+func (m *MockStore) RenameTeam(oldName, newName string) (models.Team, error) {
+	team, exists := m.teams[oldName]
+	if !exists {
+		return models.Team{}, storage.ErrNotFound
+	}
+	if _, exists := m.teams[newName]; exists {
+		return models.Team{}, storage.ErrTeamExists
+	}
+	delete(m.teams, oldName)
+	team.Name = newName
+	m.teams[newName] = team
+	return team, nil
+}
+
+func (m *MockStore) DeleteTeam(name, moveTo string) (map[string]interface{}, error) {
+	team, exists := m.teams[name]
+	if !exists {
+		return nil, storage.ErrNotFound
+	}
+	target, exists := m.teams[moveTo]
+	if moveTo != "" && !exists {
+		return nil, storage.ErrNotFound
+	}
+
+	members := []string{}
+	busy := []string{}
+	for _, member := range team.Members {
+		members = append(members, member.UserID)
+		for id, pr := range m.prs {
+			if pr.Status == models.OPEN && hasReviewer(pr, member.UserID) {
+				busy = append(busy, id)
+			}
+		}
+	}
+	if moveTo == "" && len(busy) > 0 {
+		return nil, &storage.TeamBusyError{PullRequestIDs: busy}
+	}
+	var requiring []models.MergePolicy
+	for _, p := range m.policies {
+		if p.RequiredReviewerTeam == name {
+			requiring = append(requiring, p)
+		}
+	}
+	if moveTo == "" && len(requiring) > 0 {
+		return nil, &storage.TeamRequiredError{Policies: requiring}
+	}
+	if moveTo != "" {
+		target.Members = append(target.Members, team.Members...)
+		m.teams[moveTo] = target
+		for _, p := range requiring {
+			p.RequiredReviewerTeam = moveTo
+			m.policies[p.TeamName] = p
+		}
+	}
+
+	delete(m.teams, name)
+	return map[string]interface{}{"team_name": name, "moved_to": moveTo, "members": members}, nil
+}
+
+func (m *MockStore) ReconcileTeam(name string, specs []models.MemberSpec, dryRun bool) (models.TeamDiff, error) {
+	diff := models.TeamDiff{TeamName: name, DryRun: dryRun, Added: []string{}, Removed: []string{}}
+	team, exists := m.teams[name]
+	diff.Created = !exists
+
+	existing := map[string]models.User{}
+	for _, member := range team.Members {
+		existing[member.UserID] = member
+	}
+	desired := map[string]bool{}
+	members := make([]models.User, 0, len(specs))
+	for _, spec := range specs {
+		desired[spec.UserID] = true
+		member := models.User{UserID: spec.UserID, Username: spec.Username, IsActive: spec.IsActive == nil || *spec.IsActive, Role: spec.Role}
+		old, ok := existing[spec.UserID]
+		if ok && spec.Username == "" {
+			member.Username = old.Username
+		}
+		if ok && spec.IsActive == nil {
+			member.IsActive = old.IsActive
+		}
+		if !ok {
+			diff.Added = append(diff.Added, member.UserID)
+		} else if old.Username != member.Username || old.IsActive != member.IsActive {
+			diff.Updated = append(diff.Updated, models.MemberChange{UserID: member.UserID})
+		}
+		members = append(members, member)
+	}
+	for _, member := range team.Members {
+		if !desired[member.UserID] {
+			diff.Removed = append(diff.Removed, member.UserID)
+		}
+	}
+
+	if !dryRun {
+		for _, member := range members {
+			if old, ok := existing[member.UserID]; ok && old.IsActive && !member.IsActive {
+				reassignments, _ := m.DeactivateUser(member.UserID, nil)
+				diff.Reassignments = append(diff.Reassignments, reassignments...)
+			}
+		}
+		m.teams[name] = models.Team{Name: name, Members: members}
+		for _, member := range members {
+			user := m.users[member.UserID]
+			user.UserID, user.Username, user.IsActive, user.Role = member.UserID, member.Username, member.IsActive, member.Role
+			m.users[member.UserID] = user
+		}
+	}
+	return diff, nil
+}
+
+func (m *MockStore) SetTeamParent(teamName, parentTeam string) (models.Team, error) {
+	team, exists := m.teams[teamName]
+	if !exists {
+		return models.Team{}, storage.ErrNotFound
+	}
+	for id := parentTeam; id != ""; id = m.teams[id].ParentTeam {
+		if _, exists := m.teams[id]; !exists {
+			return models.Team{}, storage.ErrNotFound
+		}
+		if id == teamName {
+			return models.Team{}, storage.ErrTeamCycle
+		}
+	}
+	team.ParentTeam = parentTeam
+	m.teams[teamName] = team
+	return m.GetTeam(teamName)
+}
+
+func (m *MockStore) AddReviewer(prID, userID string) (models.PullRequest, error) {
+	pr, exists := m.prs[prID]
+	user, userExists := m.users[userID]
+	if !exists || !userExists || !user.IsActive {
+		return models.PullRequest{}, storage.ErrNotFound
+	}
+	if pr.Status == models.MERGED {
+		return models.PullRequest{}, storage.ErrPRMerged
+	}
+	if pr.Status == models.CLOSED {
+		return models.PullRequest{}, storage.ErrPRClosed
+	}
+	if pr.AuthorID == userID {
+		return models.PullRequest{}, storage.ErrAuthor
+	}
+	if user.IsBot {
+		return models.PullRequest{}, storage.ErrBot
+	}
+	if hasReviewer(pr, userID) {
+		return models.PullRequest{}, storage.ErrAssigned
+	}
+	pr.Reviewers = append(pr.Reviewers, user)
+	m.prs[prID] = pr
+	return pr, nil
+}
+
+func (m *MockStore) ImportRoster(r roster.Roster, dryRun bool) (models.ImportResult, error) {
+	if problems := r.Validate(); len(problems) > 0 {
+		return models.ImportResult{}, &storage.InvalidRosterError{Problems: problems}
+	}
+
+	result := models.ImportResult{DryRun: dryRun, UsersCreated: []string{}, Reassignments: []models.Reassignment{}}
+	users := map[string]models.User{}
+	for _, u := range r.Users {
+		users[u.UserID] = models.User{UserID: u.UserID, Username: u.Username, IsActive: u.Active(), IsBot: u.IsBot, BotTeam: u.BotTeam}
+		old, exists := m.users[u.UserID]
+		if !exists {
+			result.UsersCreated = append(result.UsersCreated, u.UserID)
+		} else if old.IsActive && !u.Active() && !dryRun {
+			reassignments, _ := m.DeactivateUser(u.UserID, nil)
+			result.Reassignments = append(result.Reassignments, reassignments...)
+		}
+	}
+	for _, t := range r.Teams {
+		var members []models.MemberSpec
+		for _, member := range t.Members {
+			user := users[member.UserID]
+			members = append(members, models.MemberSpec{UserID: user.UserID, Username: user.Username, IsActive: &user.IsActive, Role: member.Role})
+		}
+		diff, _ := m.ReconcileTeam(t.Name, members, dryRun)
+		result.Teams = append(result.Teams, diff)
+	}
+	if !dryRun {
+		for id, u := range users {
+			if user, ok := m.users[id]; ok {
+				user.IsBot, user.BotTeam = u.IsBot, u.BotTeam
+				m.users[id] = user
+			}
+		}
+	}
+	return result, nil
+}
+
+func (m *MockStore) ExportRoster(includeAssignments bool) (roster.Roster, error) {
+	var r roster.Roster
+	for _, user := range m.users {
+		active := user.IsActive
+		r.Users = append(r.Users, roster.User{UserID: user.UserID, Username: user.Username, IsActive: &active, IsBot: user.IsBot, BotTeam: user.BotTeam})
+	}
+	for _, team := range m.teams {
+		t := roster.Team{Name: team.Name, ParentTeam: team.ParentTeam}
+		for _, member := range team.Members {
+			t.Members = append(t.Members, roster.Member{UserID: member.UserID, Role: member.Role})
+		}
+		r.Teams = append(r.Teams, t)
+	}
+	if includeAssignments {
+		for _, pr := range m.prs {
+			a := roster.Assignment{PullRequestID: pr.ID, AuthorID: pr.AuthorID}
+			for _, reviewer := range pr.Reviewers {
+				a.Reviewers = append(a.Reviewers, reviewer.UserID)
+			}
+			r.Assignments = append(r.Assignments, a)
+		}
+	}
+	return r, nil
+}
+
+func (m *MockStore) GetUser(userID string) (models.User, error) {
+	user, exists := m.users[userID]
+	if !exists {
+		return models.User{}, storage.ErrNotFound
+	}
+	user.TeamName = m.findUserTeam(userID).Name
+	return user, nil
+}
+
+func (m *MockStore) ListUsers(f models.UserFilter) ([]models.User, int, error) {
+	var ids []string
+	for id, user := range m.users {
+		if (f.UserID == "" || id == f.UserID) &&
+			(f.Query == "" || strings.Contains(strings.ToLower(id), strings.ToLower(f.Query)) ||
+				strings.Contains(strings.ToLower(user.Username), strings.ToLower(f.Query))) &&
+			(f.TeamName == "" || m.findUserTeam(id).Name == f.TeamName) &&
+			(f.Active == nil || user.IsActive == *f.Active) &&
+			(f.Skill == "" || contains(user.Skills, f.Skill)) {
+			ids = append(ids, id)
+		}
+	}
+	sort.Strings(ids)
+	total := len(ids)
+	if f.Offset < len(ids) {
+		ids = ids[f.Offset:]
+	} else {
+		ids = nil
+	}
+	if f.Limit > 0 && len(ids) > f.Limit {
+		ids = ids[:f.Limit]
+	}
+	users := []models.User{}
+	for _, id := range ids {
+		user, _ := m.GetUser(id)
+		users = append(users, user)
+	}
+	return users, total, nil
+}
+
+func (m *MockStore) CreateUser(u models.User) (models.User, error) {
+	if _, exists := m.users[u.UserID]; exists {
+		return models.User{}, storage.ErrUserExists
+	}
+	m.users[u.UserID] = u
+	return u, nil
+}
+
+func (m *MockStore) UpdateUser(userID string, p models.UserPatch) (models.User, error) {
+	user, exists := m.users[userID]
+	if !exists {
+		return models.User{}, storage.ErrNotFound
+	}
+	if p.Username != nil {
+		user.Username = *p.Username
+	}
+	if p.Timezone != nil {
+		user.Timezone = *p.Timezone
+	}
+	if p.Skills != nil {
+		user.Skills = *p.Skills
+	}
+	if p.SetOOOUntil {
+		user.OOOUntil = p.OOOUntil
+	}
+	m.users[userID] = user
+	return user, nil
+}
+
+func (m *MockStore) updateUser(u models.User) (models.User, error) {
+	user, exists := m.users[u.UserID]
+	if !exists {
+		return models.User{}, storage.ErrNotFound
+	}
+	user.Username = u.Username
+	if u.Timezone != "" {
+		user.Timezone = u.Timezone
+	}
+	user.Skills, user.OOOUntil = u.Skills, u.OOOUntil
+	m.users[u.UserID] = user
+	return user, nil
+}
+
+func (m *MockStore) UpdateUserActive(u models.User, active bool) (models.User, []models.Reassignment, error) {
+	user, err := m.updateUser(u)
+	if err != nil {
+		return models.User{}, nil, err
+	}
+	reassignments := []models.Reassignment{}
+	switch {
+	case user.IsActive && !active:
+		reassignments, err = m.DeactivateUser(u.UserID, nil)
+	case !user.IsActive && active:
+		_, err = m.SetUserActive(u.UserID, true)
+	}
+	if err != nil {
+		return models.User{}, nil, err
+	}
+	return m.users[u.UserID], reassignments, nil
+}
+
+func (m *MockStore) GetUserProfile(userID string, historyLimit int) (models.UserProfile, error) {
+	user, err := m.GetUser(userID)
+	if err != nil {
+		return models.UserProfile{}, err
+	}
+	p := models.UserProfile{User: user, Teams: []models.Membership{}, RecentAssignments: []models.AssignmentRecord{}}
+	p.OutOfOffice = user.OOOUntil != nil && user.OOOUntil.After(time.Now())
+	for _, team := range m.teams {
+		for _, member := range team.Members {
+			if member.UserID == userID {
+				p.Teams = append(p.Teams, models.Membership{TeamName: team.Name, Role: member.Role})
+			}
+		}
+	}
+	for id, pr := range m.prs {
+		if hasReviewer(pr, userID) {
+			if pr.Status == models.OPEN {
+				p.OpenReviews++
+			}
+			p.RecentAssignments = append(p.RecentAssignments, models.AssignmentRecord{PullRequestID: id, Title: pr.Title, Status: pr.Status})
+		}
+	}
+	return p, nil
+}
+
+func (m *MockStore) DeactivateUser(userID string, reassign *bool) ([]models.Reassignment, error) {
+	user, exists := m.users[userID]
+	if !exists {
+		return nil, storage.ErrNotFound
+	}
+	user.IsActive = false
+	m.users[userID] = user
+	team := m.findUserTeam(userID)
+	for i, member := range team.Members {
+		if member.UserID == userID {
+			team.Members[i].IsActive = false
+		}
+	}
+
+	reassignments := []models.Reassignment{}
+	if (reassign == nil && team.KeepReviewsOnDeactivate) || (reassign != nil && !*reassign) {
+		return reassignments, nil
+	}
+	for id, pr := range m.prs {
+		if pr.Status != models.OPEN {
+			continue
+		}
+		for i, reviewer := range pr.Reviewers {
+			if reviewer.UserID != userID {
+				continue
+			}
+			r := models.Reassignment{PullRequestID: id, OldReviewerID: userID, Reason: storage.ErrNoCandidate.Error()}
+			for _, member := range team.Members {
+				if member.UserID != pr.AuthorID && member.IsActive && !m.users[member.UserID].IsBot && member.Role != models.RoleObserver && !hasReviewer(pr, member.UserID) {
+					pr.Reviewers[i] = member
+					r.NewReviewerID, r.Reason = member.UserID, ""
+					break
+				}
+			}
+			reassignments = append(reassignments, r)
+		}
+	}
+	return reassignments, nil
+}
+
+func (m *MockStore) SetUserBot(u models.User) (models.User, []models.Reassignment, error) {
+	if _, exists := m.teams[u.BotTeam]; u.BotTeam != "" && !exists {
+		return models.User{}, nil, storage.ErrNotFound
+	}
+	user, exists := m.users[u.UserID]
+	if !exists {
+		if !u.IsBot {
+			return models.User{}, nil, storage.ErrNotFound
+		}
+		user = models.User{UserID: u.UserID, Username: u.Username, IsActive: true}
+		if user.Username == "" {
+			user.Username = u.UserID
+		}
+	}
+	wasBot := user.IsBot
+	user.IsBot, user.BotTeam = u.IsBot, u.BotTeam
+	m.users[u.UserID] = user
+
+	reassignments := []models.Reassignment{}
+	if !u.IsBot || wasBot {
+		return user, reassignments, nil
+	}
+	team := m.findUserTeam(u.UserID)
+	for id, pr := range m.prs {
+		for i, reviewer := range pr.Reviewers {
+			if pr.Status != models.OPEN || reviewer.UserID != u.UserID {
+				continue
+			}
+			r := models.Reassignment{PullRequestID: id, OldReviewerID: u.UserID, Reason: storage.ErrNoCandidate.Error()}
+			for _, member := range team.Members {
+				if member.UserID != pr.AuthorID && member.IsActive && !m.users[member.UserID].IsBot && !hasReviewer(pr, member.UserID) {
+					pr.Reviewers[i] = member
+					r.NewReviewerID, r.Reason = member.UserID, ""
+					break
+				}
+			}
+			reassignments = append(reassignments, r)
+		}
+	}
+	return user, reassignments, nil
+}
+
+func (m *MockStore) SetTeamKeepReviews(teamName string, keep bool) (models.Team, error) {
+	team, exists := m.teams[teamName]
+	if !exists {
+		return models.Team{}, storage.ErrNotFound
+	}
+	team.KeepReviewsOnDeactivate = keep
+	m.teams[teamName] = team
+	return team, nil
+}
+
+func (m *MockStore) ListTeams() ([]string, error) {
+	names := []string{}
+	for name := range m.teams {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+func (m *MockStore) recordOperation(deactivated []string, swaps []models.Reassignment) int64 {
+	m.operations = append(m.operations, mockOperation{deactivated: deactivated, swaps: swaps})
+	return int64(len(m.operations))
+}
+
+func (m *MockStore) RevertOperation(id int64) (models.RevertResult, error) {
+	if id < 1 || id > int64(len(m.operations)) {
+		return models.RevertResult{}, storage.ErrNotFound
+	}
+	op := &m.operations[id-1]
+	if op.reverted {
+		return models.RevertResult{}, storage.ErrReverted
+	}
+	op.reverted = true
+
+	result := models.RevertResult{OperationID: id, Reactivated: []string{}, Restored: []models.Reassignment{}, NotReverted: []models.Reassignment{}, ReviewState: models.ReviewPending}
+	for _, userID := range op.deactivated {
+		if user := m.users[userID]; !user.IsActive {
+			user.IsActive = true
+			m.users[userID] = user
+			result.Reactivated = append(result.Reactivated, userID)
+		}
+	}
+	for i := len(op.swaps) - 1; i >= 0; i-- {
+		swap := op.swaps[i]
+		if swap.NewReviewerID == "" {
+			continue
+		}
+		r := models.Reassignment{PullRequestID: swap.PullRequestID, OldReviewerID: swap.NewReviewerID, NewReviewerID: swap.OldReviewerID}
+		pr := m.prs[swap.PullRequestID]
+		switch {
+		case pr.Status != models.OPEN:
+			r.Reason = "PR_NOT_OPEN"
+		case !m.users[r.NewReviewerID].IsActive || m.users[r.NewReviewerID].IsBot:
+			r.Reason = "REVIEWER_INACTIVE"
+		case !hasReviewer(pr, r.OldReviewerID):
+			r.Reason = "REVIEWER_CHANGED"
+		case hasReviewer(pr, r.NewReviewerID):
+			r.Reason = storage.ErrAssigned.Error()
+		}
+		if r.Reason != "" {
+			result.NotReverted = append(result.NotReverted, r)
+			continue
+		}
+		for j, reviewer := range pr.Reviewers {
+			if reviewer.UserID == r.OldReviewerID {
+				pr.Reviewers[j] = m.users[r.NewReviewerID]
+			}
+		}
+		result.Restored = append(result.Restored, r)
+	}
+	return result, nil
+}
+
+func (m *MockStore) ScheduleChange(c models.ScheduledChange) (models.ScheduledChange, error) {
+	if _, exists := m.users[c.UserID]; !exists {
+		return models.ScheduledChange{}, storage.ErrNotFound
+	}
+	for _, team := range []string{c.TeamName, c.FromTeam} {
+		if _, exists := m.teams[team]; team != "" && !exists {
+			return models.ScheduledChange{}, storage.ErrNotFound
+		}
+	}
+	c.ID, c.Status, c.CreatedAt = int64(len(m.changes)+1), models.ChangePending, time.Now()
+	m.changes = append(m.changes, c)
+	return c, nil
+}
+
+func (m *MockStore) ListScheduledChanges(status, userID string) ([]models.ScheduledChange, error) {
+	changes := []models.ScheduledChange{}
+	for _, c := range m.changes {
+		if (status == "" || c.Status == status) && (userID == "" || c.UserID == userID) {
+			changes = append(changes, c)
+		}
+	}
+	return changes, nil
+}
+
+func (m *MockStore) CancelScheduledChange(id int64) (models.ScheduledChange, error) {
+	if id < 1 || id > int64(len(m.changes)) {
+		return models.ScheduledChange{}, storage.ErrNotFound
+	}
+	c := &m.changes[id-1]
+	if c.Status != models.ChangePending {
+		return models.ScheduledChange{}, storage.ErrNotPending
+	}
+	c.Status = models.ChangeCancelled
+	return *c, nil
+}
+
+func (m *MockStore) ApplyScheduledChanges() ([]models.ScheduledChange, error) {
+	applied := []models.ScheduledChange{}
+	for i := range m.changes {
+		c := &m.changes[i]
+		if c.Status != models.ChangePending || c.RunAt.After(time.Now()) {
+			continue
+		}
+		var err error
+		switch c.Kind {
+		case models.ChangeDeactivate:
+			c.Reassignments, err = m.DeactivateUser(c.UserID, c.ReassignReviews)
+		case models.ChangeActivate:
+			_, err = m.SetUserActive(c.UserID, true)
+		case models.ChangeMoveTeam:
+			for _, team := range m.teams {
+				if team.Name != c.TeamName && (c.FromTeam == "" || team.Name == c.FromTeam) {
+					if reassignments, err := m.RemoveTeamMember(team.Name, c.UserID); err == nil {
+						c.Reassignments = append(c.Reassignments, reassignments...)
+					}
+				}
+			}
+			_, err = m.AddTeamMember(c.TeamName, m.users[c.UserID])
+		}
+		c.Status = models.ChangeApplied
+		if err != nil {
+			c.Status, c.Error = models.ChangeFailed, err.Error()
+		}
+		now := time.Now()
+		c.AppliedAt = &now
+		applied = append(applied, *c)
+	}
+	return applied, nil
+}
+
+func (m *MockStore) AddUserAlias(a models.UserAlias) (models.UserAlias, error) {
+	if _, exists := m.users[a.UserID]; !exists {
+		return models.UserAlias{}, storage.ErrNotFound
+	}
+	a.ExternalID = strings.ToLower(a.ExternalID)
+	for _, existing := range m.aliases {
+		if existing.Provider == a.Provider && existing.ExternalID == a.ExternalID {
+			return models.UserAlias{}, storage.ErrAliasExists
+		}
+	}
+	a.CreatedAt = time.Now()
+	m.aliases = append(m.aliases, a)
+	return a, nil
+}
+
+func (m *MockStore) ListUserAliases(userID, provider string) ([]models.UserAlias, error) {
+	aliases := []models.UserAlias{}
+	for _, a := range m.aliases {
+		if (userID == "" || a.UserID == userID) && (provider == "" || a.Provider == provider) {
+			aliases = append(aliases, a)
+		}
+	}
+	return aliases, nil
+}
+
+func (m *MockStore) DeleteUserAlias(provider, externalID string) (models.UserAlias, error) {
+	for i, a := range m.aliases {
+		if a.Provider == provider && a.ExternalID == strings.ToLower(externalID) {
+			m.aliases = append(m.aliases[:i], m.aliases[i+1:]...)
+			return a, nil
+		}
+	}
+	return models.UserAlias{}, storage.ErrNotFound
+}
+
+func (m *MockStore) ResolveUser(provider, identity string) (string, error) {
+	if _, exists := m.users[identity]; exists {
+		return identity, nil
+	}
+	var userIDs []string
+	for _, a := range m.aliases {
+		if a.ExternalID == strings.ToLower(identity) && (provider == "" || a.Provider == provider) && !contains(userIDs, a.UserID) {
+			userIDs = append(userIDs, a.UserID)
+		}
+	}
+	switch len(userIDs) {
+	case 0:
+		return "", storage.ErrNotFound
+	case 1:
+		return userIDs[0], nil
+	}
+	return "", storage.ErrAliasAmbiguous
+}
+
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+func hasReviewer(pr models.PullRequest, userID string) bool {
+	for _, reviewer := range pr.Reviewers {
+		if reviewer.UserID == userID {
+			return true
+		}
+	}
+	return false
+}
+
+// doRequest serves a request through router. A string body is sent as is,
+// anything else as JSON; header holds name, value pairs to set.
+func doRequest(t *testing.T, router http.Handler, method, path string, body interface{}, header ...string) *httptest.ResponseRecorder {
+	t.Helper()
+	var reader io.Reader
+	contentType := ""
+	switch b := body.(type) {
+	case nil:
+	case string:
+		reader = strings.NewReader(b)
+	default:
+		data, err := json.Marshal(b)
+		if err != nil {
+			t.Fatal(err)
+		}
+		reader, contentType = bytes.NewReader(data), "application/json"
+	}
+
+	req := httptest.NewRequest(method, path, reader)
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	for i := 0; i+1 < len(header); i += 2 {
+		req.Header.Set(header[i], header[i+1])
+	}
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	return rr
+}
+
+func (m *MockStore) findUserTeam(userID string) models.Team {
+	for _, team := range m.teams {
+		for _, member := range team.Members {
+			if member.UserID == userID {
+				return team
+			}
+		}
+	}
+	return models.Team{}
+}
+
+// Tests
+func TestCreateTeam(t *testing.T) {
+	store := NewMockStore()
+	handler := NewHandler(store)
+	router := mux.NewRouter()
+	handler.RegisterRoutes(router)
+
+	teamData := map[string]interface{}{
+		"team_name": "backend",
+		"members": []map[string]interface{}{
+			{"user_id": "u1", "username": "Alice", "is_active": true},
+		},
+	}
+	
+	body, _ := json.Marshal(teamData)
+	req := httptest.NewRequest("POST", "/team/add", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	
+	if rr.Code != http.StatusCreated {
+		t.Errorf("Expected status 201, got %d", rr.Code)
+	}
+}
+
+func TestCreatePR(t *testing.T) {
+	store := NewMockStore()
+	
+	// Create team first
+	store.CreateTeam("backend", []models.User{
+		{UserID: "u1", Username: "Alice", IsActive: true},
+		{UserID: "u2", Username: "Bob", IsActive: true},
+	})
+	
+	handler := NewHandler(store)
+	router := mux.NewRouter()
+	handler.RegisterRoutes(router)
+
+	prData := map[string]interface{}{
+		"pull_request_id":   "pr-1",
+		"pull_request_name": "Test PR",
+		"author_id":         "u1",
+	}
+	
+	body, _ := json.Marshal(prData)
+	req := httptest.NewRequest("POST", "/pullRequest/create", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	
+	if rr.Code != http.StatusCreated {
+		t.Errorf("Expected status 201, got %d", rr.Code)
+	}
+}
+
+func TestGetStats(t *testing.T) {
+	store := NewMockStore()
+	handler := NewHandler(store)
+	router := mux.NewRouter()
+	handler.RegisterRoutes(router)
+
+	req := httptest.NewRequest("GET", "/stats/assignments", nil)
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	
+	if rr.Code != http.StatusOK {
+		t.Errorf("Expected status 200, got %d", rr.Code)
+	}
+}
+
+func TestMassDeactivate(t *testing.T) {
+	store := NewMockStore()
+	
+	// Create team first
+	store.CreateTeam("backend", []models.User{
+		{UserID: "u1", Username: "Alice", IsActive: true},
+		{UserID: "u2", Username: "Bob", IsActive: true},
+	})
+	
+	handler := NewHandler(store)
+	router := mux.NewRouter()
+	handler.RegisterRoutes(router)
+
+	deactivateData := map[string]interface{}{
+		"exclude_users": []string{"u1"},
+	}
+	
+	body, _ := json.Marshal(deactivateData)
+	req := httptest.NewRequest("POST", "/team/backend/deactivate", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	
+	if rr.Code != http.StatusOK {
+		t.Errorf("Expected status 200, got %d", rr.Code)
+	}
+}
+
+func TestMassDeactivateSkipsInactiveUsers(t *testing.T) {
+	store := NewMockStore()
+	store.CreateTeam("backend", []models.User{
+		{UserID: "u1", Username: "Alice", IsActive: true},
+		{UserID: "u2", Username: "Bob", IsActive: true},
+		{UserID: "u3", Username: "Carol", IsActive: true},
+		{UserID: "u4", Username: "Dave", IsActive: true},
+	})
+	store.CreatePR(models.PullRequest{ID: "pr-1", Title: "Test PR", AuthorID: "u1", Status: models.OPEN})
+	store.SetUserActive("u3", false)
+
+	handler := NewHandler(store)
+	router := mux.NewRouter()
+	handler.RegisterRoutes(router)
+
+	body, _ := json.Marshal(map[string]interface{}{"exclude_users": []string{"u1", "u4"}})
+	req := httptest.NewRequest("POST", "/team/backend/deactivate", bytes.NewReader(body))
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+
+	var resp struct {
+		DeactivatedUsers int                   `json:"deactivated_users"`
+		Reassignments    []models.Reassignment `json:"reassignments"`
+	}
+	json.Unmarshal(rr.Body.Bytes(), &resp)
+	if resp.DeactivatedUsers != 1 || len(resp.Reassignments) != 1 || resp.Reassignments[0].OldReviewerID != "u2" {
+		t.Errorf("Expected only u2 to be deactivated and handed over, got %s", rr.Body.String())
+	}
+}
+
+func TestMassDeactivateDryRun(t *testing.T) {
+	store := NewMockStore()
+	store.CreateTeam("backend", []models.User{
+		{UserID: "u1", Username: "Alice", IsActive: true},
+		{UserID: "u2", Username: "Bob", IsActive: true},
+		{UserID: "u3", Username: "Carol", IsActive: true},
+		{UserID: "u4", Username: "Dave", IsActive: true},
+	})
+	store.CreatePR(models.PullRequest{ID: "pr-1", Title: "Test PR", AuthorID: "u1", Status: models.OPEN})
+
+	handler := NewHandler(store)
+	router := mux.NewRouter()
+	handler.RegisterRoutes(router)
+
+	deactivate := func(query string, body map[string]interface{}) *httptest.ResponseRecorder {
+		return doRequest(t, router, "POST", "/team/backend/deactivate"+query, body)
+	}
+
+	// u2 and u3 review pr-1; only u4 is left to take one of them over
+	var resp struct {
+		Reassignments   []models.Reassignment `json:"reassignments"`
+		UnresolvedCount int                   `json:"unresolved_count"`
+	}
+	rr := deactivate("?dry_run=true", map[string]interface{}{"exclude_users": []string{"u1", "u4"}})
+	json.Unmarshal(rr.Body.Bytes(), &resp)
+	if rr.Code != http.StatusOK || len(resp.Reassignments) != 2 || resp.UnresolvedCount != 1 {
+		t.Fatalf("Expected one hand-off and one unresolved review, got %d %+v", rr.Code, resp)
+	}
+	if r := resp.Reassignments[1]; r.NewReviewerID != "" || r.Reason != "NO_CANDIDATE" {
+		t.Errorf("Expected the second review to stay with a reason, got %+v", r)
+	}
+	if !store.users["u2"].IsActive || !hasReviewer(store.prs["pr-1"], "u2") {
+		t.Error("Expected the dry run to change nothing")
+	}
+
+	rr = deactivate("", map[string]interface{}{"exclude_users": []string{"u1", "u4"}, "abort_if_unresolved": true})
+	if rr.Code != http.StatusConflict || !store.users["u2"].IsActive {
+		t.Errorf("Expected status 409 and no changes, got %d", rr.Code)
+	}
+
+	rr = deactivate("", map[string]interface{}{"exclude_users": []string{"u1", "u4"}})
+	if rr.Code != http.StatusOK || store.users["u2"].IsActive || !hasReviewer(store.prs["pr-1"], "u4") {
+		t.Errorf("Expected u2 and u3 deactivated and u4 on pr-1, got %d", rr.Code)
+	}
+}
+
+func TestRevertOperation(t *testing.T) {
+	store := NewMockStore()
+	store.CreateTeam("backend", []models.User{
+		{UserID: "u1", Username: "Alice", IsActive: true},
+		{UserID: "u2", Username: "Bob", IsActive: true},
+		{UserID: "u3", Username: "Carol", IsActive: true},
+		{UserID: "u4", Username: "Dave", IsActive: true},
+	})
+	store.CreatePR(models.PullRequest{ID: "pr-1", Title: "Test PR", AuthorID: "u1", Status: models.OPEN})
+	store.CreatePR(models.PullRequest{ID: "pr-2", Title: "Other PR", AuthorID: "u1", Status: models.OPEN})
+
+	handler := NewHandler(store, WithAdminToken("secret"))
+	router := mux.NewRouter()
+	handler.RegisterRoutes(router)
+
+	post := func(path, token string, body map[string]interface{}) *httptest.ResponseRecorder {
+		return doRequest(t, router, "POST", path, body, "X-Admin-Token", token)
+	}
+
+	rr := post("/team/backend/deactivate", "", map[string]interface{}{"exclude_users": []string{"u1", "u4"}})
+	var deactivation struct {
+		OperationID int64 `json:"operation_id"`
+	}
+	json.Unmarshal(rr.Body.Bytes(), &deactivation)
+	if deactivation.OperationID == 0 || !hasReviewer(store.prs["pr-1"], "u4") || !hasReviewer(store.prs["pr-2"], "u4") {
+		t.Fatalf("Expected an operation ID and u4 on both PRs, got %s", rr.Body.String())
+	}
+
+	// pr-2 is merged in the meantime and keeps its current reviewers
+	pr := store.prs["pr-2"]
+	pr.Status = models.MERGED
+	store.prs["pr-2"] = pr
+
+	revert := fmt.Sprintf("/admin/operations/%d/revert", deactivation.OperationID)
+	if rr := post(revert, "wrong", nil); rr.Code != http.StatusForbidden {
+		t.Errorf("Expected status 403 without the admin token, got %d", rr.Code)
+	}
+
+	rr = post(revert, "secret", nil)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", rr.Code)
+	}
+	var result models.RevertResult
+	json.Unmarshal(rr.Body.Bytes(), &result)
+	if len(result.Reactivated) != 2 || !store.users["u2"].IsActive || !store.users["u3"].IsActive {
+		t.Errorf("Expected u2 and u3 to be reactivated, got %+v", result.Reactivated)
+	}
+	if len(result.Restored) != 1 || !hasReviewer(store.prs["pr-1"], "u2") || hasReviewer(store.prs["pr-1"], "u4") {
+		t.Errorf("Expected u2 back on pr-1, got %+v", result.Restored)
+	}
+	if len(result.NotReverted) != 1 || result.NotReverted[0].Reason != "PR_NOT_OPEN" {
+		t.Errorf("Expected the merged pr-2 to be reported, got %+v", result.NotReverted)
+	}
+	if result.ReviewState != models.ReviewPending {
+		t.Errorf("Expected restored reviewers to start over as PENDING, got %q", result.ReviewState)
+	}
+
+	if rr := post(revert, "secret", nil); rr.Code != http.StatusConflict {
+		t.Errorf("Expected status 409 for a second revert, got %d", rr.Code)
+	}
+	if rr := post("/admin/operations/99/revert", "secret", nil); rr.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404, got %d", rr.Code)
+	}
+
+	// u2 is turned into a bot after being swapped out, so stays swapped out
+	rr = post("/team/backend/deactivate", "", map[string]interface{}{"exclude_users": []string{"u1", "u3", "u4"}})
+	json.Unmarshal(rr.Body.Bytes(), &deactivation)
+	bot := store.users["u2"]
+	bot.IsBot = true
+	store.users["u2"] = bot
+	rr = post(fmt.Sprintf("/admin/operations/%d/revert", deactivation.OperationID), "secret", nil)
+	result = models.RevertResult{}
+	json.Unmarshal(rr.Body.Bytes(), &result)
+	if len(result.NotReverted) != 1 || result.NotReverted[0].Reason != "REVIEWER_INACTIVE" || hasReviewer(store.prs["pr-1"], "u2") {
+		t.Errorf("Expected the swap back to the bot u2 to be refused, got %s", rr.Body.String())
+	}
+}
+
+func TestMergePRPolicyViolation(t *testing.T) {
+	store := NewMockStore()
+	store.CreateTeam("backend", []models.User{
+		{UserID: "u1", Username: "Alice", IsActive: true},
+		{UserID: "u2", Username: "Bob", IsActive: true},
+	})
+	store.SetMergePolicy(models.MergePolicy{TeamName: "backend", MinApprovals: 1})
+	store.CreatePR(models.PullRequest{ID: "pr-1", Title: "Test PR", AuthorID: "u1", Status: models.OPEN})
+
+	handler := NewHandler(store, WithAdminToken("secret"))
+	router := mux.NewRouter()
+	handler.RegisterRoutes(router)
+
+	merge := func(body map[string]interface{}, token string) *httptest.ResponseRecorder {
+		return doRequest(t, router, "POST", "/pullRequest/merge", body, "X-Admin-Token", token)
+	}
+
+	rr := merge(map[string]interface{}{"pull_request_id": "pr-1"}, "")
+	if rr.Code != http.StatusConflict {
+		t.Fatalf("Expected status 409, got %d", rr.Code)
+	}
+	var resp struct {
+		Violations []models.RuleViolation `json:"violations"`
+	}
+	json.Unmarshal(rr.Body.Bytes(), &resp)
+	if len(resp.Violations) != 1 || resp.Violations[0].Rule != policy.RuleMinApprovals {
+		t.Errorf("Expected min_approvals violation, got %v", resp.Violations)
+	}
+
+	rr = merge(map[string]interface{}{"pull_request_id": "pr-1", "force": true}, "wrong")
+	if rr.Code != http.StatusForbidden {
+		t.Errorf("Expected status 403, got %d", rr.Code)
+	}
+
+	rr = merge(map[string]interface{}{"pull_request_id": "pr-1", "force": true}, "secret")
+	if rr.Code != http.StatusOK {
+		t.Errorf("Expected status 200, got %d", rr.Code)
+	}
+}
+
+func TestMergePolicyRequiresAdmin(t *testing.T) {
+	store := NewMockStore()
+	store.CreateTeam("backend", []models.User{{UserID: "u1", Username: "Alice", IsActive: true}})
+	store.SetMergePolicy(models.MergePolicy{TeamName: "backend", MinApprovals: 1})
+
+	handler := NewHandler(store, WithAdminToken("secret"))
+	router := mux.NewRouter()
+	handler.RegisterRoutes(router)
+
+	post := func(path string, body map[string]interface{}, token string) *httptest.ResponseRecorder {
+		return doRequest(t, router, "POST", path, body, "X-Admin-Token", token)
+	}
+
+	policyBody := map[string]interface{}{"team_name": "backend", "min_approvals": 0}
+	freezeBody := map[string]interface{}{"team_name": "backend", "ends_at": time.Now().Add(time.Hour)}
+	if rr := post("/mergePolicy/set", policyBody, ""); rr.Code != http.StatusForbidden {
+		t.Errorf("Expected status 403 without a token, got %d", rr.Code)
+	}
+	if rr := post("/mergePolicy/freeze", freezeBody, "wrong"); rr.Code != http.StatusForbidden {
+		t.Errorf("Expected status 403 with a wrong token, got %d", rr.Code)
+	}
+	if rr := post("/repository/setMergePolicy", map[string]interface{}{"repository": "acme/api"}, ""); rr.Code != http.StatusForbidden {
+		t.Errorf("Expected status 403 for a repository policy without a token, got %d", rr.Code)
+	}
+	if p, _ := store.GetMergePolicy("backend"); p.MinApprovals != 1 {
+		t.Errorf("Expected the policy to be unchanged, got %+v", p)
+	}
+
+	if rr := post("/mergePolicy/set", policyBody, "secret"); rr.Code != http.StatusOK {
+		t.Errorf("Expected status 200, got %d %s", rr.Code, rr.Body.String())
+	}
+	if rr := post("/mergePolicy/freeze", freezeBody, "secret"); rr.Code != http.StatusCreated {
+		t.Errorf("Expected status 201, got %d %s", rr.Code, rr.Body.String())
+	}
+}
+
+func TestSubmitReviewSatisfiesPolicy(t *testing.T) {
+	store := NewMockStore()
+	store.CreateTeam("backend", []models.User{
+		{UserID: "u1", Username: "Alice", IsActive: true},
+		{UserID: "u2", Username: "Bob", IsActive: true},
+	})
+	store.SetMergePolicy(models.MergePolicy{TeamName: "backend", MinApprovals: 1})
+	store.CreatePR(models.PullRequest{ID: "pr-1", Title: "Test PR", AuthorID: "u1", Status: models.OPEN})
+
+	handler := NewHandler(store)
+	router := mux.NewRouter()
+	handler.RegisterRoutes(router)
+
+	body, _ := json.Marshal(map[string]interface{}{
+		"pull_request_id": "pr-1",
+		"user_id":         "u2",
+		"state":           "APPROVED",
+	})
+	req := httptest.NewRequest("POST", "/pullRequest/review", bytes.NewReader(body))
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", rr.Code)
+	}
+
+	body, _ = json.Marshal(map[string]interface{}{"pull_request_id": "pr-1"})
+	req = httptest.NewRequest("POST", "/pullRequest/merge", bytes.NewReader(body))
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Errorf("Expected status 200, got %d", rr.Code)
+	}
+}
+
+func TestPRSizeAndPriority(t *testing.T) {
+	store := NewMockStore()
+	store.CreateTeam("backend", []models.User{
+		{UserID: "u1", Username: "Alice", IsActive: true},
+		{UserID: "u2", Username: "Bob", IsActive: true},
+		{UserID: "u3", Username: "Carol", IsActive: true},
+		{UserID: "u4", Username: "Dave", IsActive: true},
+	})
+
+	handler := NewHandler(store)
+	router := mux.NewRouter()
+	handler.RegisterRoutes(router)
+
+	rr := doRequest(t, router, "POST", "/team/setSizeRules", map[string]interface{}{
+		"team_name": "backend",
+		"rules":     []map[string]int{{"min_lines": 500, "reviewer_count": 3}},
+	})
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", rr.Code)
+	}
+
+	rr = doRequest(t, router, "POST", "/pullRequest/create", map[string]interface{}{
+		"pull_request_id":   "pr-big",
+		"pull_request_name": "Big PR",
+		"author_id":         "u1",
+		"lines_added":       450,
+		"lines_removed":     100,
+		"priority":          "LOW",
+	})
+	var resp struct {
+		PR models.PullRequest `json:"pr"`
+	}
+	json.Unmarshal(rr.Body.Bytes(), &resp)
+	if len(resp.PR.Reviewers) != 3 {
+		t.Errorf("Expected 3 reviewers for a large PR, got %d", len(resp.PR.Reviewers))
+	}
+
+	doRequest(t, router, "POST", "/pullRequest/create", map[string]interface{}{
+		"pull_request_id":   "pr-hot",
+		"pull_request_name": "Hotfix",
+		"author_id":         "u1",
+		"lines_added":       5,
+		"priority":          "CRITICAL",
+	})
+
+	rr = doRequest(t, router, "POST", "/pullRequest/create", map[string]interface{}{
+		"pull_request_id":   "pr-bad",
+		"pull_request_name": "Bad",
+		"author_id":         "u1",
+		"priority":          "URGENT",
+	})
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400 for unknown priority, got %d", rr.Code)
+	}
+
+	req := httptest.NewRequest("GET", "/users/getReview?user_id=u2", nil)
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+
+	var queue struct {
+		PullRequests []models.PullRequestShort `json:"pull_requests"`
+	}
+	json.Unmarshal(rr.Body.Bytes(), &queue)
+	if len(queue.PullRequests) != 2 || queue.PullRequests[0].ID != "pr-hot" {
+		t.Errorf("Expected pr-hot first in the queue, got %v", queue.PullRequests)
+	}
+}
+
+func TestStackedPRs(t *testing.T) {
+	store := NewMockStore()
+	store.CreateTeam("backend", []models.User{
+		{UserID: "u1", Username: "Alice", IsActive: true},
+		{UserID: "u2", Username: "Bob", IsActive: true},
+		{UserID: "u3", Username: "Carol", IsActive: true},
+		{UserID: "u4", Username: "Dave", IsActive: true},
+	})
+
+	handler := NewHandler(store)
+	router := mux.NewRouter()
+	handler.RegisterRoutes(router)
+
+	doRequest(t, router, "POST", "/pullRequest/create", map[string]interface{}{
+		"pull_request_id": "pr-base", "pull_request_name": "Base", "author_id": "u4",
+	})
+	rr := doRequest(t, router, "POST", "/pullRequest/create", map[string]interface{}{
+		"pull_request_id": "pr-child", "pull_request_name": "Child", "author_id": "u1", "depends_on": "pr-base",
+	})
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("Expected status 201, got %d", rr.Code)
+	}
+
+	// Base is reviewed by u1 and u2; u1 authored the child, so only u2 carries over
+	child, _ := store.GetPR("pr-child")
+	if child.Reviewers[0].UserID != "u2" {
+		t.Errorf("Expected the stack to keep reviewer u2, got %s", child.Reviewers[0].UserID)
+	}
+
+	rr = doRequest(t, router, "POST", "/pullRequest/link", map[string]interface{}{"pull_request_id": "pr-base", "depends_on": "pr-child"})
+	if rr.Code != http.StatusConflict {
+		t.Errorf("Expected status 409 for a cycle, got %d", rr.Code)
+	}
+
+	rr = doRequest(t, router, "POST", "/pullRequest/merge", map[string]interface{}{"pull_request_id": "pr-child"})
+	if rr.Code != http.StatusConflict {
+		t.Errorf("Expected status 409 while parent is open, got %d", rr.Code)
+	}
+
+	doRequest(t, router, "POST", "/pullRequest/merge", map[string]interface{}{"pull_request_id": "pr-base"})
+	rr = doRequest(t, router, "POST", "/pullRequest/merge", map[string]interface{}{"pull_request_id": "pr-child"})
+	if rr.Code != http.StatusOK {
+		t.Errorf("Expected status 200 after parent merge, got %d", rr.Code)
+	}
+}
+
+func TestRespondToAssignment(t *testing.T) {
+	store := NewMockStore()
+	store.CreateTeam("backend", []models.User{
+		{UserID: "u1", Username: "Alice", IsActive: true},
+		{UserID: "u2", Username: "Bob", IsActive: true},
+		{UserID: "u3", Username: "Carol", IsActive: true},
+		{UserID: "u4", Username: "Dave", IsActive: true},
+	})
+	store.CreatePR(models.PullRequest{ID: "pr-1", Title: "Test PR", AuthorID: "u1", Status: models.OPEN})
+
+	handler := NewHandler(store)
+	router := mux.NewRouter()
+	handler.RegisterRoutes(router)
+
+	rr := doRequest(t, router, "POST", "/pullRequest/respond", map[string]interface{}{"pull_request_id": "pr-1", "user_id": "u2", "response": "maybe"})
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400, got %d", rr.Code)
+	}
+
+	rr = doRequest(t, router, "POST", "/pullRequest/respond", map[string]interface{}{"pull_request_id": "pr-1", "user_id": "u2", "response": "decline", "reason": "on vacation"})
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", rr.Code)
+	}
+	var resp struct {
+		ReplacedBy string `json:"replaced_by"`
+	}
+	json.Unmarshal(rr.Body.Bytes(), &resp)
+	if resp.ReplacedBy == "" || resp.ReplacedBy == "u2" {
+		t.Errorf("Expected a replacement for u2, got %q", resp.ReplacedBy)
+	}
+
+	rr = doRequest(t, router, "POST", "/pullRequest/respond", map[string]interface{}{"pull_request_id": "pr-1", "user_id": "u2", "response": "decline"})
+	if rr.Code != http.StatusConflict {
+		t.Errorf("Expected status 409 for a reviewer no longer assigned, got %d", rr.Code)
+	}
+
+	// Reviewers of a closed PR stay as they are
+	store.ClosePR("pr-1", models.ProviderGitHub)
+	reviewer := store.prs["pr-1"].Reviewers[0].UserID
+	for _, call := range []struct{ path, userKey, response string }{
+		{"/pullRequest/respond", "user_id", "accept"},
+		{"/pullRequest/respond", "user_id", "decline"},
+		{"/pullRequest/reassign", "old_user_id", ""},
+		{"/pullRequest/addReviewer", "user_id", ""},
+	} {
+		user := reviewer
+		if call.path == "/pullRequest/addReviewer" {
+			user = "u2"
+		}
+		body := map[string]interface{}{"pull_request_id": "pr-1", call.userKey: user, "response": call.response}
+		rr = doRequest(t, router, "POST", call.path, body)
+		if rr.Code != http.StatusConflict || !strings.Contains(rr.Body.String(), "PR_CLOSED") {
+			t.Errorf("Expected 409 PR_CLOSED from %s %s on a closed PR, got %d %s", call.path, call.response, rr.Code, rr.Body.String())
+		}
+	}
+}
+
+func TestSetTeamSLA(t *testing.T) {
+	store := NewMockStore()
+	store.CreateTeam("backend", []models.User{
+		{UserID: "u1", Username: "Alice", IsActive: true, Timezone: "Europe/Berlin"},
+	})
+
+	handler := NewHandler(store)
+	router := mux.NewRouter()
+	handler.RegisterRoutes(router)
+
+	rr := doRequest(t, router, "POST", "/team/setSla", map[string]interface{}{"team_name": "backend", "review_sla_hours": 8, "holidays": []string{"2025-12-25"}})
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", rr.Code)
+	}
+	var resp struct {
+		Team models.Team `json:"team"`
+	}
+	json.Unmarshal(rr.Body.Bytes(), &resp)
+	if resp.Team.ReviewSLAHours != 8 || len(resp.Team.Holidays) != 1 {
+		t.Errorf("Expected an 8h SLA with one holiday, got %+v", resp.Team)
+	}
+
+	rr = doRequest(t, router, "POST", "/team/setSla", map[string]interface{}{"team_name": "backend", "review_sla_hours": 8, "holidays": []string{"25.12.2025"}})
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400 for a malformed holiday, got %d", rr.Code)
+	}
+
+	rr = doRequest(t, router, "POST", "/team/setSla", map[string]interface{}{"team_name": "missing", "review_sla_hours": 8})
+	if rr.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404, got %d", rr.Code)
+	}
+
+	req := httptest.NewRequest("GET", "/sla/breaches?team_name=backend", nil)
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Errorf("Expected status 200, got %d", rr.Code)
+	}
+}
+
+func TestSetTeamEscalation(t *testing.T) {
+	store := NewMockStore()
+	handler := NewHandler(store)
+	router := mux.NewRouter()
+	handler.RegisterRoutes(router)
+
+	rr := doRequest(t, router, "POST", "/team/add", map[string]interface{}{
+		"team_name": "backend",
+		"members": []map[string]interface{}{
+			{"user_id": "u1", "username": "Alice", "is_active": true, "role": "owner"},
+		},
+	})
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400 for an unknown role, got %d", rr.Code)
+	}
+
+	rr = doRequest(t, router, "POST", "/team/add", map[string]interface{}{
+		"team_name": "backend",
+		"members": []map[string]interface{}{
+			{"user_id": "u1", "username": "Alice", "is_active": true, "role": "lead"},
+			{"user_id": "u2", "username": "Bob", "is_active": true},
+		},
+	})
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("Expected status 201, got %d", rr.Code)
+	}
+
+	rr = doRequest(t, router, "POST", "/team/setEscalation", map[string]interface{}{"team_name": "backend", "escalation_minutes": 120})
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", rr.Code)
+	}
+	var resp struct {
+		Team models.Team `json:"team"`
+	}
+	json.Unmarshal(rr.Body.Bytes(), &resp)
+	if resp.Team.EscalationMinutes != 120 {
+		t.Errorf("Expected a 120 minute window, got %d", resp.Team.EscalationMinutes)
+	}
+
+	rr = doRequest(t, router, "POST", "/team/setEscalation", map[string]interface{}{"team_name": "backend", "escalation_minutes": 60, "escalation_user_id": "nobody"})
+	if rr.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404 for an unknown escalation user, got %d", rr.Code)
+	}
+}
+
+func TestTeamMembership(t *testing.T) {
+	store := NewMockStore()
+	store.CreateTeam("backend", []models.User{
+		{UserID: "u1", Username: "Alice", IsActive: true},
+		{UserID: "u2", Username: "Bob", IsActive: true},
+		{UserID: "u3", Username: "Carol", IsActive: true},
+	})
+	store.CreatePR(models.PullRequest{ID: "pr-1", Title: "Test PR", AuthorID: "u1", Status: models.OPEN})
+
+	handler := NewHandler(store)
+	router := mux.NewRouter()
+	handler.RegisterRoutes(router)
+
+	member := map[string]interface{}{"user_id": "u4", "username": "Dave", "is_active": true}
+	rr := doRequest(t, router, "POST", "/team/addMember", map[string]interface{}{"team_name": "backend", "member": member})
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", rr.Code)
+	}
+	rr = doRequest(t, router, "POST", "/team/addMember", map[string]interface{}{"team_name": "backend", "member": member})
+	if rr.Code != http.StatusConflict {
+		t.Errorf("Expected status 409 for an existing member, got %d", rr.Code)
+	}
+
+	// pr-1 is reviewed by u2 and u3, so u2's review can only go to u4
+	rr = doRequest(t, router, "POST", "/team/removeMember", map[string]interface{}{"team_name": "backend", "user_id": "u2"})
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", rr.Code)
+	}
+	var resp struct {
+		Reassignments []models.Reassignment `json:"reassignments"`
+	}
+	json.Unmarshal(rr.Body.Bytes(), &resp)
+	if len(resp.Reassignments) != 1 || resp.Reassignments[0].NewReviewerID != "u4" {
+		t.Errorf("Expected pr-1 to move to u4, got %+v", resp.Reassignments)
+	}
+
+	rr = doRequest(t, router, "POST", "/team/removeMember", map[string]interface{}{"team_name": "backend", "user_id": "u2"})
+	if rr.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404 for a non-member, got %d", rr.Code)
+	}
+
+	// An existing user joins another team as they are
+	store.CreateTeam("frontend", []models.User{})
+	rr = doRequest(t, router, "POST", "/team/addMember", map[string]interface{}{"team_name": "frontend", "member": map[string]interface{}{"user_id": "u3"}})
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", rr.Code)
+	}
+	if u := store.users["u3"]; u.Username != "Carol" || !u.IsActive {
+		t.Errorf("Expected u3 to keep their name and active flag, got %+v", u)
+	}
+}
+
+func TestRenameAndDeleteTeam(t *testing.T) {
+	store := NewMockStore()
+	store.CreateTeam("backend", []models.User{
+		{UserID: "u1", Username: "Alice", IsActive: true},
+		{UserID: "u2", Username: "Bob", IsActive: true},
+	})
+	store.CreateTeam("platform", []models.User{
+		{UserID: "u3", Username: "Carol", IsActive: true},
+	})
+	store.CreatePR(models.PullRequest{ID: "pr-1", Title: "Test PR", AuthorID: "u1", Status: models.OPEN})
+
+	handler := NewHandler(store)
+	router := mux.NewRouter()
+	handler.RegisterRoutes(router)
+
+	data, _ := json.Marshal(map[string]string{"team_name": "backend", "new_team_name": "platform"})
+	req := httptest.NewRequest("POST", "/team/rename", bytes.NewReader(data))
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	if rr.Code != http.StatusConflict {
+		t.Errorf("Expected status 409 when renaming onto an existing team, got %d", rr.Code)
+	}
+
+	data, _ = json.Marshal(map[string]string{"team_name": "backend", "new_team_name": "core"})
+	req = httptest.NewRequest("POST", "/team/rename", bytes.NewReader(data))
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", rr.Code)
+	}
+
+	// u2 reviews the open pr-1, so a plain delete is refused
+	req = httptest.NewRequest("DELETE", "/team/core", nil)
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	if rr.Code != http.StatusConflict {
+		t.Errorf("Expected status 409 while members review open PRs, got %d", rr.Code)
+	}
+
+	req = httptest.NewRequest("DELETE", "/team/core?move_to=platform", nil)
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", rr.Code)
+	}
+	if team, _ := store.GetTeam("platform"); len(team.Members) != 3 {
+		t.Errorf("Expected members to move to platform, got %v", team.Members)
+	}
+
+	// A policy requiring the team's review keeps it from being dropped
+	store.CreateTeam("qa", []models.User{{UserID: "u4", Username: "Dave", IsActive: true}})
+	store.SetMergePolicy(models.MergePolicy{TeamName: "platform", MinApprovals: 1, RequiredReviewerTeam: "qa"})
+	rr = doRequest(t, router, "DELETE", "/team/qa", nil)
+	if rr.Code != http.StatusConflict {
+		t.Fatalf("Expected status 409 while a policy requires the team, got %d", rr.Code)
+	}
+	var conflict struct {
+		Policies []models.MergePolicy `json:"merge_policies"`
+	}
+	json.Unmarshal(rr.Body.Bytes(), &conflict)
+	if len(conflict.Policies) != 1 || conflict.Policies[0].TeamName != "platform" {
+		t.Errorf("Expected the platform policy in the conflict, got %+v", conflict.Policies)
+	}
+
+	rr = doRequest(t, router, "DELETE", "/team/qa?move_to=platform", nil)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", rr.Code)
+	}
+	if p, _ := store.GetMergePolicy("platform"); p.RequiredReviewerTeam != "platform" {
+		t.Errorf("Expected the policy to require platform instead, got %+v", p)
+	}
+}
+
+func TestReconcileTeam(t *testing.T) {
+	store := NewMockStore()
+	store.CreateTeam("backend", []models.User{
+		{UserID: "u1", Username: "Alice", IsActive: true},
+		{UserID: "u2", Username: "Bob", IsActive: true},
+	})
+
+	handler := NewHandler(store)
+	router := mux.NewRouter()
+	handler.RegisterRoutes(router)
+
+	put := func(path string, members []map[string]interface{}) models.TeamDiff {
+		rr := doRequest(t, router, "PUT", path, map[string]interface{}{"members": members})
+		if rr.Code != http.StatusOK {
+			t.Fatalf("Expected status 200, got %d", rr.Code)
+		}
+		var resp struct {
+			Diff models.TeamDiff `json:"diff"`
+		}
+		json.Unmarshal(rr.Body.Bytes(), &resp)
+		return resp.Diff
+	}
+
+	members := []map[string]interface{}{
+		{"user_id": "u1", "username": "Alice Smith", "is_active": true},
+		{"user_id": "u3", "username": "Carol", "is_active": true},
+	}
+
+	diff := put("/team/backend?dry_run=true", members)
+	if !diff.DryRun || len(diff.Added) != 1 || len(diff.Removed) != 1 || len(diff.Updated) != 1 {
+		t.Errorf("Unexpected dry run diff: %+v", diff)
+	}
+	if team, _ := store.GetTeam("backend"); len(team.Members) != 2 || team.Members[1].UserID != "u2" {
+		t.Errorf("Expected a dry run to leave the team alone, got %v", team.Members)
+	}
+
+	put("/team/backend", members)
+	if team, _ := store.GetTeam("backend"); team.Members[1].UserID != "u3" {
+		t.Errorf("Expected u3 in the team, got %v", team.Members)
+	}
+
+	// Switching a member off hands their reviews over; an omitted username
+	// is kept
+	store.CreatePR(models.PullRequest{ID: "pr-1", Title: "Test PR", AuthorID: "u1", Status: models.OPEN})
+	diff = put("/team/backend", []map[string]interface{}{
+		{"user_id": "u1", "username": "Alice Smith", "is_active": true},
+		{"user_id": "u3", "is_active": false},
+		{"user_id": "u4", "username": "Dave", "is_active": true},
+	})
+	if len(diff.Reassignments) != 1 || diff.Reassignments[0].OldReviewerID != "u3" {
+		t.Errorf("Expected u3's review to be handed over, got %+v", diff.Reassignments)
+	}
+	if u := store.users["u3"]; u.Username != "Carol" || u.IsActive {
+		t.Errorf("Expected u3 to be Carol and inactive, got %+v", u)
+	}
+
+	// An omitted is_active keeps the stored flag and hands nothing over
+	store.CreatePR(models.PullRequest{ID: "pr-2", Title: "Test PR", AuthorID: "u1", Status: models.OPEN})
+	diff = put("/team/backend", []map[string]interface{}{
+		{"user_id": "u1", "username": "Alice Smith"},
+		{"user_id": "u3"},
+		{"user_id": "u4", "username": "Dave"},
+	})
+	if len(diff.Updated) != 0 || len(diff.Reassignments) != 0 {
+		t.Errorf("Expected no changes when is_active is omitted, got %+v", diff)
+	}
+	if !store.users["u1"].IsActive || !store.users["u4"].IsActive || store.users["u3"].IsActive {
+		t.Errorf("Expected stored active flags to be kept, got %+v", store.users)
+	}
+
+	data, _ := json.Marshal(map[string]interface{}{"members": []map[string]interface{}{{"user_id": "u1"}, {"user_id": "u1"}}})
+	req := httptest.NewRequest("PUT", "/team/backend", bytes.NewReader(data))
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400 for duplicate members, got %d", rr.Code)
+	}
+}
+
+func TestTeamHierarchy(t *testing.T) {
+	store := NewMockStore()
+	store.CreateTeam("engineering", nil)
+	store.CreateTeam("backend", nil)
+	store.CreateTeam("payments", nil)
+
+	handler := NewHandler(store)
+	router := mux.NewRouter()
+	handler.RegisterRoutes(router)
+
+	setParent := func(team, parent string) *httptest.ResponseRecorder {
+		return doRequest(t, router, "POST", "/team/setParent", map[string]string{"team_name": team, "parent_team": parent})
+	}
+
+	if rr := setParent("backend", "engineering"); rr.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", rr.Code)
+	}
+	if rr := setParent("payments", "backend"); rr.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", rr.Code)
+	}
+	if rr := setParent("engineering", "payments"); rr.Code != http.StatusConflict {
+		t.Errorf("Expected status 409 for a cycle, got %d", rr.Code)
+	}
+
+	req := httptest.NewRequest("GET", "/team/get?team_name=backend", nil)
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+
+	var team models.Team
+	json.Unmarshal(rr.Body.Bytes(), &team)
+	if len(team.Children) != 1 || team.Children[0] != "payments" {
+		t.Errorf("Expected payments as the only child, got %v", team.Children)
+	}
+	if len(team.Path) != 1 || team.Path[0] != "engineering" {
+		t.Errorf("Expected path [engineering], got %v", team.Path)
+	}
+}
+
+func TestObserversAreNotAutoAssigned(t *testing.T) {
+	store := NewMockStore()
+	handler := NewHandler(store)
+	router := mux.NewRouter()
+	handler.RegisterRoutes(router)
+
+	rr := doRequest(t, router, "POST", "/team/add", map[string]interface{}{
+		"team_name": "backend",
+		"members": []map[string]interface{}{
+			{"user_id": "u1", "username": "Alice", "is_active": true, "role": "lead"},
+			{"user_id": "u2", "username": "Bob", "is_active": true, "role": "observer"},
+			{"user_id": "u3", "username": "Carol", "is_active": true},
+		},
+	})
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("Expected status 201, got %d", rr.Code)
+	}
+	var created struct {
+		Team models.Team `json:"team"`
+	}
+	json.Unmarshal(rr.Body.Bytes(), &created)
+	if created.Team.Members[2].Role != models.RoleMember {
+		t.Errorf("Expected the default role member, got %q", created.Team.Members[2].Role)
+	}
+
+	doRequest(t, router, "POST", "/pullRequest/create", map[string]interface{}{
+		"pull_request_id": "pr-1", "pull_request_name": "Test PR", "author_id": "u3",
+	})
+	pr, _ := store.GetPR("pr-1")
+	if len(pr.Reviewers) != 1 || pr.Reviewers[0].UserID != "u1" {
+		t.Errorf("Expected only the lead to be assigned, got %v", pr.Reviewers)
+	}
+
+	rr = doRequest(t, router, "POST", "/pullRequest/addReviewer", map[string]interface{}{"pull_request_id": "pr-1", "user_id": "u2"})
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status 200 adding an observer by hand, got %d", rr.Code)
+	}
+	rr = doRequest(t, router, "POST", "/pullRequest/addReviewer", map[string]interface{}{"pull_request_id": "pr-1", "user_id": "u2"})
+	if rr.Code != http.StatusConflict {
+		t.Errorf("Expected status 409 for a reviewer already assigned, got %d", rr.Code)
+	}
+	rr = doRequest(t, router, "POST", "/pullRequest/addReviewer", map[string]interface{}{"pull_request_id": "pr-1", "user_id": "u3"})
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400 for the author, got %d", rr.Code)
+	}
+}
+
+func TestImportRoster(t *testing.T) {
+	store := NewMockStore()
+	handler := NewHandler(store, WithAdminToken("secret"))
+	router := mux.NewRouter()
+	handler.RegisterRoutes(router)
+
+	post := func(query, body string, admin bool) *httptest.ResponseRecorder {
+		if admin {
+			return doRequest(t, router, "POST", "/admin/import"+query, body, "X-Admin-Token", "secret")
+		}
+		return doRequest(t, router, "POST", "/admin/import"+query, body)
+	}
+
+	csv := "team,parent_team,user_id,username,role,is_active,timezone\n" +
+		"backend,,u1,Alice,lead,true,\n" +
+		"backend,,u2,Bob,,true,\n"
+
+	if rr := post("?format=csv", csv, false); rr.Code != http.StatusForbidden {
+		t.Errorf("Expected status 403 without admin token, got %d", rr.Code)
+	}
+
+	rr := post("?format=csv&dry_run=true", csv, true)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	var resp struct {
+		Result models.ImportResult `json:"result"`
+	}
+	json.Unmarshal(rr.Body.Bytes(), &resp)
+	if !resp.Result.DryRun || len(resp.Result.UsersCreated) != 2 || len(resp.Result.Teams) != 1 {
+		t.Errorf("Unexpected dry run result: %+v", resp.Result)
+	}
+	if _, err := store.GetTeam("backend"); err == nil {
+		t.Errorf("Expected a dry run to create nothing")
+	}
+
+	if rr := post("?format=csv", csv, true); rr.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", rr.Code)
+	}
+	if team, err := store.GetTeam("backend"); err != nil || len(team.Members) != 2 {
+		t.Errorf("Expected backend with two members, got %+v", team)
+	}
+
+	// Deactivated users hand their reviews over
+	store.CreatePR(models.PullRequest{ID: "pr-1", Title: "Test PR", AuthorID: "u1", Status: models.OPEN})
+	rr = post("?format=csv", strings.Replace(csv, "u2,Bob,,true", "u2,Bob,,false", 1), true)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", rr.Code)
+	}
+	json.Unmarshal(rr.Body.Bytes(), &resp)
+	if len(resp.Result.Reassignments) != 1 || resp.Result.Reassignments[0].OldReviewerID != "u2" {
+		t.Errorf("Expected u2's review to be handed over, got %+v", resp.Result.Reassignments)
+	}
+
+	yaml := "users:\n  - user_id: u1\n    username: Alice\nteams:\n  - name: backend\n    members:\n      - user_id: u7\n"
+	rr = post("", yaml, true)
+	if rr.Code != http.StatusBadRequest || !bytes.Contains(rr.Body.Bytes(), []byte("INVALID_ROSTER")) {
+		t.Errorf("Expected INVALID_ROSTER, got %d: %s", rr.Code, rr.Body.String())
+	}
+}
+
+func TestExportRoster(t *testing.T) {
+	store := NewMockStore()
+	store.CreateTeam("backend", []models.User{
+		{UserID: "u1", Username: "Alice", IsActive: true, Role: "lead"},
+		{UserID: "u2", Username: "Bob", IsActive: true},
+	})
+	store.CreatePR(models.PullRequest{ID: "pr-1", Title: "Test PR", AuthorID: "u1", Status: models.OPEN})
+
+	handler := NewHandler(store, WithAdminToken("secret"))
+	router := mux.NewRouter()
+	handler.RegisterRoutes(router)
+
+	get := func(query string) *httptest.ResponseRecorder {
+		return doRequest(t, router, "GET", "/admin/export"+query, nil, "X-Admin-Token", "secret")
+	}
+
+	rr := get("?format=csv")
+	if rr.Code != http.StatusOK || rr.Header().Get("Content-Type") != "text/csv" {
+		t.Fatalf("Expected a CSV export, got %d %s", rr.Code, rr.Header().Get("Content-Type"))
+	}
+	parsed, err := roster.Parse(roster.FormatCSV, rr.Body)
+	if err != nil || len(parsed.Teams) != 1 || len(parsed.Teams[0].Members) != 2 {
+		t.Errorf("Expected the export to parse back into backend with two members, got %+v (%v)", parsed, err)
+	}
+
+	store.SetUserBot(models.User{UserID: "ci-bot", IsBot: true, BotTeam: "backend"})
+	rr = get("?format=yaml&assignments=true")
+	parsed, err = roster.Parse(roster.FormatYAML, rr.Body)
+	var bot roster.User
+	for _, u := range parsed.Users {
+		if u.UserID == "ci-bot" {
+			bot = u
+		}
+	}
+	if !bot.IsBot || bot.BotTeam != "backend" {
+		t.Errorf("Expected ci-bot to be exported as a bot of backend, got %+v", bot)
+	}
+	if err != nil || len(parsed.Assignments) != 1 || len(parsed.Assignments[0].Reviewers) != 1 {
+		t.Errorf("Expected pr-1 with its reviewer in the export, got %+v (%v)", parsed.Assignments, err)
+	}
+
+	if rr := get("?format=xml"); rr.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400 for an unknown format, got %d", rr.Code)
+	}
+
+	// CSV would drop a team without members
+	store.teams["platform"] = models.Team{Name: "platform"}
+	if rr := get("?format=csv"); rr.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400 for a CSV export with an empty team, got %d", rr.Code)
+	}
+}
+
+// RecordDelivery keeps deliveries in m.deliveries, true once handled
+func (m *MockStore) RecordDelivery(provider, deliveryID, event string) (bool, error) {
+	key := provider + "/" + deliveryID
+	if done, exists := m.deliveries[key]; exists {
+		if !done {
+			return false, storage.ErrDeliveryInProgress
+		}
+		return false, nil
+	}
+	m.deliveries[key] = false
+	return true, nil
+}
+
+func (m *MockStore) FinishDelivery(provider, deliveryID string) error {
+	m.deliveries[provider+"/"+deliveryID] = true
+	return nil
+}
+
+func (m *MockStore) ForgetDelivery(provider, deliveryID string) error {
+	delete(m.deliveries, provider+"/"+deliveryID)
+	return nil
+}
+
+func (m *MockStore) PendingReviewerSyncs(limit int) ([]models.ReviewerSyncTask, error) {
+	tasks := []models.ReviewerSyncTask{}
+	for id, pr := range m.prs {
+		if pr.ReviewerSync != models.ReviewerSyncPending || len(tasks) >= limit {
+			continue
+		}
+		t := models.ReviewerSyncTask{PullRequestID: id, Repository: pr.Repository, Number: pr.Number, Provider: m.repos[pr.Repository].Provider}
+		for _, reviewer := range pr.Reviewers {
+			t.Logins = append(t.Logins, reviewer.UserID)
+		}
+		tasks = append(tasks, t)
+	}
+	return tasks, nil
+}
+
+func (m *MockStore) FinishReviewerSync(t models.ReviewerSyncTask) error {
+	pr := m.prs[t.PullRequestID]
+	pr.ReviewerSync, pr.ReviewerSyncError = t.Status, t.Error
+	m.prs[t.PullRequestID] = pr
+	return nil
+}
@@ -0,0 +1,41 @@
+package api
+
+import (
+	"net/http"
+
+	"pr-reviewer-service/internal/storage"
+)
+
+func (h *Handler) setTeamEscalation(w http.ResponseWriter, r *http.Request) {
+	var in struct {
+		TeamName          string `json:"team_name"`
+		EscalationMinutes int    `json:"escalation_minutes"`
+		EscalationUserID  string `json:"escalation_user_id"`
+	}
+	if err := decode(r, &in); err != nil || in.TeamName == "" || in.EscalationMinutes < 0 {
+		respondError(w, "400", "BAD_REQUEST", "Invalid request body")
+		return
+	}
+
+	team, err := h.store.SetTeamEscalation(in.TeamName, in.EscalationMinutes, in.EscalationUserID)
+	if err != nil {
+		if err == storage.ErrNotFound {
+			respondError(w, "404", "NOT_FOUND", "team or escalation user not found")
+		} else {
+			respondError(w, "500", "INTERNAL_ERROR", err.Error())
+		}
+		return
+	}
+
+	respondJSON(w, 200, map[string]interface{}{"team": team})
+}
+
+func (h *Handler) listEscalations(w http.ResponseWriter, r *http.Request) {
+	escalations, err := h.store.ListEscalations(r.URL.Query().Get("team_name"))
+	if err != nil {
+		respondError(w, "500", "INTERNAL_ERROR", "Failed to get escalations")
+		return
+	}
+
+	respondJSON(w, 200, map[string]interface{}{"escalations": escalations})
+}
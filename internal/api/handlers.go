@@ -2,6 +2,7 @@ package api
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 	"time"
@@ -13,32 +14,132 @@ import (
 )
 
 type Handler struct {
-	store storage.Store
+	store      storage.Store
+	adminToken string
+	scimToken  string
+
+	githubSecret string
+	gitlabToken  string
+}
+
+// Option configures optional Handler settings
+type Option func(*Handler)
+
+// WithAdminToken enables admin-only operations for requests carrying the
+// token in the X-Admin-Token header. Without it those operations are refused.
+func WithAdminToken(token string) Option {
+	return func(h *Handler) {
+		h.adminToken = token
+	}
 }
 
-func NewHandler(s storage.Store) *Handler {
-	return &Handler{store: s}
+// WithSCIMToken enables the SCIM endpoints for requests carrying the token
+// as a bearer token. Without it they are refused.
+func WithSCIMToken(token string) Option {
+	return func(h *Handler) {
+		h.scimToken = token
+	}
+}
+
+// WithGitHubWebhookSecret enables /webhooks/github for payloads signed with
+// the secret. Without it every delivery is refused.
+func WithGitHubWebhookSecret(secret string) Option {
+	return func(h *Handler) {
+		h.githubSecret = secret
+	}
+}
+
+// WithGitLabWebhookToken enables /webhooks/gitlab for requests carrying the
+// token in the X-Gitlab-Token header. Without it every delivery is refused.
+func WithGitLabWebhookToken(token string) Option {
+	return func(h *Handler) {
+		h.gitlabToken = token
+	}
+}
+
+func NewHandler(s storage.Store, opts ...Option) *Handler {
+	h := &Handler{store: s}
+	for _, opt := range opts {
+		opt(h)
+	}
+	return h
 }
 
 func (h *Handler) RegisterRoutes(r *mux.Router) {
 	// Teams
 	r.HandleFunc("/team/add", h.createTeam).Methods("POST")
 	r.HandleFunc("/team/get", h.getTeam).Methods("GET")
+	r.HandleFunc("/team/setSizeRules", h.setTeamSizeRules).Methods("POST")
+	r.HandleFunc("/team/setSla", h.setTeamSLA).Methods("POST")
+	r.HandleFunc("/team/setEscalation", h.setTeamEscalation).Methods("POST")
+	r.HandleFunc("/team/addMember", h.addTeamMember).Methods("POST")
+	r.HandleFunc("/team/removeMember", h.removeTeamMember).Methods("POST")
+	r.HandleFunc("/team/rename", h.renameTeam).Methods("POST")
+	r.HandleFunc("/team/setKeepReviews", h.setTeamKeepReviews).Methods("POST")
+	r.HandleFunc("/team/setParent", h.setTeamParent).Methods("POST")
+	r.HandleFunc("/team/{name}", h.deleteTeam).Methods("DELETE")
+	r.HandleFunc("/team/{name}", h.reconcileTeam).Methods("PUT")
 	
 	// Users
 	r.HandleFunc("/users/setIsActive", h.setUserActive).Methods("POST")
+	r.HandleFunc("/users/get", h.getUser).Methods("GET")
+	r.HandleFunc("/users/list", h.listUsers).Methods("GET")
+	r.HandleFunc("/users/update", h.updateUser).Methods("POST")
+	r.HandleFunc("/users/setBot", h.setUserBot).Methods("POST")
+
+	// Identity aliases
+	r.HandleFunc("/users/aliases/add", h.addUserAlias).Methods("POST")
+	r.HandleFunc("/users/aliases/list", h.listUserAliases).Methods("GET")
+	r.HandleFunc("/users/aliases/delete", h.deleteUserAlias).Methods("POST")
+
+	// Scheduled user changes
+	r.HandleFunc("/scheduledChanges/add", h.scheduleChange).Methods("POST")
+	r.HandleFunc("/scheduledChanges/list", h.listScheduledChanges).Methods("GET")
+	r.HandleFunc("/scheduledChanges/cancel", h.cancelScheduledChange).Methods("POST")
 	
 	// Pull Requests
 	r.HandleFunc("/pullRequest/create", h.createPR).Methods("POST")
 	r.HandleFunc("/pullRequest/merge", h.mergePR).Methods("POST")
 	r.HandleFunc("/pullRequest/reassign", h.reassignReviewer).Methods("POST")
+	r.HandleFunc("/pullRequest/review", h.submitReview).Methods("POST")
+	r.HandleFunc("/pullRequest/get", h.getPR).Methods("GET")
+	r.HandleFunc("/pullRequest/link", h.linkPR).Methods("POST")
+	r.HandleFunc("/pullRequest/respond", h.respondToAssignment).Methods("POST")
+	r.HandleFunc("/pullRequest/addReviewer", h.addReviewer).Methods("POST")
 	r.HandleFunc("/users/getReview", h.listPRsAssignedTo).Methods("GET")
 	
+	// Repositories
+	r.HandleFunc("/repository/add", h.createRepository).Methods("POST")
+	r.HandleFunc("/repository/get", h.getRepository).Methods("GET")
+	r.HandleFunc("/repository/list", h.listRepositories).Methods("GET")
+	r.HandleFunc("/repository/update", h.updateRepository).Methods("POST")
+	r.HandleFunc("/repository/setMergePolicy", h.setRepositoryMergePolicy).Methods("POST")
+
+	// Merge policies
+	r.HandleFunc("/mergePolicy/set", h.setMergePolicy).Methods("POST")
+	r.HandleFunc("/mergePolicy/get", h.getMergePolicy).Methods("GET")
+	r.HandleFunc("/mergePolicy/freeze", h.addMergeFreeze).Methods("POST")
+	r.HandleFunc("/mergePolicy/freezes", h.listMergeFreezes).Methods("GET")
+
 	// Statistics
 	r.HandleFunc("/stats/assignments", h.getStats).Methods("GET")
+	r.HandleFunc("/sla/breaches", h.listSLABreaches).Methods("GET")
+	r.HandleFunc("/escalations", h.listEscalations).Methods("GET")
 	
 	// Mass deactivation
 	r.HandleFunc("/team/{name}/deactivate", h.massDeactivate).Methods("POST")
+
+	// Administration
+	r.HandleFunc("/admin/import", h.importRoster).Methods("POST")
+	r.HandleFunc("/admin/export", h.exportRoster).Methods("GET")
+	r.HandleFunc("/admin/operations/{id}/revert", h.revertOperation).Methods("POST")
+
+	// SCIM provisioning
+	h.registerSCIMRoutes(r)
+
+	// Code host webhooks
+	r.HandleFunc("/webhooks/github", h.githubWebhook).Methods("POST")
+	r.HandleFunc("/webhooks/gitlab", h.gitlabWebhook).Methods("POST")
 	
 	// Health check
 	r.HandleFunc("/health", func(w http.ResponseWriter, r *http.Request) {
@@ -60,7 +161,7 @@ func respondJSON(w http.ResponseWriter, code int, v interface{}) {
 
 func respondError(w http.ResponseWriter, code, errorCode, message string) {
 	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(getHTTPStatusCode(code))
+	w.WriteHeader(getHTTPStatusCode(errorCode))
 	json.NewEncoder(w).Encode(map[string]interface{}{
 		"error": map[string]string{
 			"code":    errorCode,
@@ -71,17 +172,29 @@ func respondError(w http.ResponseWriter, code, errorCode, message string) {
 
 func getHTTPStatusCode(errorCode string) int {
 	switch errorCode {
-	case "TEAM_EXISTS", "PR_EXISTS":
+	case "TEAM_EXISTS", "PR_EXISTS", "REPOSITORY_EXISTS", "PR_AMBIGUOUS", "MEMBER_EXISTS", "ALREADY_ASSIGNED":
 		return http.StatusConflict
 	case "NOT_FOUND":
 		return http.StatusNotFound
-	case "PR_MERGED", "NOT_ASSIGNED", "NO_CANDIDATE":
+	case "UNAUTHORIZED":
+		return http.StatusUnauthorized
+	case "FORBIDDEN":
+		return http.StatusForbidden
+	case "PR_MERGED", "PR_CLOSED", "NOT_ASSIGNED", "NO_CANDIDATE", "POLICY_VIOLATION", "PARENT_OPEN", "DEPENDENCY_CYCLE", "TEAM_CYCLE", "ALREADY_REVERTED", "NOT_PENDING",
+		"ALIAS_EXISTS", "ALIAS_AMBIGUOUS", "DELIVERY_IN_PROGRESS":
 		return http.StatusConflict
+	case "INTERNAL_ERROR":
+		return http.StatusInternalServerError
 	default:
 		return http.StatusBadRequest
 	}
 }
 
+// isAdmin reports whether the request carries the configured admin token
+func (h *Handler) isAdmin(r *http.Request) bool {
+	return h.adminToken != "" && r.Header.Get("X-Admin-Token") == h.adminToken
+}
+
 // Handlers
 
 func (h *Handler) createTeam(w http.ResponseWriter, r *http.Request) {
@@ -93,6 +206,15 @@ func (h *Handler) createTeam(w http.ResponseWriter, r *http.Request) {
 		respondError(w, "400", "BAD_REQUEST", "Invalid request body")
 		return
 	}
+	for i, member := range in.Members {
+		if msg := validateMember(member); msg != "" {
+			respondError(w, "400", "BAD_REQUEST", msg)
+			return
+		}
+		if member.Role == "" {
+			in.Members[i].Role = models.RoleMember
+		}
+	}
 
 	if err := h.store.CreateTeam(in.TeamName, in.Members); err != nil {
 		if err.Error() == "TEAM_EXISTS" {
@@ -110,6 +232,23 @@ func (h *Handler) createTeam(w http.ResponseWriter, r *http.Request) {
 	respondJSON(w, 201, map[string]interface{}{"team": team})
 }
 
+// validateMember returns a message describing what is wrong with a team
+// member from a request body, or an empty string
+func validateMember(member models.User) string {
+	if member.UserID == "" {
+		return "user_id is required"
+	}
+	if _, err := time.LoadLocation(member.Timezone); err != nil {
+		return "unknown timezone " + member.Timezone
+	}
+	switch member.Role {
+	case "", models.RoleMember, models.RoleLead, models.RoleObserver:
+	default:
+		return "role must be lead, member or observer"
+	}
+	return ""
+}
+
 func (h *Handler) getTeam(w http.ResponseWriter, r *http.Request) {
 	teamName := r.URL.Query().Get("team_name")
 	if teamName == "" {
@@ -126,23 +265,92 @@ func (h *Handler) getTeam(w http.ResponseWriter, r *http.Request) {
 	respondJSON(w, 200, team)
 }
 
+func (h *Handler) setTeamSizeRules(w http.ResponseWriter, r *http.Request) {
+	var in struct {
+		TeamName string            `json:"team_name"`
+		Rules    []models.SizeRule `json:"rules"`
+	}
+	if err := decode(r, &in); err != nil || in.TeamName == "" {
+		respondError(w, "400", "BAD_REQUEST", "Invalid request body")
+		return
+	}
+
+	seen := map[int]bool{}
+	for _, rule := range in.Rules {
+		if rule.MinLines < 0 || rule.ReviewerCount < 0 || seen[rule.MinLines] {
+			respondError(w, "400", "BAD_REQUEST", "rules must have unique non-negative min_lines and reviewer_count")
+			return
+		}
+		seen[rule.MinLines] = true
+	}
+
+	rules, err := h.store.SetTeamSizeRules(in.TeamName, in.Rules)
+	if err != nil {
+		if err == storage.ErrNotFound {
+			respondError(w, "404", "NOT_FOUND", "team not found")
+		} else {
+			respondError(w, "500", "INTERNAL_ERROR", err.Error())
+		}
+		return
+	}
+
+	respondJSON(w, 200, map[string]interface{}{"team_name": in.TeamName, "size_rules": rules})
+}
+
+// setUserActive deactivates users together with handing their open reviews
+// over; reassign_reviews overrides the team's default
 func (h *Handler) setUserActive(w http.ResponseWriter, r *http.Request) {
 	var in struct {
-		UserID   string `json:"user_id"`
-		IsActive bool   `json:"is_active"`
+		UserID          string `json:"user_id"`
+		IsActive        bool   `json:"is_active"`
+		ReassignReviews *bool  `json:"reassign_reviews"`
 	}
 	if err := decode(r, &in); err != nil || in.UserID == "" {
 		respondError(w, "400", "BAD_REQUEST", "Invalid request body")
 		return
 	}
 
-	user, err := h.store.SetUserActive(in.UserID, in.IsActive)
-	if err != nil {
+	if in.IsActive {
+		user, err := h.store.SetUserActive(in.UserID, true)
+		if err != nil {
+			respondError(w, "404", "NOT_FOUND", "user not found")
+			return
+		}
+		respondJSON(w, 200, map[string]interface{}{"user": user})
+		return
+	}
+
+	reassignments, err := h.store.DeactivateUser(in.UserID, in.ReassignReviews)
+	if err == storage.ErrNotFound {
 		respondError(w, "404", "NOT_FOUND", "user not found")
 		return
 	}
+	if err != nil {
+		respondError(w, "500", "INTERNAL_ERROR", err.Error())
+		return
+	}
+	user, err := h.store.GetUser(in.UserID)
+	if err != nil {
+		respondError(w, "500", "INTERNAL_ERROR", err.Error())
+		return
+	}
+
+	reassignedPRs, noCandidatePRs := []string{}, []string{}
+	for _, ra := range reassignments {
+		if ra.NewReviewerID != "" {
+			reassignedPRs = append(reassignedPRs, ra.PullRequestID)
+		} else {
+			noCandidatePRs = append(noCandidatePRs, ra.PullRequestID)
+		}
+	}
 
-	respondJSON(w, 200, map[string]interface{}{"user": user})
+	respondJSON(w, 200, map[string]interface{}{
+		"user":             user,
+		"reassigned_prs":   reassignedPRs,
+		"reassigned_count": len(reassignedPRs),
+		"no_candidate_prs": noCandidatePRs,
+		"reassignments":    reassignments,
+	})
 }
 
 func (h *Handler) createPR(w http.ResponseWriter, r *http.Request) {
@@ -150,6 +358,13 @@ func (h *Handler) createPR(w http.ResponseWriter, r *http.Request) {
 		PullRequestID   string `json:"pull_request_id"`
 		PullRequestName string `json:"pull_request_name"`
 		AuthorID        string `json:"author_id"`
+		Repository      string `json:"repository"`
+		Number          int    `json:"number"`
+		LinesAdded      int    `json:"lines_added"`
+		LinesRemoved    int    `json:"lines_removed"`
+		FilesChanged    int    `json:"files_changed"`
+		Priority        models.PRPriority `json:"priority"`
+		DependsOn       string `json:"depends_on"`
 	}
 	
 	fmt.Printf("DEBUG: Received PR creation request\n")
@@ -162,54 +377,184 @@ func (h *Handler) createPR(w http.ResponseWriter, r *http.Request) {
 	
 	fmt.Printf("DEBUG: Parsed data - PR ID: %s, Name: %s, Author: %s\n", in.PullRequestID, in.PullRequestName, in.AuthorID)
 
-	if in.PullRequestID == "" || in.PullRequestName == "" || in.AuthorID == "" {
+	// Repository PRs are identified by number, pull_request_id is optional there
+	missingID := in.PullRequestID == "" && in.Repository == ""
+	if missingID || in.PullRequestName == "" || in.AuthorID == "" || (in.Repository != "" && in.Number <= 0) {
 		fmt.Printf("DEBUG: Missing required fields\n")
 		respondError(w, "400", "BAD_REQUEST", "Missing required fields")
 		return
 	}
 
+	if in.LinesAdded < 0 || in.LinesRemoved < 0 || in.FilesChanged < 0 {
+		respondError(w, "400", "BAD_REQUEST", "PR size must not be negative")
+		return
+	}
+	if in.Priority == "" {
+		in.Priority = models.PriorityMedium
+	} else if !in.Priority.Valid() {
+		respondError(w, "400", "BAD_REQUEST", "priority must be LOW, MEDIUM, HIGH or CRITICAL")
+		return
+	}
+
 	pr := models.PullRequest{
 		ID:       in.PullRequestID,
 		Title:    in.PullRequestName,
 		AuthorID: in.AuthorID,
 		Status:   models.OPEN,
 		CreatedAt: func() *time.Time { t := time.Now(); return &t }(),
+		Repository: in.Repository,
+		Number:     in.Number,
+		LinesAdded:   in.LinesAdded,
+		LinesRemoved: in.LinesRemoved,
+		FilesChanged: in.FilesChanged,
+		Priority:     in.Priority,
+		DependsOn:    in.DependsOn,
 	}
 
 	fmt.Printf("DEBUG: Creating PR in database...\n")
-	if err := h.store.CreatePR(pr); err != nil {
+	createdPR, err := h.store.CreatePR(pr)
+	if err != nil {
 		fmt.Printf("DEBUG: Store error: %v\n", err)
 		if err.Error() == "PR_EXISTS" {
 			respondError(w, "409", "PR_EXISTS", "PR id already exists")
+		} else if err == storage.ErrAliasAmbiguous {
+			respondError(w, "409", "ALIAS_AMBIGUOUS", "author_id is an alias of several users")
+		} else if err == storage.ErrNoTeam {
+			respondError(w, "404", "NOT_FOUND", "author team not found, bots need a bot_team")
 		} else {
 			respondError(w, "404", "NOT_FOUND", err.Error())
 		}
 		return
 	}
 
-	// Get the created PR with reviewers assigned
-	createdPR, err := h.store.GetPR(pr.ID)
+	fmt.Printf("DEBUG: PR created successfully\n")
+	respondJSON(w, 201, map[string]interface{}{"pr": createdPR})
+}
+
+func (h *Handler) mergePR(w http.ResponseWriter, r *http.Request) {
+	var in struct {
+		PullRequestID string `json:"pull_request_id"`
+		Force         bool   `json:"force"`
+	}
+	if err := decode(r, &in); err != nil || in.PullRequestID == "" {
+		respondError(w, "400", "BAD_REQUEST", "Invalid request body")
+		return
+	}
+
+	if in.Force && !h.isAdmin(r) {
+		respondError(w, "403", "FORBIDDEN", "force merge requires admin token")
+		return
+	}
+
+	pr, err := h.store.MergePR(in.PullRequestID, in.Force)
+	if err != nil {
+		var violation *storage.PolicyViolationError
+		switch {
+		case errors.As(err, &violation):
+			respondJSON(w, http.StatusConflict, map[string]interface{}{
+				"error": map[string]string{
+					"code":    "POLICY_VIOLATION",
+					"message": "merge policy is not satisfied",
+				},
+				"violations": violation.Violations,
+			})
+		case errors.Is(err, storage.ErrNotFound):
+			respondError(w, "404", "NOT_FOUND", "PR not found")
+		case errors.Is(err, storage.ErrPRAmbiguous):
+			respondError(w, "409", "PR_AMBIGUOUS", "PR id matches several repositories, use repository#number")
+		case errors.Is(err, storage.ErrParentOpen):
+			respondError(w, "409", "PARENT_OPEN", "PR depends on a PR that is still open")
+		case errors.Is(err, storage.ErrPRClosed):
+			respondError(w, "409", "PR_CLOSED", "cannot merge closed PR")
+		default:
+			respondError(w, "500", "INTERNAL_ERROR", err.Error())
+		}
+		return
+	}
+
+	respondJSON(w, 200, map[string]interface{}{"pr": pr})
+}
+
+func (h *Handler) getPR(w http.ResponseWriter, r *http.Request) {
+	prID := r.URL.Query().Get("pull_request_id")
+	if prID == "" {
+		respondError(w, "400", "BAD_REQUEST", "pull_request_id is required")
+		return
+	}
+
+	pr, err := h.store.GetPR(prID)
 	if err != nil {
-		respondError(w, "500", "INTERNAL_ERROR", "Failed to get created PR")
+		if err == storage.ErrPRAmbiguous {
+			respondError(w, "409", "PR_AMBIGUOUS", "PR id matches several repositories, use repository#number")
+		} else {
+			respondError(w, "404", "NOT_FOUND", "PR not found")
+		}
 		return
 	}
 
-	fmt.Printf("DEBUG: PR created successfully\n")
-	respondJSON(w, 201, map[string]interface{}{"pr": createdPR})
+	respondJSON(w, 200, map[string]interface{}{"pr": pr})
 }
 
-func (h *Handler) mergePR(w http.ResponseWriter, r *http.Request) {
+func (h *Handler) linkPR(w http.ResponseWriter, r *http.Request) {
 	var in struct {
 		PullRequestID string `json:"pull_request_id"`
+		DependsOn     string `json:"depends_on"`
 	}
 	if err := decode(r, &in); err != nil || in.PullRequestID == "" {
 		respondError(w, "400", "BAD_REQUEST", "Invalid request body")
 		return
 	}
 
-	pr, err := h.store.MergePR(in.PullRequestID)
+	pr, err := h.store.LinkPR(in.PullRequestID, in.DependsOn)
 	if err != nil {
-		respondError(w, "404", "NOT_FOUND", "PR not found")
+		switch err {
+		case storage.ErrNotFound:
+			respondError(w, "404", "NOT_FOUND", "PR not found")
+		case storage.ErrPRMerged:
+			respondError(w, "409", "PR_MERGED", "cannot link merged PR")
+		case storage.ErrDepCycle:
+			respondError(w, "409", "DEPENDENCY_CYCLE", "dependency would create a cycle")
+		case storage.ErrPRAmbiguous:
+			respondError(w, "409", "PR_AMBIGUOUS", "PR id matches several repositories, use repository#number")
+		default:
+			respondError(w, "500", "INTERNAL_ERROR", err.Error())
+		}
+		return
+	}
+
+	respondJSON(w, 200, map[string]interface{}{"pr": pr})
+}
+
+func (h *Handler) submitReview(w http.ResponseWriter, r *http.Request) {
+	var in struct {
+		PullRequestID string             `json:"pull_request_id"`
+		UserID        string             `json:"user_id"`
+		State         models.ReviewState `json:"state"`
+	}
+	if err := decode(r, &in); err != nil || in.PullRequestID == "" || in.UserID == "" {
+		respondError(w, "400", "BAD_REQUEST", "Invalid request body")
+		return
+	}
+
+	if in.State != models.ReviewApproved && in.State != models.ReviewChangesRequested {
+		respondError(w, "400", "BAD_REQUEST", "state must be APPROVED or CHANGES_REQUESTED")
+		return
+	}
+
+	pr, err := h.store.SubmitReview(in.PullRequestID, in.UserID, in.State)
+	if err != nil {
+		switch err {
+		case storage.ErrNotFound:
+			respondError(w, "404", "NOT_FOUND", "PR not found")
+		case storage.ErrPRMerged:
+			respondError(w, "409", "PR_MERGED", "cannot review merged PR")
+		case storage.ErrNotAssigned:
+			respondError(w, "409", "NOT_ASSIGNED", "reviewer is not assigned to this PR")
+		case storage.ErrPRAmbiguous:
+			respondError(w, "409", "PR_AMBIGUOUS", "PR id matches several repositories, use repository#number")
+		default:
+			respondError(w, "500", "INTERNAL_ERROR", err.Error())
+		}
 		return
 	}
 
@@ -231,17 +576,21 @@ func (h *Handler) reassignReviewer(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	pr, newReviewerID, err := h.store.ReassignReviewer(in.PullRequestID, in.OldUserID)
+	pr, newReviewerID, operationID, err := h.store.ReassignReviewer(in.PullRequestID, in.OldUserID)
 	if err != nil {
 		switch err.Error() {
 		case "NOT_FOUND":
 			respondError(w, "404", "NOT_FOUND", "PR or user not found")
 		case "PR_MERGED":
 			respondError(w, "409", "PR_MERGED", "cannot reassign on merged PR")
+		case "PR_CLOSED":
+			respondError(w, "409", "PR_CLOSED", "cannot reassign on closed PR")
 		case "NOT_ASSIGNED":
 			respondError(w, "409", "NOT_ASSIGNED", "reviewer is not assigned to this PR")
 		case "NO_CANDIDATE":
 			respondError(w, "409", "NO_CANDIDATE", "no active replacement candidate in team")
+		case "PR_AMBIGUOUS":
+			respondError(w, "409", "PR_AMBIGUOUS", "PR id matches several repositories, use repository#number")
 		default:
 			respondError(w, "409", "CONFLICT", err.Error())
 		}
@@ -249,11 +598,92 @@ func (h *Handler) reassignReviewer(w http.ResponseWriter, r *http.Request) {
 	}
 
 	respondJSON(w, 200, map[string]interface{}{
-		"pr":          pr,
-		"replaced_by": newReviewerID,
+		"pr":           pr,
+		"replaced_by":  newReviewerID,
+		"operation_id": operationID,
 	})
 }
 
+func (h *Handler) respondToAssignment(w http.ResponseWriter, r *http.Request) {
+	var in struct {
+		PullRequestID string `json:"pull_request_id"`
+		UserID        string `json:"user_id"`
+		Response      string `json:"response"`
+		Reason        string `json:"reason"`
+	}
+	if err := decode(r, &in); err != nil || in.PullRequestID == "" || in.UserID == "" {
+		respondError(w, "400", "BAD_REQUEST", "Invalid request body")
+		return
+	}
+	if in.Response != "accept" && in.Response != "decline" {
+		respondError(w, "400", "BAD_REQUEST", "response must be accept or decline")
+		return
+	}
+
+	pr, newReviewerID, err := h.store.RespondToAssignment(in.PullRequestID, in.UserID, in.Response == "accept", in.Reason)
+	if err != nil {
+		switch err {
+		case storage.ErrNotFound:
+			respondError(w, "404", "NOT_FOUND", "PR or user not found")
+		case storage.ErrPRMerged:
+			respondError(w, "409", "PR_MERGED", "cannot respond on merged PR")
+		case storage.ErrPRClosed:
+			respondError(w, "409", "PR_CLOSED", "cannot respond on closed PR")
+		case storage.ErrNotAssigned:
+			respondError(w, "409", "NOT_ASSIGNED", "reviewer is not assigned to this PR")
+		case storage.ErrNoCandidate:
+			respondError(w, "409", "NO_CANDIDATE", "no active replacement candidate in team")
+		case storage.ErrPRAmbiguous:
+			respondError(w, "409", "PR_AMBIGUOUS", "PR id matches several repositories, use repository#number")
+		default:
+			respondError(w, "500", "INTERNAL_ERROR", err.Error())
+		}
+		return
+	}
+
+	resp := map[string]interface{}{"pr": pr}
+	if newReviewerID != "" {
+		resp["replaced_by"] = newReviewerID
+	}
+	respondJSON(w, 200, resp)
+}
+
+func (h *Handler) addReviewer(w http.ResponseWriter, r *http.Request) {
+	var in struct {
+		PullRequestID string `json:"pull_request_id"`
+		UserID        string `json:"user_id"`
+	}
+	if err := decode(r, &in); err != nil || in.PullRequestID == "" || in.UserID == "" {
+		respondError(w, "400", "BAD_REQUEST", "Invalid request body")
+		return
+	}
+
+	pr, err := h.store.AddReviewer(in.PullRequestID, in.UserID)
+	if err != nil {
+		switch err {
+		case storage.ErrNotFound:
+			respondError(w, "404", "NOT_FOUND", "PR or active user not found")
+		case storage.ErrPRMerged:
+			respondError(w, "409", "PR_MERGED", "cannot add reviewer to merged PR")
+		case storage.ErrPRClosed:
+			respondError(w, "409", "PR_CLOSED", "cannot add reviewer to closed PR")
+		case storage.ErrAssigned:
+			respondError(w, "409", "ALREADY_ASSIGNED", "user already reviews this PR")
+		case storage.ErrAuthor:
+			respondError(w, "400", "AUTHOR_CANNOT_REVIEW", "author cannot review their own PR")
+		case storage.ErrBot:
+			respondError(w, "400", "BOT_CANNOT_REVIEW", "bots cannot review PRs")
+		case storage.ErrPRAmbiguous:
+			respondError(w, "409", "PR_AMBIGUOUS", "PR id matches several repositories, use repository#number")
+		default:
+			respondError(w, "500", "INTERNAL_ERROR", err.Error())
+		}
+		return
+	}
+
+	respondJSON(w, 200, map[string]interface{}{"pr": pr})
+}
+
 func (h *Handler) listPRsAssignedTo(w http.ResponseWriter, r *http.Request) {
 	userID := r.URL.Query().Get("user_id")
 	if userID == "" {
@@ -275,6 +705,7 @@ func (h *Handler) listPRsAssignedTo(w http.ResponseWriter, r *http.Request) {
 			Title:    pr.Title,
 			AuthorID: pr.AuthorID,
 			Status:   pr.Status,
+			Priority: pr.Priority,
 		})
 	}
 
@@ -298,9 +729,11 @@ func (h *Handler) getStats(w http.ResponseWriter, r *http.Request) {
 // New method for mass deactivation
 func (h *Handler) massDeactivate(w http.ResponseWriter, r *http.Request) {
 	teamName := mux.Vars(r)["name"]
+	dryRun := r.URL.Query().Get("dry_run") == "true"
 	
 	var in struct {
-		ExcludeUsers []string `json:"exclude_users"`
+		ExcludeUsers      []string `json:"exclude_users"`
+		AbortIfUnresolved bool     `json:"abort_if_unresolved"`
 	}
 	
 	if err := decode(r, &in); err != nil {
@@ -308,9 +741,23 @@ func (h *Handler) massDeactivate(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	result, err := h.store.MassDeactivate(teamName, in.ExcludeUsers)
+	result, err := h.store.MassDeactivate(teamName, in.ExcludeUsers, dryRun, in.AbortIfUnresolved)
 	if err != nil {
-		respondError(w, "500", "INTERNAL_ERROR", err.Error())
+		var unresolved *storage.UnresolvedReviewsError
+		switch {
+		case errors.As(err, &unresolved):
+			respondJSON(w, http.StatusConflict, map[string]interface{}{
+				"error": map[string]string{
+					"code":    "UNRESOLVED_REVIEWS",
+					"message": "some open reviews would be left without a replacement; nothing was changed",
+				},
+				"reassignments": unresolved.Reassignments,
+			})
+		case err == storage.ErrNotFound:
+			respondError(w, "404", "NOT_FOUND", "team not found")
+		default:
+			respondError(w, "500", "INTERNAL_ERROR", err.Error())
+		}
 		return
 	}
 
@@ -0,0 +1,110 @@
+package api
+
+import (
+	"net/http"
+	"time"
+
+	"pr-reviewer-service/internal/models"
+	"pr-reviewer-service/internal/storage"
+)
+
+func (h *Handler) setMergePolicy(w http.ResponseWriter, r *http.Request) {
+	if !h.isAdmin(r) {
+		respondError(w, "403", "FORBIDDEN", "merge policy changes require admin token")
+		return
+	}
+
+	var in models.MergePolicy
+	if err := decode(r, &in); err != nil || in.TeamName == "" {
+		respondError(w, "400", "BAD_REQUEST", "Invalid request body")
+		return
+	}
+	in.Repository = ""
+
+	if in.MinApprovals < 0 || in.MinAgeMinutes < 0 {
+		respondError(w, "400", "BAD_REQUEST", "min_approvals and min_age_minutes must not be negative")
+		return
+	}
+
+	p, err := h.store.SetMergePolicy(in)
+	if err != nil {
+		if err == storage.ErrNotFound {
+			respondError(w, "404", "NOT_FOUND", "team not found")
+		} else {
+			respondError(w, "500", "INTERNAL_ERROR", err.Error())
+		}
+		return
+	}
+
+	respondJSON(w, 200, map[string]interface{}{"policy": p})
+}
+
+func (h *Handler) getMergePolicy(w http.ResponseWriter, r *http.Request) {
+	teamName := r.URL.Query().Get("team_name")
+	if teamName == "" {
+		respondError(w, "400", "BAD_REQUEST", "team_name is required")
+		return
+	}
+
+	p, err := h.store.GetMergePolicy(teamName)
+	if err != nil {
+		respondError(w, "404", "NOT_FOUND", "merge policy not found")
+		return
+	}
+
+	respondJSON(w, 200, map[string]interface{}{"policy": p})
+}
+
+func (h *Handler) addMergeFreeze(w http.ResponseWriter, r *http.Request) {
+	if !h.isAdmin(r) {
+		respondError(w, "403", "FORBIDDEN", "merge freezes require admin token")
+		return
+	}
+
+	var in struct {
+		TeamName string     `json:"team_name"`
+		StartsAt *time.Time `json:"starts_at"`
+		EndsAt   *time.Time `json:"ends_at"`
+		Reason   string     `json:"reason"`
+	}
+	if err := decode(r, &in); err != nil || in.EndsAt == nil {
+		respondError(w, "400", "BAD_REQUEST", "Invalid request body")
+		return
+	}
+
+	freeze := models.MergeFreeze{
+		TeamName: in.TeamName,
+		StartsAt: time.Now(),
+		EndsAt:   *in.EndsAt,
+		Reason:   in.Reason,
+	}
+	if in.StartsAt != nil {
+		freeze.StartsAt = *in.StartsAt
+	}
+	if !freeze.EndsAt.After(freeze.StartsAt) {
+		respondError(w, "400", "BAD_REQUEST", "ends_at must be after starts_at")
+		return
+	}
+
+	freeze, err := h.store.AddMergeFreeze(freeze)
+	if err != nil {
+		if err == storage.ErrNotFound {
+			respondError(w, "404", "NOT_FOUND", "team not found")
+		} else {
+			respondError(w, "500", "INTERNAL_ERROR", err.Error())
+		}
+		return
+	}
+
+	respondJSON(w, 201, map[string]interface{}{"freeze": freeze})
+}
+
+func (h *Handler) listMergeFreezes(w http.ResponseWriter, r *http.Request) {
+	freezes, err := h.store.ListMergeFreezes(r.URL.Query().Get("team_name"))
+	if err != nil {
+		respondError(w, "500", "INTERNAL_ERROR", "Failed to get merge freezes")
+		return
+	}
+
+	respondJSON(w, 200, map[string]interface{}{"freezes": freezes})
+}
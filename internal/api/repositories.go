@@ -0,0 +1,185 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"pr-reviewer-service/internal/models"
+	"pr-reviewer-service/internal/storage"
+)
+
+// repositoryInput is the body of /repository/add and /repository/update
+type repositoryInput struct {
+	Repository    string         `json:"repository"`
+	OwningTeam    optionalString `json:"owning_team"`
+	ReviewerCount *int           `json:"reviewer_count"`
+	Provider      string         `json:"provider"`
+}
+
+// optionalString tells a field left out of the body from one sent as null
+// or "", which clears the value
+type optionalString struct {
+	Set   bool
+	Value string
+}
+
+func (o *optionalString) UnmarshalJSON(data []byte) error {
+	o.Set = true
+	if string(data) == "null" {
+		o.Value = ""
+		return nil
+	}
+	return json.Unmarshal(data, &o.Value)
+}
+
+func (in repositoryInput) toModel() models.Repository {
+	repo := models.Repository{
+		Name:          in.Repository,
+		OwningTeam:    in.OwningTeam.Value,
+		ReviewerCount: 2,
+		Provider:      in.Provider,
+	}
+	if in.ReviewerCount != nil {
+		repo.ReviewerCount = *in.ReviewerCount
+	}
+	return repo
+}
+
+// validRepositoryProvider accepts the code hosts reviewers can be pushed to;
+// an empty provider keeps reviewers in the service only
+func validRepositoryProvider(provider string) bool {
+	return provider == "" || provider == models.ProviderGitHub || provider == models.ProviderGitLab
+}
+
+func (h *Handler) createRepository(w http.ResponseWriter, r *http.Request) {
+	var in repositoryInput
+	if err := decode(r, &in); err != nil || in.Repository == "" {
+		respondError(w, "400", "BAD_REQUEST", "Invalid request body")
+		return
+	}
+	if in.ReviewerCount != nil && *in.ReviewerCount < 0 {
+		respondError(w, "400", "BAD_REQUEST", "reviewer_count must not be negative")
+		return
+	}
+	if !validRepositoryProvider(in.Provider) {
+		respondError(w, "400", "BAD_REQUEST", "provider must be github or gitlab")
+		return
+	}
+
+	repo, err := h.store.CreateRepository(in.toModel())
+	if err != nil {
+		switch err {
+		case storage.ErrRepoExists:
+			respondError(w, "409", "REPOSITORY_EXISTS", "repository already exists")
+		case storage.ErrNotFound:
+			respondError(w, "404", "NOT_FOUND", "owning team not found")
+		default:
+			respondError(w, "500", "INTERNAL_ERROR", err.Error())
+		}
+		return
+	}
+
+	respondJSON(w, 201, map[string]interface{}{"repository": repo})
+}
+
+func (h *Handler) getRepository(w http.ResponseWriter, r *http.Request) {
+	name := r.URL.Query().Get("repository")
+	if name == "" {
+		respondError(w, "400", "BAD_REQUEST", "repository is required")
+		return
+	}
+
+	repo, err := h.store.GetRepository(name)
+	if err != nil {
+		respondError(w, "404", "NOT_FOUND", "repository not found")
+		return
+	}
+
+	respondJSON(w, 200, map[string]interface{}{"repository": repo})
+}
+
+func (h *Handler) listRepositories(w http.ResponseWriter, r *http.Request) {
+	repos, err := h.store.ListRepositories()
+	if err != nil {
+		respondError(w, "500", "INTERNAL_ERROR", "Failed to get repositories")
+		return
+	}
+
+	respondJSON(w, 200, map[string]interface{}{"repositories": repos})
+}
+
+func (h *Handler) updateRepository(w http.ResponseWriter, r *http.Request) {
+	var in repositoryInput
+	if err := decode(r, &in); err != nil || in.Repository == "" {
+		respondError(w, "400", "BAD_REQUEST", "Invalid request body")
+		return
+	}
+
+	current, err := h.store.GetRepository(in.Repository)
+	if err != nil {
+		respondError(w, "404", "NOT_FOUND", "repository not found")
+		return
+	}
+
+	// Fields left out of the request keep their current values; owning_team
+	// sent as null or "" removes the owner
+	repo := in.toModel()
+	if !in.OwningTeam.Set {
+		repo.OwningTeam = current.OwningTeam
+	}
+	if in.ReviewerCount == nil {
+		repo.ReviewerCount = current.ReviewerCount
+	} else if *in.ReviewerCount < 0 {
+		respondError(w, "400", "BAD_REQUEST", "reviewer_count must not be negative")
+		return
+	}
+	if in.Provider == "" {
+		repo.Provider = current.Provider
+	} else if !validRepositoryProvider(in.Provider) {
+		respondError(w, "400", "BAD_REQUEST", "provider must be github or gitlab")
+		return
+	}
+
+	repo, err = h.store.UpdateRepository(repo)
+	if err != nil {
+		if err == storage.ErrNotFound {
+			respondError(w, "404", "NOT_FOUND", "repository or owning team not found")
+		} else {
+			respondError(w, "500", "INTERNAL_ERROR", err.Error())
+		}
+		return
+	}
+
+	respondJSON(w, 200, map[string]interface{}{"repository": repo})
+}
+
+func (h *Handler) setRepositoryMergePolicy(w http.ResponseWriter, r *http.Request) {
+	if !h.isAdmin(r) {
+		respondError(w, "403", "FORBIDDEN", "merge policy changes require admin token")
+		return
+	}
+
+	var in models.MergePolicy
+	if err := decode(r, &in); err != nil || in.Repository == "" {
+		respondError(w, "400", "BAD_REQUEST", "Invalid request body")
+		return
+	}
+	in.TeamName = ""
+
+	if in.MinApprovals < 0 || in.MinAgeMinutes < 0 {
+		respondError(w, "400", "BAD_REQUEST", "min_approvals and min_age_minutes must not be negative")
+		return
+	}
+
+	p, err := h.store.SetMergePolicy(in)
+	if err != nil {
+		if err == storage.ErrNotFound {
+			respondError(w, "404", "NOT_FOUND", "repository or required team not found")
+		} else {
+			respondError(w, "500", "INTERNAL_ERROR", err.Error())
+		}
+		return
+	}
+
+	respondJSON(w, 200, map[string]interface{}{"policy": p})
+}
@@ -0,0 +1,130 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"pr-reviewer-service/internal/models"
+
+	"github.com/gorilla/mux"
+)
+
+func TestRepositoryScopedPRs(t *testing.T) {
+	store := NewMockStore()
+	store.CreateTeam("backend", []models.User{
+		{UserID: "u1", Username: "Alice", IsActive: true},
+		{UserID: "u2", Username: "Bob", IsActive: true},
+		{UserID: "u3", Username: "Carol", IsActive: true},
+	})
+
+	handler := NewHandler(store)
+	router := mux.NewRouter()
+	handler.RegisterRoutes(router)
+
+	for _, name := range []string{"api", "web"} {
+		rr := doRequest(t, router, "POST", "/repository/add", map[string]interface{}{"repository": name, "owning_team": "backend", "reviewer_count": 1})
+		if rr.Code != http.StatusCreated {
+			t.Fatalf("Expected status 201, got %d", rr.Code)
+		}
+	}
+
+	// The same legacy ID in two repositories must not collide
+	for _, name := range []string{"api", "web"} {
+		rr := doRequest(t, router, "POST", "/pullRequest/create", map[string]interface{}{
+			"pull_request_id":   "pr-1",
+			"pull_request_name": "Test PR",
+			"author_id":         "u1",
+			"repository":        name,
+			"number":            1,
+		})
+		if rr.Code != http.StatusCreated {
+			t.Fatalf("Expected status 201, got %d", rr.Code)
+		}
+
+		var resp struct {
+			PR models.PullRequest `json:"pr"`
+		}
+		json.Unmarshal(rr.Body.Bytes(), &resp)
+		if resp.PR.ID != name+"#1" || resp.PR.Alias != "pr-1" {
+			t.Errorf("Expected %s#1 aliased pr-1, got %s aliased %s", name, resp.PR.ID, resp.PR.Alias)
+		}
+		if len(resp.PR.Reviewers) != 1 {
+			t.Errorf("Expected 1 reviewer, got %d", len(resp.PR.Reviewers))
+		}
+	}
+
+	rr := doRequest(t, router, "POST", "/repository/add", map[string]interface{}{"repository": "api"})
+	if rr.Code != http.StatusConflict {
+		t.Errorf("Expected status 409, got %d", rr.Code)
+	}
+}
+
+func TestRepositoryProviderQueuesReviewerSync(t *testing.T) {
+	store := NewMockStore()
+	store.CreateTeam("backend", []models.User{
+		{UserID: "u1", Username: "Alice", IsActive: true},
+		{UserID: "u2", Username: "Bob", IsActive: true},
+		{UserID: "u3", Username: "Carol", IsActive: true},
+	})
+
+	handler := NewHandler(store)
+	router := mux.NewRouter()
+	handler.RegisterRoutes(router)
+
+	if rr := doRequest(t, router, "POST", "/repository/add", map[string]interface{}{"repository": "acme/api", "owning_team": "backend", "provider": "bitbucket"}); rr.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400 for an unknown provider, got %d", rr.Code)
+	}
+	doRequest(t, router, "POST", "/repository/add", map[string]interface{}{"repository": "acme/api", "owning_team": "backend", "provider": "github"})
+	doRequest(t, router, "POST", "/repository/add", map[string]interface{}{"repository": "local", "owning_team": "backend"})
+
+	var resp struct {
+		PR models.PullRequest `json:"pr"`
+	}
+	rr := doRequest(t, router, "POST", "/pullRequest/create", map[string]interface{}{"pull_request_name": "Test PR", "author_id": "u1", "repository": "acme/api", "number": 1})
+	json.Unmarshal(rr.Body.Bytes(), &resp)
+	if resp.PR.ReviewerSync != models.ReviewerSyncPending {
+		t.Errorf("Expected the reviewers of acme/api#1 to be queued for the code host, got %q", resp.PR.ReviewerSync)
+	}
+
+	resp.PR = models.PullRequest{}
+	rr = doRequest(t, router, "POST", "/pullRequest/create", map[string]interface{}{"pull_request_name": "Test PR", "author_id": "u1", "repository": "local", "number": 1})
+	json.Unmarshal(rr.Body.Bytes(), &resp)
+	if resp.PR.ID != "local#1" || resp.PR.ReviewerSync != "" {
+		t.Errorf("Expected no reviewer sync without a provider, got %q", resp.PR.ReviewerSync)
+	}
+
+	// Updates without provider keep it
+	doRequest(t, router, "POST", "/repository/update", map[string]interface{}{"repository": "acme/api", "reviewer_count": 1})
+	if store.repos["acme/api"].Provider != models.ProviderGitHub {
+		t.Errorf("Expected acme/api to stay on github, got %q", store.repos["acme/api"].Provider)
+	}
+}
+
+func TestUpdateRepositoryClearsOwningTeam(t *testing.T) {
+	store := NewMockStore()
+	store.CreateTeam("backend", []models.User{{UserID: "u1", Username: "Alice", IsActive: true}})
+
+	handler := NewHandler(store)
+	router := mux.NewRouter()
+	handler.RegisterRoutes(router)
+
+	for _, owner := range []interface{}{nil, ""} {
+		doRequest(t, router, "POST", "/repository/add", map[string]interface{}{"repository": "api", "owning_team": "backend"})
+
+		// Leaving owning_team out keeps it
+		doRequest(t, router, "POST", "/repository/update", map[string]interface{}{"repository": "api", "reviewer_count": 1})
+		if got := store.repos["api"].OwningTeam; got != "backend" {
+			t.Fatalf("Expected api to stay owned by backend, got %q", got)
+		}
+
+		rr := doRequest(t, router, "POST", "/repository/update", map[string]interface{}{"repository": "api", "owning_team": owner})
+		if rr.Code != http.StatusOK || store.repos["api"].OwningTeam != "" {
+			t.Errorf("Expected owning_team %#v to clear the owner, got %d %q", owner, rr.Code, store.repos["api"].OwningTeam)
+		}
+		if store.repos["api"].ReviewerCount != 1 {
+			t.Errorf("Expected reviewer_count to be kept, got %d", store.repos["api"].ReviewerCount)
+		}
+		delete(store.repos, "api")
+	}
+}
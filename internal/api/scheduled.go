@@ -0,0 +1,104 @@
+package api
+
+import (
+	"net/http"
+	"time"
+
+	"pr-reviewer-service/internal/models"
+	"pr-reviewer-service/internal/storage"
+)
+
+func (h *Handler) scheduleChange(w http.ResponseWriter, r *http.Request) {
+	var in struct {
+		Kind            string `json:"kind"`
+		UserID          string `json:"user_id"`
+		TeamName        string `json:"team_name"`
+		FromTeam        string `json:"from_team"`
+		ReassignReviews *bool  `json:"reassign_reviews"`
+		RunAt           string `json:"run_at"`
+	}
+	if err := decode(r, &in); err != nil || in.UserID == "" {
+		respondError(w, "400", "BAD_REQUEST", "Invalid request body")
+		return
+	}
+
+	switch in.Kind {
+	case models.ChangeDeactivate, models.ChangeActivate:
+		if in.TeamName != "" || in.FromTeam != "" {
+			respondError(w, "400", "BAD_REQUEST", "team_name and from_team only apply to move_team")
+			return
+		}
+	case models.ChangeMoveTeam:
+		if in.TeamName == "" {
+			respondError(w, "400", "BAD_REQUEST", "team_name is required for move_team")
+			return
+		}
+	default:
+		respondError(w, "400", "BAD_REQUEST", "kind must be deactivate, activate or move_team")
+		return
+	}
+	if in.ReassignReviews != nil && in.Kind != models.ChangeDeactivate {
+		respondError(w, "400", "BAD_REQUEST", "reassign_reviews only applies to deactivate")
+		return
+	}
+	runAt, err := time.Parse(time.RFC3339, in.RunAt)
+	if err != nil {
+		respondError(w, "400", "BAD_REQUEST", "run_at must be an RFC 3339 time")
+		return
+	}
+
+	change, err := h.store.ScheduleChange(models.ScheduledChange{
+		Kind:            in.Kind,
+		UserID:          in.UserID,
+		TeamName:        in.TeamName,
+		FromTeam:        in.FromTeam,
+		ReassignReviews: in.ReassignReviews,
+		RunAt:           runAt,
+	})
+	if err != nil {
+		if err == storage.ErrNotFound {
+			respondError(w, "404", "NOT_FOUND", "user or team not found")
+		} else {
+			respondError(w, "500", "INTERNAL_ERROR", err.Error())
+		}
+		return
+	}
+
+	respondJSON(w, 201, map[string]interface{}{"change": change})
+}
+
+func (h *Handler) listScheduledChanges(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	changes, err := h.store.ListScheduledChanges(q.Get("status"), q.Get("user_id"))
+	if err != nil {
+		respondError(w, "500", "INTERNAL_ERROR", "Failed to get scheduled changes")
+		return
+	}
+
+	respondJSON(w, 200, map[string]interface{}{"changes": changes})
+}
+
+func (h *Handler) cancelScheduledChange(w http.ResponseWriter, r *http.Request) {
+	var in struct {
+		ID int64 `json:"id"`
+	}
+	if err := decode(r, &in); err != nil || in.ID == 0 {
+		respondError(w, "400", "BAD_REQUEST", "Invalid request body")
+		return
+	}
+
+	change, err := h.store.CancelScheduledChange(in.ID)
+	if err != nil {
+		switch err {
+		case storage.ErrNotFound:
+			respondError(w, "404", "NOT_FOUND", "scheduled change not found")
+		case storage.ErrNotPending:
+			respondError(w, "409", "NOT_PENDING", "change was already applied or cancelled")
+		default:
+			respondError(w, "500", "INTERNAL_ERROR", err.Error())
+		}
+		return
+	}
+
+	respondJSON(w, 200, map[string]interface{}{"change": change})
+}
@@ -0,0 +1,585 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"pr-reviewer-service/internal/models"
+	"pr-reviewer-service/internal/storage"
+
+	"github.com/gorilla/mux"
+)
+
+// SCIM 2.0 (RFC 7643/7644) provisioning. Users map onto users (id and
+// userName are the user_id, displayName the username) and Groups onto teams
+// (id and displayName are the team name).
+const (
+	scimUserSchema  = "urn:ietf:params:scim:schemas:core:2.0:User"
+	scimGroupSchema = "urn:ietf:params:scim:schemas:core:2.0:Group"
+	scimListSchema  = "urn:ietf:params:scim:api:messages:2.0:ListResponse"
+	scimErrorSchema = "urn:ietf:params:scim:api:messages:2.0:Error"
+
+	scimContentType  = "application/scim+json"
+	scimDefaultCount = 100
+)
+
+type scimMeta struct {
+	ResourceType string `json:"resourceType"`
+	Location     string `json:"location"`
+}
+
+type scimUser struct {
+	Schemas     []string `json:"schemas"`
+	ID          string   `json:"id,omitempty"`
+	UserName    string   `json:"userName"`
+	DisplayName string   `json:"displayName,omitempty"`
+	Active      *bool    `json:"active,omitempty"`
+	Timezone    string   `json:"timezone,omitempty"`
+	Meta        scimMeta `json:"meta"`
+}
+
+type scimMember struct {
+	Value   string `json:"value"`
+	Display string `json:"display,omitempty"`
+}
+
+type scimGroup struct {
+	Schemas     []string     `json:"schemas"`
+	ID          string       `json:"id,omitempty"`
+	DisplayName string       `json:"displayName"`
+	Members     []scimMember `json:"members"`
+	Meta        scimMeta     `json:"meta"`
+}
+
+type scimPatch struct {
+	Operations []struct {
+		Op    string          `json:"op"`
+		Path  string          `json:"path"`
+		Value json.RawMessage `json:"value"`
+	} `json:"Operations"`
+}
+
+func (h *Handler) registerSCIMRoutes(r *mux.Router) {
+	s := r.PathPrefix("/scim/v2").Subrouter()
+	s.Use(h.scimAuth)
+	s.HandleFunc("/Users", h.scimListUsers).Methods("GET")
+	s.HandleFunc("/Users", h.scimCreateUser).Methods("POST")
+	s.HandleFunc("/Users/{id}", h.scimGetUser).Methods("GET")
+	s.HandleFunc("/Users/{id}", h.scimReplaceUser).Methods("PUT")
+	s.HandleFunc("/Users/{id}", h.scimPatchUser).Methods("PATCH")
+	s.HandleFunc("/Users/{id}", h.scimDeleteUser).Methods("DELETE")
+	s.HandleFunc("/Groups", h.scimListGroups).Methods("GET")
+	s.HandleFunc("/Groups", h.scimCreateGroup).Methods("POST")
+	s.HandleFunc("/Groups/{id}", h.scimGetGroup).Methods("GET")
+	s.HandleFunc("/Groups/{id}", h.scimReplaceGroup).Methods("PUT")
+	s.HandleFunc("/Groups/{id}", h.scimPatchGroup).Methods("PATCH")
+	s.HandleFunc("/Groups/{id}", h.scimDeleteGroup).Methods("DELETE")
+}
+
+func (h *Handler) scimAuth(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if h.scimToken == "" || r.Header.Get("Authorization") != "Bearer "+h.scimToken {
+			scimError(w, http.StatusUnauthorized, "", "missing or invalid bearer token")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func scimJSON(w http.ResponseWriter, status int, data interface{}) {
+	w.Header().Set("Content-Type", scimContentType)
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(data)
+}
+
+func scimError(w http.ResponseWriter, status int, scimType, detail string) {
+	body := map[string]interface{}{
+		"schemas": []string{scimErrorSchema},
+		"status":  strconv.Itoa(status),
+		"detail":  detail,
+	}
+	if scimType != "" {
+		body["scimType"] = scimType
+	}
+	scimJSON(w, status, body)
+}
+
+func scimList(w http.ResponseWriter, resources interface{}, total, startIndex, count int) {
+	scimJSON(w, 200, map[string]interface{}{
+		"schemas":      []string{scimListSchema},
+		"totalResults": total,
+		"startIndex":   startIndex,
+		"itemsPerPage": count,
+		"Resources":    resources,
+	})
+}
+
+var scimFilterPattern = regexp.MustCompile(`^\s*(\w+)\s+eq\s+"([^"]*)"\s*$`)
+
+// parseSCIMFilter supports the `attribute eq "value"` filters identity
+// providers send to look resources up; an empty filter matches everything
+func parseSCIMFilter(filter, attribute string) (string, error) {
+	if filter == "" {
+		return "", nil
+	}
+	m := scimFilterPattern.FindStringSubmatch(filter)
+	if m == nil || !strings.EqualFold(m[1], attribute) {
+		return "", fmt.Errorf("only %s eq \"value\" filters are supported", attribute)
+	}
+	return m[2], nil
+}
+
+// scimPage reads startIndex (1-based) and count. A count above
+// maxUserPageSize is cut down to it, as SCIM lets servers return fewer.
+func scimPage(r *http.Request) (int, int) {
+	startIndex, err := strconv.Atoi(r.URL.Query().Get("startIndex"))
+	if err != nil || startIndex < 1 {
+		startIndex = 1
+	}
+	count, err := strconv.Atoi(r.URL.Query().Get("count"))
+	if err != nil || count < 0 {
+		count = scimDefaultCount
+	}
+	if count > maxUserPageSize {
+		count = maxUserPageSize
+	}
+	return startIndex, count
+}
+
+// Users
+
+func toSCIMUser(u models.User) scimUser {
+	active := u.IsActive
+	return scimUser{
+		Schemas:     []string{scimUserSchema},
+		ID:          u.UserID,
+		UserName:    u.UserID,
+		DisplayName: u.Username,
+		Active:      &active,
+		Timezone:    u.Timezone,
+		Meta:        scimMeta{ResourceType: "User", Location: "/scim/v2/Users/" + u.UserID},
+	}
+}
+
+func (h *Handler) scimListUsers(w http.ResponseWriter, r *http.Request) {
+	userName, err := parseSCIMFilter(r.URL.Query().Get("filter"), "userName")
+	if err != nil {
+		scimError(w, http.StatusBadRequest, "invalidFilter", err.Error())
+		return
+	}
+	startIndex, count := scimPage(r)
+
+	f := models.UserFilter{UserID: userName, Offset: startIndex - 1, Limit: count}
+	resources := []scimUser{}
+	total := 0
+	if count > 0 {
+		var users []models.User
+		users, total, err = h.store.ListUsers(f)
+		if err != nil {
+			scimError(w, http.StatusInternalServerError, "", err.Error())
+			return
+		}
+		for _, u := range users {
+			resources = append(resources, toSCIMUser(u))
+		}
+	} else if _, total, err = h.store.ListUsers(models.UserFilter{UserID: userName, Limit: 1}); err != nil {
+		scimError(w, http.StatusInternalServerError, "", err.Error())
+		return
+	}
+
+	scimList(w, resources, total, startIndex, len(resources))
+}
+
+func (h *Handler) scimGetUser(w http.ResponseWriter, r *http.Request) {
+	user, err := h.store.GetUser(mux.Vars(r)["id"])
+	if err != nil {
+		h.scimStoreError(w, err)
+		return
+	}
+	scimJSON(w, 200, toSCIMUser(user))
+}
+
+func (h *Handler) scimCreateUser(w http.ResponseWriter, r *http.Request) {
+	var in scimUser
+	if err := decode(r, &in); err != nil || in.UserName == "" {
+		scimError(w, http.StatusBadRequest, "invalidValue", "userName is required")
+		return
+	}
+
+	user := models.User{UserID: in.UserName, Username: in.DisplayName, IsActive: in.Active == nil || *in.Active, Timezone: in.Timezone}
+	if user.Username == "" {
+		user.Username = in.UserName
+	}
+	created, err := h.store.CreateUser(user)
+	if err != nil {
+		h.scimStoreError(w, err)
+		return
+	}
+
+	w.Header().Set("Location", "/scim/v2/Users/"+created.UserID)
+	scimJSON(w, http.StatusCreated, toSCIMUser(created))
+}
+
+func (h *Handler) scimReplaceUser(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+	var in scimUser
+	if err := decode(r, &in); err != nil {
+		scimError(w, http.StatusBadRequest, "invalidSyntax", "Invalid request body")
+		return
+	}
+	if in.UserName != "" && in.UserName != id {
+		scimError(w, http.StatusBadRequest, "mutability", "userName cannot be changed")
+		return
+	}
+
+	user, err := h.store.GetUser(id)
+	if err != nil {
+		h.scimStoreError(w, err)
+		return
+	}
+	if in.DisplayName != "" {
+		user.Username = in.DisplayName
+	}
+	user.Timezone = in.Timezone
+	h.scimUpdateUser(w, user, in.Active == nil || *in.Active)
+}
+
+func (h *Handler) scimPatchUser(w http.ResponseWriter, r *http.Request) {
+	var patch scimPatch
+	if err := decode(r, &patch); err != nil {
+		scimError(w, http.StatusBadRequest, "invalidSyntax", "Invalid request body")
+		return
+	}
+
+	user, err := h.store.GetUser(mux.Vars(r)["id"])
+	if err != nil {
+		h.scimStoreError(w, err)
+		return
+	}
+	active := user.IsActive
+
+	for _, op := range patch.Operations {
+		if !strings.EqualFold(op.Op, "replace") && !strings.EqualFold(op.Op, "add") {
+			scimError(w, http.StatusBadRequest, "invalidValue", "unsupported operation "+op.Op)
+			return
+		}
+
+		// Without a path the value holds the attributes to set
+		var attrs struct {
+			DisplayName *string `json:"displayName"`
+			Active      *bool   `json:"active"`
+		}
+		var err error
+		switch strings.ToLower(op.Path) {
+		case "":
+			err = json.Unmarshal(op.Value, &attrs)
+		case "displayname":
+			err = json.Unmarshal(op.Value, &attrs.DisplayName)
+		case "active":
+			err = json.Unmarshal(op.Value, &attrs.Active)
+			// Some providers send "False" as a string
+			if err != nil {
+				var s string
+				if json.Unmarshal(op.Value, &s) == nil {
+					b, perr := strconv.ParseBool(s)
+					attrs.Active, err = &b, perr
+				}
+			}
+		default:
+			scimError(w, http.StatusBadRequest, "invalidPath", "unsupported path "+op.Path)
+			return
+		}
+		if err != nil {
+			scimError(w, http.StatusBadRequest, "invalidValue", "invalid value for "+op.Path)
+			return
+		}
+		if attrs.DisplayName != nil {
+			user.Username = *attrs.DisplayName
+		}
+		if attrs.Active != nil {
+			active = *attrs.Active
+		}
+	}
+
+	h.scimUpdateUser(w, user, active)
+}
+
+// scimUpdateUser saves the user and the active flag together; deactivation
+// hands the user's open reviews over unless their team keeps them
+func (h *Handler) scimUpdateUser(w http.ResponseWriter, user models.User, active bool) {
+	updated, _, err := h.store.UpdateUserActive(user, active)
+	if err != nil {
+		h.scimStoreError(w, err)
+		return
+	}
+
+	scimJSON(w, 200, toSCIMUser(updated))
+}
+
+// scimDeleteUser deactivates the user; PRs keep referring to them, so users
+// are never removed
+func (h *Handler) scimDeleteUser(w http.ResponseWriter, r *http.Request) {
+	if _, err := h.store.DeactivateUser(mux.Vars(r)["id"], nil); err != nil {
+		h.scimStoreError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Groups
+
+func toSCIMGroup(team models.Team) scimGroup {
+	members := []scimMember{}
+	for _, m := range team.Members {
+		members = append(members, scimMember{Value: m.UserID, Display: m.Username})
+	}
+	return scimGroup{
+		Schemas:     []string{scimGroupSchema},
+		ID:          team.Name,
+		DisplayName: team.Name,
+		Members:     members,
+		Meta:        scimMeta{ResourceType: "Group", Location: "/scim/v2/Groups/" + team.Name},
+	}
+}
+
+func (h *Handler) scimListGroups(w http.ResponseWriter, r *http.Request) {
+	name, err := parseSCIMFilter(r.URL.Query().Get("filter"), "displayName")
+	if err != nil {
+		scimError(w, http.StatusBadRequest, "invalidFilter", err.Error())
+		return
+	}
+	startIndex, count := scimPage(r)
+
+	names, err := h.store.ListTeams()
+	if err != nil {
+		scimError(w, http.StatusInternalServerError, "", err.Error())
+		return
+	}
+	if name != "" {
+		var matched []string
+		for _, n := range names {
+			if n == name {
+				matched = append(matched, n)
+			}
+		}
+		names = matched
+	}
+
+	total := len(names)
+	if startIndex-1 < len(names) {
+		names = names[startIndex-1:]
+	} else {
+		names = nil
+	}
+	if len(names) > count {
+		names = names[:count]
+	}
+
+	resources := []scimGroup{}
+	for _, n := range names {
+		team, err := h.store.GetTeam(n)
+		if err != nil {
+			scimError(w, http.StatusInternalServerError, "", err.Error())
+			return
+		}
+		resources = append(resources, toSCIMGroup(team))
+	}
+	scimList(w, resources, total, startIndex, len(resources))
+}
+
+func (h *Handler) scimGetGroup(w http.ResponseWriter, r *http.Request) {
+	team, err := h.store.GetTeam(mux.Vars(r)["id"])
+	if err != nil {
+		h.scimStoreError(w, err)
+		return
+	}
+	scimJSON(w, 200, toSCIMGroup(team))
+}
+
+// scimMembers resolves group members to existing users
+func (h *Handler) scimMembers(members []scimMember) ([]models.User, error) {
+	users := []models.User{}
+	for _, m := range members {
+		u, err := h.store.GetUser(m.Value)
+		if err != nil {
+			return nil, err
+		}
+		users = append(users, u)
+	}
+	return users, nil
+}
+
+// memberSpecs turns group members into a team roster that changes nothing
+// about the users themselves
+func memberSpecs(users []models.User) []models.MemberSpec {
+	specs := make([]models.MemberSpec, 0, len(users))
+	for _, u := range users {
+		specs = append(specs, models.MemberSpec{UserID: u.UserID})
+	}
+	return specs
+}
+
+func (h *Handler) scimCreateGroup(w http.ResponseWriter, r *http.Request) {
+	var in scimGroup
+	if err := decode(r, &in); err != nil || in.DisplayName == "" {
+		scimError(w, http.StatusBadRequest, "invalidValue", "displayName is required")
+		return
+	}
+	members, err := h.scimMembers(in.Members)
+	if err != nil {
+		h.scimStoreError(w, err)
+		return
+	}
+
+	if err := h.store.CreateTeam(in.DisplayName, members); err != nil {
+		h.scimStoreError(w, err)
+		return
+	}
+	team, err := h.store.GetTeam(in.DisplayName)
+	if err != nil {
+		h.scimStoreError(w, err)
+		return
+	}
+
+	w.Header().Set("Location", "/scim/v2/Groups/"+team.Name)
+	scimJSON(w, http.StatusCreated, toSCIMGroup(team))
+}
+
+func (h *Handler) scimReplaceGroup(w http.ResponseWriter, r *http.Request) {
+	name := mux.Vars(r)["id"]
+	var in scimGroup
+	if err := decode(r, &in); err != nil {
+		scimError(w, http.StatusBadRequest, "invalidSyntax", "Invalid request body")
+		return
+	}
+	if _, err := h.store.GetTeam(name); err != nil {
+		h.scimStoreError(w, err)
+		return
+	}
+	members, err := h.scimMembers(in.Members)
+	if err != nil {
+		h.scimStoreError(w, err)
+		return
+	}
+
+	team, err := h.store.UpdateTeam(name, []models.TeamChange{
+		{Op: models.TeamRename, NewName: in.DisplayName},
+		{Op: models.TeamReplaceMembers, Members: memberSpecs(members)},
+	})
+	if err != nil {
+		h.scimStoreError(w, err)
+		return
+	}
+	scimJSON(w, 200, toSCIMGroup(team))
+}
+
+var scimMemberPathPattern = regexp.MustCompile(`^members\[value eq "([^"]+)"\]$`)
+
+// scimPatchGroup checks every operation, members included, and applies them
+// in one storage transaction, so an invalid or failing one leaves the group
+// untouched
+func (h *Handler) scimPatchGroup(w http.ResponseWriter, r *http.Request) {
+	name := mux.Vars(r)["id"]
+	var patch scimPatch
+	if err := decode(r, &patch); err != nil {
+		scimError(w, http.StatusBadRequest, "invalidSyntax", "Invalid request body")
+		return
+	}
+	if _, err := h.store.GetTeam(name); err != nil {
+		h.scimStoreError(w, err)
+		return
+	}
+
+	changes := make([]models.TeamChange, 0, len(patch.Operations))
+	for _, op := range patch.Operations {
+		var change models.TeamChange
+		var members []scimMember
+		path := op.Path
+		if m := scimMemberPathPattern.FindStringSubmatch(path); m != nil {
+			path, members = "members", []scimMember{{Value: m[1]}}
+		} else if strings.EqualFold(path, "members") && len(op.Value) > 0 {
+			if err := json.Unmarshal(op.Value, &members); err != nil {
+				scimError(w, http.StatusBadRequest, "invalidValue", "members must be a list of {value}")
+				return
+			}
+		}
+
+		var users []models.User
+		var err error
+		switch {
+		case strings.EqualFold(op.Op, "add") && strings.EqualFold(path, "members"):
+			change.Op = models.TeamAddMembers
+			users, err = h.scimMembers(members)
+		case strings.EqualFold(op.Op, "remove") && strings.EqualFold(path, "members"):
+			change.Op = models.TeamRemoveMembers
+			for _, m := range members {
+				users = append(users, models.User{UserID: m.Value})
+			}
+		case strings.EqualFold(op.Op, "replace") && strings.EqualFold(path, "members"):
+			change.Op = models.TeamReplaceMembers
+			users, err = h.scimMembers(members)
+		case strings.EqualFold(op.Op, "replace") && (strings.EqualFold(path, "displayName") || path == ""):
+			change.Op = models.TeamRename
+			if strings.EqualFold(path, "displayName") {
+				err = json.Unmarshal(op.Value, &change.NewName)
+			} else {
+				var attrs struct {
+					DisplayName string `json:"displayName"`
+				}
+				err = json.Unmarshal(op.Value, &attrs)
+				change.NewName = attrs.DisplayName
+			}
+			if err != nil {
+				scimError(w, http.StatusBadRequest, "invalidValue", "displayName must be a string")
+				return
+			}
+		default:
+			scimError(w, http.StatusBadRequest, "invalidPath", fmt.Sprintf("unsupported operation %s %s", op.Op, op.Path))
+			return
+		}
+		if err != nil {
+			h.scimStoreError(w, err)
+			return
+		}
+		change.Members = memberSpecs(users)
+		changes = append(changes, change)
+	}
+
+	// Removed members hand their open reviews over
+	team, err := h.store.UpdateTeam(name, changes)
+	if err != nil {
+		h.scimStoreError(w, err)
+		return
+	}
+	scimJSON(w, 200, toSCIMGroup(team))
+}
+
+func (h *Handler) scimDeleteGroup(w http.ResponseWriter, r *http.Request) {
+	if _, err := h.store.DeleteTeam(mux.Vars(r)["id"], ""); err != nil {
+		h.scimStoreError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *Handler) scimStoreError(w http.ResponseWriter, err error) {
+	var busy *storage.TeamBusyError
+	var required *storage.TeamRequiredError
+	switch {
+	case errors.As(err, &busy):
+		scimError(w, http.StatusConflict, "", "members still review open PRs: "+strings.Join(busy.PullRequestIDs, ", "))
+	case errors.As(err, &required):
+		scimError(w, http.StatusConflict, "", "merge policies require a review from the team")
+	case err == storage.ErrNotFound:
+		scimError(w, http.StatusNotFound, "", "resource not found")
+	case err == storage.ErrUserExists, err == storage.ErrTeamExists:
+		scimError(w, http.StatusConflict, "uniqueness", "resource already exists")
+	default:
+		scimError(w, http.StatusInternalServerError, "", err.Error())
+	}
+}
@@ -0,0 +1,195 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"pr-reviewer-service/internal/models"
+
+	"github.com/gorilla/mux"
+)
+
+func TestSCIMUsers(t *testing.T) {
+	store := NewMockStore()
+	store.CreateTeam("backend", []models.User{
+		{UserID: "u1", Username: "Alice", IsActive: true},
+		{UserID: "u2", Username: "Bob", IsActive: true},
+		{UserID: "u3", Username: "Carol", IsActive: true},
+		{UserID: "u4", Username: "Dave", IsActive: true},
+	})
+	store.CreatePR(models.PullRequest{ID: "pr-1", Title: "Test PR", AuthorID: "u1", Status: models.OPEN})
+
+	handler := NewHandler(store, WithSCIMToken("secret"))
+	router := mux.NewRouter()
+	handler.RegisterRoutes(router)
+
+	send := func(method, path, token string, body interface{}) *httptest.ResponseRecorder {
+		return doRequest(t, router, method, path, body, "Content-Type", scimContentType, "Authorization", "Bearer "+token)
+	}
+
+	if rr := send("GET", "/scim/v2/Users", "wrong", nil); rr.Code != http.StatusUnauthorized {
+		t.Fatalf("Expected status 401 without the bearer token, got %d", rr.Code)
+	}
+
+	rr := send("POST", "/scim/v2/Users", "secret", map[string]interface{}{
+		"schemas":     []string{scimUserSchema},
+		"userName":    "u5",
+		"displayName": "Erin",
+	})
+	if rr.Code != http.StatusCreated || rr.Header().Get("Content-Type") != scimContentType {
+		t.Fatalf("Expected status 201 with a SCIM body, got %d %s", rr.Code, rr.Header().Get("Content-Type"))
+	}
+	var created scimUser
+	json.Unmarshal(rr.Body.Bytes(), &created)
+	if created.ID != "u5" || created.Active == nil || !*created.Active {
+		t.Errorf("Expected an active u5, got %+v", created)
+	}
+	if rr := send("POST", "/scim/v2/Users", "secret", map[string]interface{}{"userName": "u5"}); rr.Code != http.StatusConflict {
+		t.Errorf("Expected status 409 for an existing user, got %d", rr.Code)
+	}
+
+	rr = send("GET", `/scim/v2/Users?filter=userName+eq+"u2"`, "secret", nil)
+	var list struct {
+		TotalResults int        `json:"totalResults"`
+		Resources    []scimUser `json:"Resources"`
+	}
+	json.Unmarshal(rr.Body.Bytes(), &list)
+	if list.TotalResults != 1 || len(list.Resources) != 1 || list.Resources[0].DisplayName != "Bob" {
+		t.Errorf("Expected the filter to find Bob, got %+v", list)
+	}
+
+	// Deactivating a reviewer hands their open reviews over
+	reviewer := store.prs["pr-1"].Reviewers[0].UserID
+	rr = send("PATCH", "/scim/v2/Users/"+reviewer, "secret", map[string]interface{}{
+		"Operations": []map[string]interface{}{{"op": "Replace", "path": "active", "value": false}},
+	})
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", rr.Code)
+	}
+	if store.users[reviewer].IsActive || hasReviewer(store.prs["pr-1"], reviewer) {
+		t.Errorf("Expected %s to be deactivated and replaced on pr-1", reviewer)
+	}
+
+	if rr := send("DELETE", "/scim/v2/Users/u5", "secret", nil); rr.Code != http.StatusNoContent || store.users["u5"].IsActive {
+		t.Errorf("Expected DELETE to deactivate u5, got %d", rr.Code)
+	}
+	if rr := send("GET", "/scim/v2/Users/missing", "secret", nil); rr.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404, got %d", rr.Code)
+	}
+
+	// count is capped like /users/list
+	for i := 0; i < maxUserPageSize; i++ {
+		store.CreateUser(models.User{UserID: fmt.Sprintf("bulk-%03d", i), IsActive: true})
+	}
+	rr = send("GET", "/scim/v2/Users?count=100000", "secret", nil)
+	var page struct {
+		TotalResults int        `json:"totalResults"`
+		ItemsPerPage int        `json:"itemsPerPage"`
+		Resources    []scimUser `json:"Resources"`
+	}
+	json.Unmarshal(rr.Body.Bytes(), &page)
+	if page.TotalResults <= maxUserPageSize || page.ItemsPerPage != maxUserPageSize || len(page.Resources) != maxUserPageSize {
+		t.Errorf("Expected a page of %d out of %d users, got %d", maxUserPageSize, page.TotalResults, len(page.Resources))
+	}
+}
+
+func TestSCIMGroups(t *testing.T) {
+	store := NewMockStore()
+	store.CreateTeam("backend", []models.User{
+		{UserID: "u1", Username: "Alice", IsActive: true},
+		{UserID: "u2", Username: "Bob", IsActive: true},
+	})
+	store.CreateUser(models.User{UserID: "u3", Username: "Carol", IsActive: true})
+
+	handler := NewHandler(store, WithSCIMToken("secret"))
+	router := mux.NewRouter()
+	handler.RegisterRoutes(router)
+
+	send := func(method, path string, body interface{}) *httptest.ResponseRecorder {
+		return doRequest(t, router, method, path, body, "Authorization", "Bearer secret")
+	}
+
+	rr := send("POST", "/scim/v2/Groups", map[string]interface{}{
+		"displayName": "frontend",
+		"members":     []map[string]string{{"value": "u3"}},
+	})
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("Expected status 201, got %d", rr.Code)
+	}
+	if rr := send("POST", "/scim/v2/Groups", map[string]interface{}{"displayName": "frontend"}); rr.Code != http.StatusConflict {
+		t.Errorf("Expected status 409 for an existing group, got %d", rr.Code)
+	}
+
+	// An operation that cannot apply fails the PATCH before anything changes
+	rr = send("PATCH", "/scim/v2/Groups/backend", map[string]interface{}{
+		"Operations": []map[string]interface{}{
+			{"op": "remove", "path": `members[value eq "u2"]`},
+			{"op": "add", "path": "members", "value": []map[string]string{{"value": "ghost"}}},
+		},
+	})
+	if rr.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404 for an unknown member, got %d", rr.Code)
+	}
+	rr = send("PATCH", "/scim/v2/Groups/backend", map[string]interface{}{
+		"Operations": []map[string]interface{}{
+			{"op": "remove", "path": `members[value eq "u2"]`},
+			{"op": "move", "path": "members"},
+		},
+	})
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400 for an unsupported operation, got %d", rr.Code)
+	}
+	rr = send("PATCH", "/scim/v2/Groups/backend", map[string]interface{}{
+		"Operations": []map[string]interface{}{
+			{"op": "remove", "path": `members[value eq "u2"]`},
+			{"op": "replace", "path": "displayName", "value": "frontend"},
+		},
+	})
+	if rr.Code != http.StatusConflict {
+		t.Errorf("Expected status 409 for a rename onto an existing group, got %d", rr.Code)
+	}
+	if team, _ := store.GetTeam("backend"); len(team.Members) != 2 {
+		t.Errorf("Expected failed PATCHes to leave backend alone, got %v", team.Members)
+	}
+
+	rr = send("PATCH", "/scim/v2/Groups/backend", map[string]interface{}{
+		"Operations": []map[string]interface{}{
+			{"op": "remove", "path": `members[value eq "u2"]`},
+			{"op": "replace", "path": "displayName", "value": "platform"},
+		},
+	})
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", rr.Code)
+	}
+	var group scimGroup
+	json.Unmarshal(rr.Body.Bytes(), &group)
+	if group.ID != "platform" || len(group.Members) != 1 || group.Members[0].Value != "u1" {
+		t.Errorf("Expected platform with only u1, got %+v", group)
+	}
+
+	rr = send("GET", "/scim/v2/Groups", nil)
+	var list struct {
+		TotalResults int         `json:"totalResults"`
+		Resources    []scimGroup `json:"Resources"`
+	}
+	json.Unmarshal(rr.Body.Bytes(), &list)
+	if list.TotalResults != 2 || list.Resources[0].DisplayName != "frontend" {
+		t.Errorf("Expected frontend and platform, got %+v", list)
+	}
+
+	store.policies["platform"] = models.MergePolicy{TeamName: "platform", RequiredReviewerTeam: "frontend"}
+	if rr := send("DELETE", "/scim/v2/Groups/frontend", nil); rr.Code != http.StatusConflict {
+		t.Errorf("Expected status 409 while a merge policy requires frontend, got %d", rr.Code)
+	}
+	delete(store.policies, "platform")
+
+	if rr := send("DELETE", "/scim/v2/Groups/frontend", nil); rr.Code != http.StatusNoContent {
+		t.Errorf("Expected status 204, got %d", rr.Code)
+	}
+	if _, exists := store.teams["frontend"]; exists {
+		t.Error("Expected frontend to be deleted")
+	}
+}
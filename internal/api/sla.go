@@ -0,0 +1,49 @@
+package api
+
+import (
+	"net/http"
+	"time"
+
+	"pr-reviewer-service/internal/storage"
+)
+
+func (h *Handler) setTeamSLA(w http.ResponseWriter, r *http.Request) {
+	var in struct {
+		TeamName       string   `json:"team_name"`
+		ReviewSLAHours int      `json:"review_sla_hours"`
+		Holidays       []string `json:"holidays"`
+	}
+	if err := decode(r, &in); err != nil || in.TeamName == "" || in.ReviewSLAHours < 0 {
+		respondError(w, "400", "BAD_REQUEST", "Invalid request body")
+		return
+	}
+	for _, day := range in.Holidays {
+		if _, err := time.Parse("2006-01-02", day); err != nil {
+			respondError(w, "400", "BAD_REQUEST", "holidays must be dates in YYYY-MM-DD format")
+			return
+		}
+	}
+
+	team, err := h.store.SetTeamSLA(in.TeamName, in.ReviewSLAHours, in.Holidays)
+	if err != nil {
+		if err == storage.ErrNotFound {
+			respondError(w, "404", "NOT_FOUND", "team not found")
+		} else {
+			respondError(w, "500", "INTERNAL_ERROR", err.Error())
+		}
+		return
+	}
+
+	respondJSON(w, 200, map[string]interface{}{"team": team})
+}
+
+func (h *Handler) listSLABreaches(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	breaches, err := h.store.ListSLABreaches(query.Get("team_name"), query.Get("user_id"))
+	if err != nil {
+		respondError(w, "500", "INTERNAL_ERROR", "Failed to get SLA breaches")
+		return
+	}
+
+	respondJSON(w, 200, map[string]interface{}{"breaches": breaches})
+}
@@ -0,0 +1,215 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+
+	"pr-reviewer-service/internal/models"
+	"pr-reviewer-service/internal/storage"
+
+	"github.com/gorilla/mux"
+)
+
+func (h *Handler) addTeamMember(w http.ResponseWriter, r *http.Request) {
+	var in struct {
+		TeamName string      `json:"team_name"`
+		Member   models.User `json:"member"`
+	}
+	if err := decode(r, &in); err != nil || in.TeamName == "" {
+		respondError(w, "400", "BAD_REQUEST", "Invalid request body")
+		return
+	}
+	if msg := validateMember(in.Member); msg != "" {
+		respondError(w, "400", "BAD_REQUEST", msg)
+		return
+	}
+
+	team, err := h.store.AddTeamMember(in.TeamName, in.Member)
+	if err != nil {
+		switch err {
+		case storage.ErrNotFound:
+			respondError(w, "404", "NOT_FOUND", "team not found")
+		case storage.ErrMemberExists:
+			respondError(w, "409", "MEMBER_EXISTS", "user is already a member of the team")
+		default:
+			respondError(w, "500", "INTERNAL_ERROR", err.Error())
+		}
+		return
+	}
+
+	respondJSON(w, 200, map[string]interface{}{"team": team})
+}
+
+func (h *Handler) removeTeamMember(w http.ResponseWriter, r *http.Request) {
+	var in struct {
+		TeamName string `json:"team_name"`
+		UserID   string `json:"user_id"`
+	}
+	if err := decode(r, &in); err != nil || in.TeamName == "" || in.UserID == "" {
+		respondError(w, "400", "BAD_REQUEST", "Invalid request body")
+		return
+	}
+
+	reassignments, err := h.store.RemoveTeamMember(in.TeamName, in.UserID)
+	if err != nil {
+		if err == storage.ErrNotFound {
+			respondError(w, "404", "NOT_FOUND", "user is not a member of the team")
+		} else {
+			respondError(w, "500", "INTERNAL_ERROR", err.Error())
+		}
+		return
+	}
+
+	respondJSON(w, 200, map[string]interface{}{
+		"team_name":     in.TeamName,
+		"user_id":       in.UserID,
+		"reassignments": reassignments,
+	})
+}
+
+func (h *Handler) renameTeam(w http.ResponseWriter, r *http.Request) {
+	var in struct {
+		TeamName    string `json:"team_name"`
+		NewTeamName string `json:"new_team_name"`
+	}
+	if err := decode(r, &in); err != nil || in.TeamName == "" || in.NewTeamName == "" {
+		respondError(w, "400", "BAD_REQUEST", "Invalid request body")
+		return
+	}
+
+	team, err := h.store.RenameTeam(in.TeamName, in.NewTeamName)
+	if err != nil {
+		switch err {
+		case storage.ErrNotFound:
+			respondError(w, "404", "NOT_FOUND", "team not found")
+		case storage.ErrTeamExists:
+			respondError(w, "409", "TEAM_EXISTS", "new_team_name already exists")
+		default:
+			respondError(w, "500", "INTERNAL_ERROR", err.Error())
+		}
+		return
+	}
+
+	respondJSON(w, 200, map[string]interface{}{"team": team, "old_team_name": in.TeamName})
+}
+
+func (h *Handler) deleteTeam(w http.ResponseWriter, r *http.Request) {
+	name := mux.Vars(r)["name"]
+	moveTo := r.URL.Query().Get("move_to")
+	if moveTo == name {
+		respondError(w, "400", "BAD_REQUEST", "move_to must be another team")
+		return
+	}
+
+	report, err := h.store.DeleteTeam(name, moveTo)
+	if err != nil {
+		var busy *storage.TeamBusyError
+		var required *storage.TeamRequiredError
+		switch {
+		case errors.As(err, &busy):
+			respondJSON(w, http.StatusConflict, map[string]interface{}{
+				"error": map[string]string{
+					"code":    "TEAM_HAS_OPEN_REVIEWS",
+					"message": "members still review open PRs; pass move_to or reassign them first",
+				},
+				"pull_request_ids": busy.PullRequestIDs,
+			})
+		case errors.As(err, &required):
+			respondJSON(w, http.StatusConflict, map[string]interface{}{
+				"error": map[string]string{
+					"code":    "TEAM_REQUIRED_BY_POLICY",
+					"message": "merge policies require a review from the team; pass move_to or change them first",
+				},
+				"merge_policies": required.Policies,
+			})
+		case err == storage.ErrNotFound:
+			respondError(w, "404", "NOT_FOUND", "team or move_to team not found")
+		default:
+			respondError(w, "500", "INTERNAL_ERROR", err.Error())
+		}
+		return
+	}
+
+	respondJSON(w, 200, report)
+}
+
+func (h *Handler) reconcileTeam(w http.ResponseWriter, r *http.Request) {
+	name := mux.Vars(r)["name"]
+	var in struct {
+		Members []models.MemberSpec `json:"members"`
+	}
+	if err := decode(r, &in); err != nil {
+		respondError(w, "400", "BAD_REQUEST", "Invalid request body")
+		return
+	}
+	seen := map[string]bool{}
+	for _, member := range in.Members {
+		if msg := validateMember(models.User{UserID: member.UserID, Timezone: member.Timezone, Role: member.Role}); msg != "" {
+			respondError(w, "400", "BAD_REQUEST", msg)
+			return
+		}
+		if seen[member.UserID] {
+			respondError(w, "400", "BAD_REQUEST", "duplicate user_id "+member.UserID)
+			return
+		}
+		seen[member.UserID] = true
+	}
+
+	dryRun := r.URL.Query().Get("dry_run") == "true"
+	diff, err := h.store.ReconcileTeam(name, in.Members, dryRun)
+	if err != nil {
+		respondError(w, "500", "INTERNAL_ERROR", err.Error())
+		return
+	}
+
+	respondJSON(w, 200, map[string]interface{}{"diff": diff})
+}
+
+func (h *Handler) setTeamParent(w http.ResponseWriter, r *http.Request) {
+	var in struct {
+		TeamName   string `json:"team_name"`
+		ParentTeam string `json:"parent_team"`
+	}
+	if err := decode(r, &in); err != nil || in.TeamName == "" {
+		respondError(w, "400", "BAD_REQUEST", "Invalid request body")
+		return
+	}
+
+	team, err := h.store.SetTeamParent(in.TeamName, in.ParentTeam)
+	if err != nil {
+		switch err {
+		case storage.ErrNotFound:
+			respondError(w, "404", "NOT_FOUND", "team or parent team not found")
+		case storage.ErrTeamCycle:
+			respondError(w, "409", "TEAM_CYCLE", "parent_team is the team itself or one of its sub-teams")
+		default:
+			respondError(w, "500", "INTERNAL_ERROR", err.Error())
+		}
+		return
+	}
+
+	respondJSON(w, 200, map[string]interface{}{"team": team})
+}
+
+func (h *Handler) setTeamKeepReviews(w http.ResponseWriter, r *http.Request) {
+	var in struct {
+		TeamName                string `json:"team_name"`
+		KeepReviewsOnDeactivate bool   `json:"keep_reviews_on_deactivate"`
+	}
+	if err := decode(r, &in); err != nil || in.TeamName == "" {
+		respondError(w, "400", "BAD_REQUEST", "Invalid request body")
+		return
+	}
+
+	team, err := h.store.SetTeamKeepReviews(in.TeamName, in.KeepReviewsOnDeactivate)
+	if err != nil {
+		if err == storage.ErrNotFound {
+			respondError(w, "404", "NOT_FOUND", "team not found")
+		} else {
+			respondError(w, "500", "INTERNAL_ERROR", err.Error())
+		}
+		return
+	}
+
+	respondJSON(w, 200, map[string]interface{}{"team": team})
+}
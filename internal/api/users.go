@@ -0,0 +1,187 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"pr-reviewer-service/internal/models"
+	"pr-reviewer-service/internal/storage"
+)
+
+const (
+	// userHistoryLimit is how many recent assignments /users/get returns
+	userHistoryLimit = 20
+
+	defaultUserPageSize = 50
+	maxUserPageSize     = 500
+)
+
+func (h *Handler) getUser(w http.ResponseWriter, r *http.Request) {
+	userID := r.URL.Query().Get("user_id")
+	if userID == "" {
+		respondError(w, "400", "BAD_REQUEST", "user_id is required")
+		return
+	}
+
+	profile, err := h.store.GetUserProfile(userID, userHistoryLimit)
+	if err == storage.ErrNotFound {
+		respondError(w, "404", "NOT_FOUND", "user not found")
+		return
+	}
+	if err != nil {
+		respondError(w, "500", "INTERNAL_ERROR", err.Error())
+		return
+	}
+
+	respondJSON(w, 200, map[string]interface{}{"user": profile})
+}
+
+func (h *Handler) listUsers(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	f := models.UserFilter{
+		UserID:   q.Get("user_id"),
+		Query:    q.Get("q"),
+		TeamName: q.Get("team_name"),
+		Skill:    q.Get("skill"),
+		Limit:    defaultUserPageSize,
+	}
+
+	if v := q.Get("is_active"); v != "" {
+		active, err := strconv.ParseBool(v)
+		if err != nil {
+			respondError(w, "400", "BAD_REQUEST", "is_active must be true or false")
+			return
+		}
+		f.Active = &active
+	}
+	if v := q.Get("offset"); v != "" {
+		offset, err := strconv.Atoi(v)
+		if err != nil || offset < 0 {
+			respondError(w, "400", "BAD_REQUEST", "offset must be a non-negative integer")
+			return
+		}
+		f.Offset = offset
+	}
+	if v := q.Get("limit"); v != "" {
+		limit, err := strconv.Atoi(v)
+		if err != nil || limit < 1 || limit > maxUserPageSize {
+			respondError(w, "400", "BAD_REQUEST", "limit must be between 1 and "+strconv.Itoa(maxUserPageSize))
+			return
+		}
+		f.Limit = limit
+	}
+
+	users, total, err := h.store.ListUsers(f)
+	if err != nil {
+		respondError(w, "500", "INTERNAL_ERROR", err.Error())
+		return
+	}
+
+	respondJSON(w, 200, map[string]interface{}{
+		"users":  users,
+		"total":  total,
+		"offset": f.Offset,
+		"limit":  f.Limit,
+	})
+}
+
+// updateUser changes only the fields present in the request. An empty
+// ooo_until clears the out-of-office date and an empty skills list removes
+// all skills.
+func (h *Handler) updateUser(w http.ResponseWriter, r *http.Request) {
+	var in struct {
+		UserID   string    `json:"user_id"`
+		Username *string   `json:"username"`
+		Timezone *string   `json:"timezone"`
+		Skills   *[]string `json:"skills"`
+		OOOUntil *string   `json:"ooo_until"`
+	}
+	if err := decode(r, &in); err != nil || in.UserID == "" {
+		respondError(w, "400", "BAD_REQUEST", "Invalid request body")
+		return
+	}
+
+	patch := models.UserPatch{Username: in.Username, Timezone: in.Timezone}
+	if in.Username != nil && *in.Username == "" {
+		respondError(w, "400", "BAD_REQUEST", "username cannot be empty")
+		return
+	}
+	if in.Timezone != nil {
+		if _, err := time.LoadLocation(*in.Timezone); err != nil || *in.Timezone == "" {
+			respondError(w, "400", "BAD_REQUEST", "unknown timezone "+*in.Timezone)
+			return
+		}
+	}
+	if in.Skills != nil {
+		skills := []string{}
+		for _, skill := range *in.Skills {
+			if skill = strings.TrimSpace(skill); skill != "" {
+				skills = append(skills, skill)
+			}
+		}
+		patch.Skills = &skills
+	}
+	if in.OOOUntil != nil {
+		patch.SetOOOUntil = true
+		if *in.OOOUntil != "" {
+			until, err := time.Parse(time.RFC3339, *in.OOOUntil)
+			if err != nil {
+				respondError(w, "400", "BAD_REQUEST", "ooo_until must be an RFC 3339 time")
+				return
+			}
+			patch.OOOUntil = &until
+		}
+	}
+
+	updated, err := h.store.UpdateUser(in.UserID, patch)
+	if err == storage.ErrNotFound {
+		respondError(w, "404", "NOT_FOUND", "user not found")
+		return
+	}
+	if err != nil {
+		respondError(w, "500", "INTERNAL_ERROR", err.Error())
+		return
+	}
+
+	respondJSON(w, 200, map[string]interface{}{"user": updated})
+}
+
+// setUserBot flags a user as a bot, creating the account when it is not
+// known yet, and sets the team that reviews its PRs. A user who becomes a
+// bot gives up their open reviews.
+func (h *Handler) setUserBot(w http.ResponseWriter, r *http.Request) {
+	var in struct {
+		UserID   string `json:"user_id"`
+		Username string `json:"username"`
+		IsBot    *bool  `json:"is_bot"`
+		BotTeam  string `json:"bot_team"`
+	}
+	if err := decode(r, &in); err != nil || in.UserID == "" {
+		respondError(w, "400", "BAD_REQUEST", "Invalid request body")
+		return
+	}
+	isBot := in.IsBot == nil || *in.IsBot
+	if !isBot && in.BotTeam != "" {
+		respondError(w, "400", "BAD_REQUEST", "bot_team requires is_bot")
+		return
+	}
+
+	user, reassignments, err := h.store.SetUserBot(models.User{
+		UserID:   in.UserID,
+		Username: in.Username,
+		IsBot:    isBot,
+		BotTeam:  in.BotTeam,
+	})
+	if err == storage.ErrNotFound {
+		respondError(w, "404", "NOT_FOUND", "user or bot team not found")
+		return
+	}
+	if err != nil {
+		respondError(w, "500", "INTERNAL_ERROR", err.Error())
+		return
+	}
+
+	respondJSON(w, 200, map[string]interface{}{"user": user, "reassignments": reassignments})
+}
@@ -0,0 +1,334 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"pr-reviewer-service/internal/models"
+
+	"github.com/gorilla/mux"
+)
+
+func TestUserProfile(t *testing.T) {
+	store := NewMockStore()
+	store.CreateTeam("backend", []models.User{
+		{UserID: "u1", Username: "Alice", IsActive: true},
+		{UserID: "u2", Username: "Bob", IsActive: true, Role: models.RoleLead},
+		{UserID: "u3", Username: "Carol", IsActive: false},
+	})
+	store.CreatePR(models.PullRequest{ID: "pr-1", Title: "Test PR", AuthorID: "u1", Status: models.OPEN})
+
+	handler := NewHandler(store)
+	router := mux.NewRouter()
+	handler.RegisterRoutes(router)
+
+	rr := doRequest(t, router, "POST", "/users/update", map[string]interface{}{
+		"user_id":   "u2",
+		"username":  "Robert",
+		"skills":    []string{"go", " sql "},
+		"ooo_until": "2999-01-01T00:00:00Z",
+	})
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	if user := store.users["u2"]; user.Username != "Robert" || len(user.Skills) != 2 || user.Skills[1] != "sql" {
+		t.Errorf("Expected the profile to be saved, got %+v", user)
+	}
+	if rr := doRequest(t, router, "POST", "/users/update", map[string]interface{}{"user_id": "u2", "timezone": "Mars/Olympus"}); rr.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400 for an unknown timezone, got %d", rr.Code)
+	}
+	// Fields left out keep their value
+	rr = doRequest(t, router, "POST", "/users/update", map[string]interface{}{"user_id": "u2", "timezone": "Europe/Berlin"})
+	if user := store.users["u2"]; rr.Code != http.StatusOK || user.Timezone != "Europe/Berlin" || user.Username != "Robert" || len(user.Skills) != 2 {
+		t.Errorf("Expected only the timezone to change, got %d %+v", rr.Code, user)
+	}
+	if rr := doRequest(t, router, "POST", "/users/update", map[string]interface{}{"user_id": "missing", "username": "Nobody"}); rr.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404 for an unknown user, got %d", rr.Code)
+	}
+
+	rr = doRequest(t, router, "GET", "/users/get?user_id=u2", nil)
+	var resp struct {
+		User models.UserProfile `json:"user"`
+	}
+	json.Unmarshal(rr.Body.Bytes(), &resp)
+	if !resp.User.OutOfOffice || resp.User.OpenReviews != 1 || len(resp.User.RecentAssignments) != 1 {
+		t.Errorf("Expected u2 to be out of office with one open review, got %+v", resp.User)
+	}
+	if len(resp.User.Teams) != 1 || resp.User.Teams[0].Role != models.RoleLead {
+		t.Errorf("Expected u2 to lead backend, got %+v", resp.User.Teams)
+	}
+	if rr := doRequest(t, router, "GET", "/users/get?user_id=missing", nil); rr.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404, got %d", rr.Code)
+	}
+
+	var list struct {
+		Users []models.User `json:"users"`
+		Total int           `json:"total"`
+	}
+	rr = doRequest(t, router, "GET", "/users/list?team_name=backend&is_active=true&limit=1", nil)
+	json.Unmarshal(rr.Body.Bytes(), &list)
+	if list.Total != 2 || len(list.Users) != 1 || list.Users[0].UserID != "u1" {
+		t.Errorf("Expected the first of two active users, got %+v", list)
+	}
+
+	rr = doRequest(t, router, "GET", "/users/list?skill=go", nil)
+	json.Unmarshal(rr.Body.Bytes(), &list)
+	if list.Total != 1 || list.Users[0].UserID != "u2" {
+		t.Errorf("Expected only u2 to know go, got %+v", list)
+	}
+
+	// q matches part of the user ID or username, ignoring case
+	rr = doRequest(t, router, "GET", "/users/list?q=ROB", nil)
+	json.Unmarshal(rr.Body.Bytes(), &list)
+	if list.Total != 1 || list.Users[0].UserID != "u2" {
+		t.Errorf("Expected only Robert to match, got %+v", list)
+	}
+	rr = doRequest(t, router, "GET", "/users/list?q=u", nil)
+	json.Unmarshal(rr.Body.Bytes(), &list)
+	if list.Total != 3 {
+		t.Errorf("Expected every user to match by ID, got %+v", list)
+	}
+	rr = doRequest(t, router, "GET", "/users/list?user_id=u3", nil)
+	json.Unmarshal(rr.Body.Bytes(), &list)
+	if list.Total != 1 || list.Users[0].UserID != "u3" {
+		t.Errorf("Expected only u3, got %+v", list)
+	}
+
+	if rr := doRequest(t, router, "GET", "/users/list?limit=0", nil); rr.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400 for a zero limit, got %d", rr.Code)
+	}
+}
+
+func TestDeactivateUserHandsOffReviews(t *testing.T) {
+	store := NewMockStore()
+	store.CreateTeam("backend", []models.User{
+		{UserID: "u1", Username: "Alice", IsActive: true},
+		{UserID: "u2", Username: "Bob", IsActive: true},
+		{UserID: "u3", Username: "Carol", IsActive: true},
+		{UserID: "u4", Username: "Dave", IsActive: true},
+	})
+	store.CreatePR(models.PullRequest{ID: "pr-1", Title: "Test PR", AuthorID: "u1", Status: models.OPEN})
+
+	handler := NewHandler(store)
+	router := mux.NewRouter()
+	handler.RegisterRoutes(router)
+
+	var resp struct {
+		ReassignedPRs  []string `json:"reassigned_prs"`
+		NoCandidatePRs []string `json:"no_candidate_prs"`
+	}
+
+	// A team that keeps reviews moves nothing unless the request asks to
+	doRequest(t, router, "POST", "/team/setKeepReviews", map[string]interface{}{"team_name": "backend", "keep_reviews_on_deactivate": true})
+	reviewer := store.prs["pr-1"].Reviewers[0].UserID
+	rr := doRequest(t, router, "POST", "/users/setIsActive", map[string]interface{}{"user_id": reviewer, "is_active": false})
+	json.Unmarshal(rr.Body.Bytes(), &resp)
+	if rr.Code != http.StatusOK || len(resp.ReassignedPRs) != 0 || !hasReviewer(store.prs["pr-1"], reviewer) {
+		t.Fatalf("Expected %s to keep pr-1, got %d %+v", reviewer, rr.Code, resp)
+	}
+
+	doRequest(t, router, "POST", "/users/setIsActive", map[string]interface{}{"user_id": reviewer, "is_active": true})
+	rr = doRequest(t, router, "POST", "/users/setIsActive", map[string]interface{}{"user_id": reviewer, "is_active": false, "reassign_reviews": true})
+	json.Unmarshal(rr.Body.Bytes(), &resp)
+	if len(resp.ReassignedPRs) != 1 || hasReviewer(store.prs["pr-1"], reviewer) {
+		t.Errorf("Expected pr-1 to move away from %s, got %+v", reviewer, resp)
+	}
+
+	// The last free teammate has nobody to hand over to
+	doRequest(t, router, "POST", "/team/setKeepReviews", map[string]interface{}{"team_name": "backend", "keep_reviews_on_deactivate": false})
+	other := store.prs["pr-1"].Reviewers[1].UserID
+	rr = doRequest(t, router, "POST", "/users/setIsActive", map[string]interface{}{"user_id": other, "is_active": false})
+	json.Unmarshal(rr.Body.Bytes(), &resp)
+	if len(resp.NoCandidatePRs) != 1 || resp.NoCandidatePRs[0] != "pr-1" {
+		t.Errorf("Expected pr-1 without a candidate, got %+v", resp)
+	}
+
+	if rr := doRequest(t, router, "POST", "/users/setIsActive", map[string]interface{}{"user_id": "missing", "is_active": false}); rr.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404, got %d", rr.Code)
+	}
+}
+
+func TestScheduledChanges(t *testing.T) {
+	store := NewMockStore()
+	store.CreateTeam("backend", []models.User{
+		{UserID: "u1", Username: "Alice", IsActive: true},
+		{UserID: "u2", Username: "Bob", IsActive: true},
+		{UserID: "u3", Username: "Carol", IsActive: true},
+		{UserID: "u4", Username: "Dave", IsActive: true},
+	})
+	store.CreateTeam("frontend", []models.User{{UserID: "f1", Username: "Frank", IsActive: true}})
+	store.CreatePR(models.PullRequest{ID: "pr-1", Title: "Test PR", AuthorID: "u1", Status: models.OPEN})
+
+	handler := NewHandler(store)
+	router := mux.NewRouter()
+	handler.RegisterRoutes(router)
+
+	past := time.Now().Add(-time.Minute).Format(time.RFC3339)
+	future := time.Now().Add(24 * time.Hour).Format(time.RFC3339)
+
+	if rr := doRequest(t, router, "POST", "/scheduledChanges/add", map[string]interface{}{"kind": "deactivate", "user_id": "u2", "run_at": past}); rr.Code != http.StatusCreated {
+		t.Fatalf("Expected status 201, got %d", rr.Code)
+	}
+	doRequest(t, router, "POST", "/scheduledChanges/add", map[string]interface{}{"kind": "move_team", "user_id": "u3", "team_name": "frontend", "run_at": past})
+	rr := doRequest(t, router, "POST", "/scheduledChanges/add", map[string]interface{}{"kind": "activate", "user_id": "u4", "run_at": future})
+	var resp struct {
+		Change models.ScheduledChange `json:"change"`
+	}
+	json.Unmarshal(rr.Body.Bytes(), &resp)
+
+	if rr := doRequest(t, router, "POST", "/scheduledChanges/add", map[string]interface{}{"kind": "promote", "user_id": "u2", "run_at": past}); rr.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400 for an unknown kind, got %d", rr.Code)
+	}
+	if rr := doRequest(t, router, "POST", "/scheduledChanges/add", map[string]interface{}{"kind": "move_team", "user_id": "u2", "team_name": "missing", "run_at": past}); rr.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404 for an unknown team, got %d", rr.Code)
+	}
+
+	if rr := doRequest(t, router, "POST", "/scheduledChanges/cancel", map[string]interface{}{"id": resp.Change.ID}); rr.Code != http.StatusOK {
+		t.Errorf("Expected status 200, got %d", rr.Code)
+	}
+
+	applied, err := store.ApplyScheduledChanges()
+	if err != nil || len(applied) != 2 {
+		t.Fatalf("Expected the two due changes to be applied, got %+v (%v)", applied, err)
+	}
+	if store.users["u2"].IsActive || hasReviewer(store.prs["pr-1"], "u2") {
+		t.Error("Expected u2 to be deactivated and handed off pr-1")
+	}
+	if store.findUserTeam("u3").Name != "frontend" || len(store.teams["backend"].Members) != 3 {
+		t.Errorf("Expected u3 to move to frontend, got %+v", store.teams)
+	}
+
+	if rr := doRequest(t, router, "POST", "/scheduledChanges/cancel", map[string]interface{}{"id": applied[0].ID}); rr.Code != http.StatusConflict {
+		t.Errorf("Expected status 409 for an applied change, got %d", rr.Code)
+	}
+
+	req := httptest.NewRequest("GET", "/scheduledChanges/list?status=CANCELLED", nil)
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	var list struct {
+		Changes []models.ScheduledChange `json:"changes"`
+	}
+	json.Unmarshal(rr.Body.Bytes(), &list)
+	if len(list.Changes) != 1 || list.Changes[0].UserID != "u4" {
+		t.Errorf("Expected the cancelled activation of u4, got %+v", list.Changes)
+	}
+}
+
+func TestUserAliases(t *testing.T) {
+	store := NewMockStore()
+	store.CreateTeam("backend", []models.User{
+		{UserID: "u1", Username: "Alice", IsActive: true},
+		{UserID: "u2", Username: "Bob", IsActive: true},
+		{UserID: "u3", Username: "Carol", IsActive: true},
+	})
+
+	handler := NewHandler(store)
+	router := mux.NewRouter()
+	handler.RegisterRoutes(router)
+
+	if rr := doRequest(t, router, "POST", "/users/aliases/add", map[string]interface{}{"user_id": "u1", "provider": "github", "external_id": "Alice-GH"}); rr.Code != http.StatusCreated {
+		t.Fatalf("Expected status 201, got %d", rr.Code)
+	}
+	if rr := doRequest(t, router, "POST", "/users/aliases/add", map[string]interface{}{"user_id": "u2", "provider": "github", "external_id": "alice-gh"}); rr.Code != http.StatusConflict {
+		t.Errorf("Expected status 409 for a taken identity, got %d", rr.Code)
+	}
+	if rr := doRequest(t, router, "POST", "/users/aliases/add", map[string]interface{}{"user_id": "u1", "provider": "bitbucket", "external_id": "alice"}); rr.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400 for an unknown provider, got %d", rr.Code)
+	}
+
+	// The GitHub login stands in for the author's user_id
+	rr := doRequest(t, router, "POST", "/pullRequest/create", map[string]interface{}{"pull_request_id": "pr-1", "pull_request_name": "Test PR", "author_id": "alice-gh"})
+	if rr.Code != http.StatusCreated || store.prs["pr-1"].AuthorID != "u1" {
+		t.Fatalf("Expected pr-1 authored by u1, got %d %s", rr.Code, rr.Body.String())
+	}
+
+	// The same identity under two providers for different users is ambiguous
+	doRequest(t, router, "POST", "/users/aliases/add", map[string]interface{}{"user_id": "u1", "provider": "email", "external_id": "dev@example.com"})
+	doRequest(t, router, "POST", "/users/aliases/add", map[string]interface{}{"user_id": "u2", "provider": "gitlab", "external_id": "dev@example.com"})
+	rr = doRequest(t, router, "POST", "/pullRequest/create", map[string]interface{}{"pull_request_id": "pr-2", "pull_request_name": "Test PR", "author_id": "dev@example.com"})
+	if rr.Code != http.StatusConflict {
+		t.Errorf("Expected status 409 for an ambiguous author, got %d", rr.Code)
+	}
+
+	req := httptest.NewRequest("GET", "/users/aliases/list?user_id=u1", nil)
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	var list struct {
+		Aliases []models.UserAlias `json:"aliases"`
+	}
+	json.Unmarshal(rr.Body.Bytes(), &list)
+	if len(list.Aliases) != 2 {
+		t.Errorf("Expected two aliases of u1, got %+v", list.Aliases)
+	}
+
+	if rr := doRequest(t, router, "POST", "/users/aliases/delete", map[string]interface{}{"provider": "github", "external_id": "ALICE-GH"}); rr.Code != http.StatusOK {
+		t.Errorf("Expected status 200, got %d", rr.Code)
+	}
+	if rr := doRequest(t, router, "POST", "/users/aliases/delete", map[string]interface{}{"provider": "github", "external_id": "alice-gh"}); rr.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404 for a deleted alias, got %d", rr.Code)
+	}
+}
+
+func TestBotAccounts(t *testing.T) {
+	store := NewMockStore()
+	store.CreateTeam("backend", []models.User{
+		{UserID: "u1", Username: "Alice", IsActive: true},
+		{UserID: "u2", Username: "Bob", IsActive: true},
+		{UserID: "u3", Username: "Carol", IsActive: true},
+		{UserID: "u6", Username: "Frank", IsActive: true},
+	})
+	store.CreateTeam("platform", []models.User{
+		{UserID: "u4", Username: "Dave", IsActive: true},
+		{UserID: "u5", Username: "Erin", IsActive: true},
+	})
+
+	handler := NewHandler(store)
+	router := mux.NewRouter()
+	handler.RegisterRoutes(router)
+
+	// Without a bot team a teamless bot has nobody to review its PRs
+	if rr := doRequest(t, router, "POST", "/users/setBot", map[string]interface{}{"user_id": "dependabot"}); rr.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", rr.Code)
+	}
+	rr := doRequest(t, router, "POST", "/pullRequest/create", map[string]interface{}{"pull_request_id": "pr-1", "pull_request_name": "Bump deps", "author_id": "dependabot"})
+	if rr.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404 for a bot without a team, got %d", rr.Code)
+	}
+
+	if rr := doRequest(t, router, "POST", "/users/setBot", map[string]interface{}{"user_id": "dependabot", "bot_team": "missing"}); rr.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404 for an unknown bot team, got %d", rr.Code)
+	}
+	doRequest(t, router, "POST", "/users/setBot", map[string]interface{}{"user_id": "dependabot", "bot_team": "platform"})
+	rr = doRequest(t, router, "POST", "/pullRequest/create", map[string]interface{}{"pull_request_id": "pr-1", "pull_request_name": "Bump deps", "author_id": "dependabot"})
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("Expected status 201, got %d %s", rr.Code, rr.Body.String())
+	}
+	if pr := store.prs["pr-1"]; len(pr.Reviewers) != 2 || !hasReviewer(pr, "u4") || !hasReviewer(pr, "u5") {
+		t.Errorf("Expected the platform team to review pr-1, got %+v", pr.Reviewers)
+	}
+
+	// A team member turned bot leaves their reviews and is never picked again
+	store.CreatePR(models.PullRequest{ID: "pr-2", Title: "Test PR", AuthorID: "u1", Status: models.OPEN})
+	rr = doRequest(t, router, "POST", "/users/setBot", map[string]interface{}{"user_id": "u2"})
+	var out struct {
+		Reassignments []models.Reassignment `json:"reassignments"`
+	}
+	json.Unmarshal(rr.Body.Bytes(), &out)
+	if len(out.Reassignments) != 1 || out.Reassignments[0].NewReviewerID != "u6" || hasReviewer(store.prs["pr-2"], "u2") {
+		t.Errorf("Expected u2 handed pr-2 to u6, got %+v", out.Reassignments)
+	}
+	store.CreatePR(models.PullRequest{ID: "pr-3", Title: "Test PR", AuthorID: "u1", Status: models.OPEN})
+	if hasReviewer(store.prs["pr-3"], "u2") {
+		t.Error("Expected the bot u2 not to be picked for pr-3")
+	}
+	if rr := doRequest(t, router, "POST", "/pullRequest/addReviewer", map[string]interface{}{"pull_request_id": "pr-3", "user_id": "u2"}); rr.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400 when adding a bot reviewer, got %d", rr.Code)
+	}
+
+	if rr := doRequest(t, router, "POST", "/users/setBot", map[string]interface{}{"user_id": "ghost", "is_bot": false}); rr.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404 when unflagging an unknown user, got %d", rr.Code)
+	}
+}
@@ -0,0 +1,373 @@
+package api
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"pr-reviewer-service/internal/models"
+	"pr-reviewer-service/internal/storage"
+)
+
+// maxWebhookBody is the largest payload GitHub sends, GitLab ones are smaller
+const maxWebhookBody = 25 << 20
+
+// ignoredEvent acknowledges a delivery that needs no changes, such as a
+// draft PR or a repository that is not registered
+type ignoredEvent struct {
+	reason string
+}
+
+func (e ignoredEvent) Error() string {
+	return e.reason
+}
+
+// deliver runs handle once per delivery ID. Failed deliveries are forgotten
+// so that the code host can redeliver them. A redelivery arriving while an
+// earlier attempt is still running gets a conflict rather than a success,
+// since that attempt may yet fail.
+func (h *Handler) deliver(w http.ResponseWriter, provider, deliveryID, event string, handle func() (map[string]interface{}, error)) {
+	fresh, err := h.store.RecordDelivery(provider, deliveryID, event)
+	if errors.Is(err, storage.ErrDeliveryInProgress) {
+		respondError(w, "409", "DELIVERY_IN_PROGRESS", "delivery is still being handled, retry later")
+		return
+	}
+	if err != nil {
+		respondError(w, "500", "INTERNAL_ERROR", err.Error())
+		return
+	}
+	if !fresh {
+		respondJSON(w, 200, map[string]interface{}{"status": "duplicate"})
+		return
+	}
+
+	result, err := handle()
+	var ignored ignoredEvent
+	if err == nil || errors.As(err, &ignored) {
+		if err := h.store.FinishDelivery(provider, deliveryID); err != nil {
+			respondError(w, "500", "INTERNAL_ERROR", err.Error())
+			return
+		}
+	}
+	switch {
+	case errors.As(err, &ignored):
+		respondJSON(w, 202, map[string]interface{}{"status": "ignored", "reason": ignored.reason})
+	case err != nil:
+		h.store.ForgetDelivery(provider, deliveryID)
+		switch {
+		case errors.Is(err, storage.ErrNotFound), errors.Is(err, storage.ErrNoTeam):
+			respondError(w, "404", "NOT_FOUND", err.Error())
+		case errors.Is(err, storage.ErrPRMerged):
+			respondError(w, "409", "PR_MERGED", err.Error())
+		case errors.Is(err, storage.ErrAliasAmbiguous):
+			respondError(w, "409", "ALIAS_AMBIGUOUS", err.Error())
+		default:
+			respondError(w, "500", "INTERNAL_ERROR", err.Error())
+		}
+	default:
+		respondJSON(w, 200, result)
+	}
+}
+
+// prEvent is a code host PR event reduced to the lifecycle change it asks for
+type prEvent struct {
+	change prChange
+	draft  bool
+	// logins are the author's identities at the provider, tried in order
+	logins []string
+	pr     models.PullRequest
+}
+
+type prChange int
+
+const (
+	prOpen prChange = iota
+	prClose
+	prMerge
+	prReopen
+)
+
+// applyPREvent moves the PR repository#number through its lifecycle. Open
+// creates the PR unless it exists or is a draft; reopening a PR that
+// predates the webhook creates it as well.
+func (h *Handler) applyPREvent(provider string, e prEvent) (models.PullRequest, error) {
+	id := fmt.Sprintf("%s#%d", e.pr.Repository, e.pr.Number)
+
+	var pr models.PullRequest
+	var err error
+	switch e.change {
+	case prClose, prMerge:
+		if e.change == prMerge {
+			pr, err = h.store.RecordExternalMerge(id, provider)
+		} else {
+			pr, err = h.store.ClosePR(id, provider)
+		}
+		if err == storage.ErrNotFound {
+			return pr, ignoredEvent{"unknown PR " + id}
+		}
+		return pr, err
+	case prReopen:
+		pr, err = h.store.ReopenPR(id, provider)
+		if err != storage.ErrNotFound {
+			return pr, err
+		}
+	case prOpen:
+		if _, err := h.store.GetPR(id); err == nil {
+			return models.PullRequest{}, ignoredEvent{"PR already exists"}
+		}
+	}
+	if e.draft {
+		return models.PullRequest{}, ignoredEvent{"draft PR"}
+	}
+
+	if _, err := h.store.GetRepository(e.pr.Repository); err == storage.ErrNotFound {
+		return models.PullRequest{}, ignoredEvent{"repository " + e.pr.Repository + " is not registered"}
+	} else if err != nil {
+		return models.PullRequest{}, err
+	}
+
+	authorID, err := "", storage.ErrNotFound
+	for _, login := range e.logins {
+		if authorID, err = h.store.ResolveUser(provider, login); err != storage.ErrNotFound {
+			break
+		}
+	}
+	if err != nil {
+		return models.PullRequest{}, fmt.Errorf("author %s: %w", strings.Join(e.logins, ", "), err)
+	}
+	e.pr.AuthorID = authorID
+	e.pr.Status = models.OPEN
+	if e.pr.CreatedAt == nil || e.pr.CreatedAt.IsZero() {
+		now := time.Now()
+		e.pr.CreatedAt = &now
+	}
+
+	pr, err = h.store.CreatePR(e.pr)
+	if err == storage.ErrPRExists {
+		return models.PullRequest{}, ignoredEvent{"PR already exists"}
+	}
+	return pr, err
+}
+
+// GitHub
+
+type githubPullRequestEvent struct {
+	Action      string `json:"action"`
+	Number      int    `json:"number"`
+	PullRequest struct {
+		Title        string    `json:"title"`
+		Draft        bool      `json:"draft"`
+		Merged       bool      `json:"merged"`
+		Additions    int       `json:"additions"`
+		Deletions    int       `json:"deletions"`
+		ChangedFiles int       `json:"changed_files"`
+		CreatedAt    time.Time `json:"created_at"`
+		User         struct {
+			Login string `json:"login"`
+		} `json:"user"`
+	} `json:"pull_request"`
+	Repository struct {
+		FullName string `json:"full_name"`
+	} `json:"repository"`
+}
+
+// githubWebhook handles pull_request events. Other events are acknowledged
+// and ignored.
+func (h *Handler) githubWebhook(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxWebhookBody))
+	if err != nil {
+		respondError(w, "400", "BAD_REQUEST", "cannot read body")
+		return
+	}
+	if !validGitHubSignature(h.githubSecret, body, r.Header.Get("X-Hub-Signature-256")) {
+		respondError(w, "401", "UNAUTHORIZED", "missing or invalid X-Hub-Signature-256")
+		return
+	}
+
+	event := r.Header.Get("X-GitHub-Event")
+	switch event {
+	case "ping":
+		respondJSON(w, 200, map[string]interface{}{"status": "pong"})
+		return
+	case "pull_request":
+	default:
+		respondJSON(w, 202, map[string]interface{}{"status": "ignored", "reason": "unsupported event " + event})
+		return
+	}
+
+	var payload githubPullRequestEvent
+	deliveryID := r.Header.Get("X-GitHub-Delivery")
+	if err := json.Unmarshal(body, &payload); err != nil || deliveryID == "" || payload.Repository.FullName == "" {
+		respondError(w, "400", "BAD_REQUEST", "Invalid pull_request payload")
+		return
+	}
+
+	h.deliver(w, models.ProviderGitHub, deliveryID, event, func() (map[string]interface{}, error) {
+		pr, err := h.applyGitHubEvent(payload)
+		if err != nil {
+			return nil, err
+		}
+		return map[string]interface{}{"action": payload.Action, "pr": pr}, nil
+	})
+}
+
+func (h *Handler) applyGitHubEvent(e githubPullRequestEvent) (models.PullRequest, error) {
+	event := prEvent{
+		draft:  e.PullRequest.Draft,
+		logins: []string{e.PullRequest.User.Login},
+		pr: models.PullRequest{
+			Title:        e.PullRequest.Title,
+			CreatedAt:    &e.PullRequest.CreatedAt,
+			Repository:   e.Repository.FullName,
+			Number:       e.Number,
+			LinesAdded:   e.PullRequest.Additions,
+			LinesRemoved: e.PullRequest.Deletions,
+			FilesChanged: e.PullRequest.ChangedFiles,
+		},
+	}
+
+	switch e.Action {
+	case "opened", "ready_for_review":
+		event.change = prOpen
+	case "closed":
+		event.change = prClose
+		if e.PullRequest.Merged {
+			event.change = prMerge
+		}
+	case "reopened":
+		event.change = prReopen
+	default:
+		return models.PullRequest{}, ignoredEvent{"unsupported action " + e.Action}
+	}
+	return h.applyPREvent(models.ProviderGitHub, event)
+}
+
+// validGitHubSignature checks the sha256=<hex HMAC> signature of the body
+func validGitHubSignature(secret string, body []byte, signature string) bool {
+	if secret == "" || !strings.HasPrefix(signature, "sha256=") {
+		return false
+	}
+	got, err := hex.DecodeString(strings.TrimPrefix(signature, "sha256="))
+	if err != nil {
+		return false
+	}
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return hmac.Equal(got, mac.Sum(nil))
+}
+
+// GitLab
+
+type gitlabMergeRequestEvent struct {
+	ObjectKind string `json:"object_kind"`
+	User       struct {
+		ID       int    `json:"id"`
+		Username string `json:"username"`
+	} `json:"user"`
+	Project struct {
+		PathWithNamespace string `json:"path_with_namespace"`
+	} `json:"project"`
+	ObjectAttributes struct {
+		IID            int    `json:"iid"`
+		AuthorID       int    `json:"author_id"`
+		Title          string `json:"title"`
+		Action         string `json:"action"`
+		Draft          bool   `json:"draft"`
+		WorkInProgress bool   `json:"work_in_progress"`
+		CreatedAt      string `json:"created_at"`
+	} `json:"object_attributes"`
+}
+
+// gitlabWebhook handles Merge Request Hook events. Other events are
+// acknowledged and ignored.
+func (h *Handler) gitlabWebhook(w http.ResponseWriter, r *http.Request) {
+	token := r.Header.Get("X-Gitlab-Token")
+	if h.gitlabToken == "" || subtle.ConstantTimeCompare([]byte(token), []byte(h.gitlabToken)) != 1 {
+		respondError(w, "401", "UNAUTHORIZED", "missing or invalid X-Gitlab-Token")
+		return
+	}
+
+	event := r.Header.Get("X-Gitlab-Event")
+	if event != "Merge Request Hook" {
+		respondJSON(w, 202, map[string]interface{}{"status": "ignored", "reason": "unsupported event " + event})
+		return
+	}
+
+	// Retries keep the Idempotency-Key, older GitLab versions only send the
+	// event UUID
+	deliveryID := r.Header.Get("Idempotency-Key")
+	if deliveryID == "" {
+		deliveryID = r.Header.Get("X-Gitlab-Event-UUID")
+	}
+
+	var payload gitlabMergeRequestEvent
+	err := json.NewDecoder(io.LimitReader(r.Body, maxWebhookBody)).Decode(&payload)
+	if err != nil || deliveryID == "" || payload.ObjectKind != "merge_request" || payload.Project.PathWithNamespace == "" {
+		respondError(w, "400", "BAD_REQUEST", "Invalid merge_request payload")
+		return
+	}
+
+	h.deliver(w, models.ProviderGitLab, deliveryID, event, func() (map[string]interface{}, error) {
+		pr, err := h.applyGitLabEvent(payload)
+		if err != nil {
+			return nil, err
+		}
+		return map[string]interface{}{"action": payload.ObjectAttributes.Action, "pr": pr}, nil
+	})
+}
+
+// applyGitLabEvent maps the MR of project path_with_namespace to the PR of
+// the repository with the same name. The hook only has the author's numeric
+// ID, which a gitlab alias can hold; when the author triggered the event
+// their username is tried as well.
+func (h *Handler) applyGitLabEvent(e gitlabMergeRequestEvent) (models.PullRequest, error) {
+	mr := e.ObjectAttributes
+	logins := []string{strconv.Itoa(mr.AuthorID)}
+	if e.User.ID == mr.AuthorID && e.User.Username != "" {
+		logins = append(logins, e.User.Username)
+	}
+	event := prEvent{
+		draft:  mr.Draft || mr.WorkInProgress,
+		logins: logins,
+		pr: models.PullRequest{
+			Title:      mr.Title,
+			CreatedAt:  parseGitLabTime(mr.CreatedAt),
+			Repository: e.Project.PathWithNamespace,
+			Number:     mr.IID,
+		},
+	}
+
+	switch mr.Action {
+	case "open", "update":
+		event.change = prOpen
+	case "close":
+		event.change = prClose
+	case "merge":
+		event.change = prMerge
+	case "reopen":
+		event.change = prReopen
+	default:
+		return models.PullRequest{}, ignoredEvent{"unsupported action " + mr.Action}
+	}
+	return h.applyPREvent(models.ProviderGitLab, event)
+}
+
+// parseGitLabTime reads both the ISO 8601 timestamps of current GitLab
+// versions and the "2006-01-02 15:04:05 UTC" ones of older versions
+func parseGitLabTime(value string) *time.Time {
+	for _, layout := range []string{time.RFC3339, "2006-01-02 15:04:05 MST", "2006-01-02 15:04:05 -0700"} {
+		if t, err := time.Parse(layout, value); err == nil {
+			return &t
+		}
+	}
+	return nil
+}
@@ -0,0 +1,209 @@
+package api
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"pr-reviewer-service/internal/models"
+
+	"github.com/gorilla/mux"
+)
+
+func TestGitHubWebhook(t *testing.T) {
+	store := NewMockStore()
+	store.CreateTeam("backend", []models.User{
+		{UserID: "u1", Username: "Alice", IsActive: true},
+		{UserID: "u2", Username: "Bob", IsActive: true},
+		{UserID: "u3", Username: "Carol", IsActive: true},
+	})
+	store.CreateRepository(models.Repository{Name: "acme/api", OwningTeam: "backend", ReviewerCount: 2})
+	store.AddUserAlias(models.UserAlias{UserID: "u1", Provider: models.ProviderGitHub, ExternalID: "alice-gh"})
+
+	handler := NewHandler(store, WithGitHubWebhookSecret("secret"))
+	router := mux.NewRouter()
+	handler.RegisterRoutes(router)
+
+	// deliver replays a recorded payload signed with key
+	deliver := func(fixture, deliveryID, key string) *httptest.ResponseRecorder {
+		body, err := os.ReadFile("testdata/github/" + fixture)
+		if err != nil {
+			t.Fatal(err)
+		}
+		mac := hmac.New(sha256.New, []byte(key))
+		mac.Write(body)
+		req := httptest.NewRequest("POST", "/webhooks/github", bytes.NewReader(body))
+		req.Header.Set("X-GitHub-Event", "pull_request")
+		req.Header.Set("X-GitHub-Delivery", deliveryID)
+		req.Header.Set("X-Hub-Signature-256", "sha256="+hex.EncodeToString(mac.Sum(nil)))
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		return rr
+	}
+	status := func(rr *httptest.ResponseRecorder) string {
+		var out struct {
+			Status string `json:"status"`
+		}
+		json.Unmarshal(rr.Body.Bytes(), &out)
+		return out.Status
+	}
+
+	if rr := deliver("pull_request_opened.json", "d-1", "wrong"); rr.Code != http.StatusUnauthorized {
+		t.Fatalf("Expected status 401 for a bad signature, got %d", rr.Code)
+	}
+
+	rr := deliver("pull_request_opened.json", "d-1", "secret")
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d %s", rr.Code, rr.Body.String())
+	}
+	pr := store.prs["acme/api#42"]
+	if pr.AuthorID != "u1" || pr.LinesAdded != 120 || len(pr.Reviewers) != 2 || hasReviewer(pr, "u1") {
+		t.Errorf("Expected acme/api#42 by u1 with two reviewers, got %+v", pr)
+	}
+	if rr := deliver("pull_request_opened.json", "d-1", "secret"); rr.Code != http.StatusOK || status(rr) != "duplicate" {
+		t.Errorf("Expected a redelivery to be skipped, got %d %s", rr.Code, rr.Body.String())
+	}
+	// A redelivery racing the first attempt is not acknowledged, since that
+	// attempt may still fail
+	store.RecordDelivery(models.ProviderGitHub, "d-0", "pull_request")
+	if rr := deliver("pull_request_opened.json", "d-0", "secret"); rr.Code != http.StatusConflict {
+		t.Errorf("Expected status 409 for a delivery still being handled, got %d", rr.Code)
+	}
+
+	// Drafts wait for ready_for_review
+	if rr := deliver("pull_request_opened_draft.json", "d-2", "secret"); rr.Code != http.StatusAccepted || status(rr) != "ignored" {
+		t.Errorf("Expected a draft to be ignored, got %d", rr.Code)
+	}
+	if rr := deliver("pull_request_ready_for_review.json", "d-3", "secret"); rr.Code != http.StatusOK {
+		t.Errorf("Expected status 200, got %d", rr.Code)
+	}
+	if _, exists := store.prs["acme/api#43"]; !exists {
+		t.Error("Expected acme/api#43 to be created once ready for review")
+	}
+
+	deliver("pull_request_closed.json", "d-4", "secret")
+	if store.prs["acme/api#42"].Status != models.CLOSED {
+		t.Errorf("Expected acme/api#42 to be closed, got %s", store.prs["acme/api#42"].Status)
+	}
+	// A PR closed on GitHub cannot be merged here
+	if rr := doRequest(t, router, "POST", "/pullRequest/merge", map[string]interface{}{"pull_request_id": "acme/api#42"}); rr.Code != http.StatusConflict {
+		t.Errorf("Expected status 409 for merging a closed PR, got %d", rr.Code)
+	}
+	deliver("pull_request_reopened.json", "d-5", "secret")
+	if store.prs["acme/api#42"].Status != models.OPEN {
+		t.Errorf("Expected acme/api#42 to be reopened, got %s", store.prs["acme/api#42"].Status)
+	}
+	// A merge made on GitHub is recorded even for a stacked PR whose parent
+	// is still open
+	store.CreatePR(models.PullRequest{ID: "parent", Title: "Base", AuthorID: "u2", Status: models.OPEN})
+	pr = store.prs["acme/api#42"]
+	pr.DependsOn = "parent"
+	store.prs["acme/api#42"] = pr
+	if rr := deliver("pull_request_closed_merged.json", "d-6", "secret"); rr.Code != http.StatusOK {
+		t.Errorf("Expected status 200, got %d %s", rr.Code, rr.Body.String())
+	}
+	if store.prs["acme/api#42"].Status != models.MERGED {
+		t.Errorf("Expected acme/api#42 to be merged, got %s", store.prs["acme/api#42"].Status)
+	}
+
+	// A failed delivery can be redelivered once the author is known
+	store.aliases = nil
+	delete(store.prs, "acme/api#42")
+	if rr := deliver("pull_request_opened.json", "d-7", "secret"); rr.Code != http.StatusNotFound {
+		t.Fatalf("Expected status 404 for an unknown author, got %d", rr.Code)
+	}
+	store.AddUserAlias(models.UserAlias{UserID: "u1", Provider: models.ProviderGitHub, ExternalID: "alice-gh"})
+	if rr := deliver("pull_request_opened.json", "d-7", "secret"); rr.Code != http.StatusOK {
+		t.Errorf("Expected the redelivery to succeed, got %d %s", rr.Code, rr.Body.String())
+	}
+}
+
+func TestGitLabWebhook(t *testing.T) {
+	store := NewMockStore()
+	store.CreateTeam("frontend", []models.User{
+		{UserID: "u1", Username: "Alice", IsActive: true},
+		{UserID: "u2", Username: "Bob", IsActive: true},
+		{UserID: "u3", Username: "Carol", IsActive: true},
+	})
+	store.CreateRepository(models.Repository{Name: "acme/web", OwningTeam: "frontend", ReviewerCount: 2})
+	store.AddUserAlias(models.UserAlias{UserID: "u1", Provider: models.ProviderGitLab, ExternalID: "alice.gl"})
+
+	handler := NewHandler(store, WithGitLabWebhookToken("secret"))
+	router := mux.NewRouter()
+	handler.RegisterRoutes(router)
+
+	deliver := func(fixture, deliveryID, token string) *httptest.ResponseRecorder {
+		body, err := os.ReadFile("testdata/gitlab/" + fixture)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req := httptest.NewRequest("POST", "/webhooks/gitlab", bytes.NewReader(body))
+		req.Header.Set("X-Gitlab-Event", "Merge Request Hook")
+		req.Header.Set("X-Gitlab-Event-UUID", deliveryID)
+		req.Header.Set("X-Gitlab-Token", token)
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		return rr
+	}
+
+	if rr := deliver("merge_request_open.json", "e-1", "wrong"); rr.Code != http.StatusUnauthorized {
+		t.Fatalf("Expected status 401 for a bad token, got %d", rr.Code)
+	}
+
+	if rr := deliver("merge_request_open.json", "e-1", "secret"); rr.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d %s", rr.Code, rr.Body.String())
+	}
+	pr := store.prs["acme/web#17"]
+	if pr.AuthorID != "u1" || len(pr.Reviewers) != 2 || pr.CreatedAt == nil || pr.CreatedAt.Day() != 12 {
+		t.Errorf("Expected acme/web#17 by u1 with two reviewers, got %+v", pr)
+	}
+	if rr := deliver("merge_request_open.json", "e-1", "secret"); rr.Code != http.StatusOK || !bytes.Contains(rr.Body.Bytes(), []byte("duplicate")) {
+		t.Errorf("Expected a redelivery to be skipped, got %d %s", rr.Code, rr.Body.String())
+	}
+
+	// A draft is created by the update that marks it ready
+	if rr := deliver("merge_request_open_draft.json", "e-2", "secret"); rr.Code != http.StatusAccepted {
+		t.Errorf("Expected a draft to be ignored, got %d", rr.Code)
+	}
+	if rr := deliver("merge_request_update.json", "e-3", "secret"); rr.Code != http.StatusOK {
+		t.Errorf("Expected status 200, got %d %s", rr.Code, rr.Body.String())
+	}
+	if pr, exists := store.prs["acme/web#18"]; !exists || pr.Title != "Dark mode" {
+		t.Errorf("Expected acme/web#18 to be created once ready, got %+v", pr)
+	}
+	if rr := deliver("merge_request_update.json", "e-4", "secret"); rr.Code != http.StatusAccepted {
+		t.Errorf("Expected an update of a known MR to be ignored, got %d", rr.Code)
+	}
+
+	deliver("merge_request_close.json", "e-5", "secret")
+	if store.prs["acme/web#17"].Status != models.CLOSED {
+		t.Errorf("Expected acme/web#17 to be closed, got %s", store.prs["acme/web#17"].Status)
+	}
+	deliver("merge_request_reopen.json", "e-6", "secret")
+	if store.prs["acme/web#17"].Status != models.OPEN {
+		t.Errorf("Expected acme/web#17 to be reopened, got %s", store.prs["acme/web#17"].Status)
+	}
+	deliver("merge_request_merge.json", "e-7", "secret")
+	if store.prs["acme/web#17"].Status != models.MERGED {
+		t.Errorf("Expected acme/web#17 to be merged, got %s", store.prs["acme/web#17"].Status)
+	}
+
+	// An edit by someone else resolves the author through their numeric ID
+	store.AddUserAlias(models.UserAlias{UserID: "u2", Provider: models.ProviderGitLab, ExternalID: "bob.gl"})
+	if rr := deliver("merge_request_update_by_reviewer.json", "e-8", "secret"); rr.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404 without an alias for author 31, got %d %s", rr.Code, rr.Body.String())
+	}
+	store.AddUserAlias(models.UserAlias{UserID: "u1", Provider: models.ProviderGitLab, ExternalID: "31"})
+	if rr := deliver("merge_request_update_by_reviewer.json", "e-8", "secret"); rr.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d %s", rr.Code, rr.Body.String())
+	}
+	if pr := store.prs["acme/web#19"]; pr.AuthorID != "u1" || !hasReviewer(pr, "u2") {
+		t.Errorf("Expected acme/web#19 by u1 with the editor u2 as a reviewer, got %+v", pr)
+	}
+}
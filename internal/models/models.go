@@ -9,11 +9,166 @@ type User struct {
 	Username string `db:"username" json:"username"`
 	IsActive bool   `db:"is_active" json:"is_active"`
 	TeamName string `db:"team_name" json:"team_name,omitempty"`
+	Timezone string `db:"timezone" json:"timezone,omitempty"`
+	Role     string `db:"role" json:"role,omitempty"`
+
+	// Profile fields
+	Skills   []string   `json:"skills,omitempty"`
+	OOOUntil *time.Time `db:"ooo_until" json:"ooo_until,omitempty"`
+
+	// Bots author PRs but are never picked as reviewers. BotTeam reviews
+	// their PRs.
+	IsBot   bool   `db:"is_bot" json:"is_bot,omitempty"`
+	BotTeam string `db:"bot_team" json:"bot_team,omitempty"`
+}
+
+// UserProfile is a user with every team membership, their open review load
+// and most recent assignments
+type UserProfile struct {
+	User
+	Teams             []Membership       `json:"teams"`
+	OpenReviews       int                `json:"open_reviews"`
+	OutOfOffice       bool               `json:"out_of_office"`
+	RecentAssignments []AssignmentRecord `json:"recent_assignments"`
+}
+
+type Membership struct {
+	TeamName string `db:"team_name" json:"team_name"`
+	Role     string `db:"role" json:"role"`
 }
 
+// AssignmentRecord is one review assignment of a user
+type AssignmentRecord struct {
+	PullRequestID string      `db:"pull_request_id" json:"pull_request_id"`
+	Title         string      `db:"pull_request_name" json:"pull_request_name"`
+	Status        PRStatus    `db:"status" json:"status"`
+	State         ReviewState `db:"state" json:"state"`
+	AssignedAt    time.Time   `db:"assigned_at" json:"assigned_at"`
+}
+
+// UserFilter narrows user listings; zero values match everything. A zero
+// Limit means no limit.
+type UserFilter struct {
+	UserID   string
+	Query    string // part of the user ID or username, case-insensitive
+	TeamName string
+	Active   *bool
+	Skill    string
+	Offset   int
+	Limit    int
+}
+
+// UserPatch lists the profile fields an update changes; nil fields keep
+// their stored value. SetOOOUntil applies OOOUntil, where nil clears the
+// out-of-office date.
+type UserPatch struct {
+	Username    *string
+	Timezone    *string
+	Skills      *[]string
+	SetOOOUntil bool
+	OOOUntil    *time.Time
+}
+
+// Team member roles. Leads receive escalations; observers are never picked
+// as reviewers automatically but can be added by hand.
+const (
+	RoleMember   = "member"
+	RoleLead     = "lead"
+	RoleObserver = "observer"
+)
+
 type Team struct {
-	Name    string `db:"name" json:"team_name"`
-	Members []User `json:"members"`
+	Name           string     `db:"name" json:"team_name"`
+	Members        []User     `json:"members"`
+	SizeRules      []SizeRule `json:"size_rules,omitempty"`
+	ReviewSLAHours int        `db:"review_sla_hours" json:"review_sla_hours,omitempty"`
+	Holidays       []string   `json:"holidays,omitempty"`
+
+	EscalationMinutes int    `db:"escalation_minutes" json:"escalation_minutes,omitempty"`
+	EscalationUserID  string `db:"escalation_user_id" json:"escalation_user_id,omitempty"`
+
+	// KeepReviewsOnDeactivate leaves a deactivated member on their open
+	// reviews instead of handing them over
+	KeepReviewsOnDeactivate bool `db:"keep_reviews_on_deactivate" json:"keep_reviews_on_deactivate,omitempty"`
+
+	// ParentTeam places the team in the org tree; Path lists its ancestors
+	// from the root down to the parent
+	ParentTeam string   `db:"parent_team" json:"parent_team,omitempty"`
+	Children   []string `json:"children,omitempty"`
+	Path       []string `json:"path,omitempty"`
+}
+
+// MemberSpec is a member of a declarative team update. An empty Username or
+// Role and a nil IsActive keep what is stored; new users are active unless
+// IsActive says otherwise.
+type MemberSpec struct {
+	UserID   string `json:"user_id"`
+	Username string `json:"username,omitempty"`
+	IsActive *bool  `json:"is_active,omitempty"`
+	Timezone string `json:"timezone,omitempty"`
+	Role     string `json:"role,omitempty"`
+}
+
+// Steps of an atomic team update
+const (
+	TeamAddMembers     = "add"
+	TeamRemoveMembers  = "remove"
+	TeamReplaceMembers = "replace"
+	TeamRename         = "rename"
+)
+
+// TeamChange is one step of an atomic team update: existing users added to
+// or removed from the team, the members replaced as by a declarative
+// update, or the team renamed to NewName
+type TeamChange struct {
+	Op      string
+	Members []MemberSpec
+	NewName string
+}
+
+// TeamDiff describes what a declarative team update changed (or would
+// change, on a dry run)
+type TeamDiff struct {
+	TeamName      string         `json:"team_name"`
+	DryRun        bool           `json:"dry_run"`
+	Created       bool           `json:"created"`
+	Added         []string       `json:"added"`
+	Removed       []string       `json:"removed"`
+	Updated       []MemberChange `json:"updated"`
+	Reassignments []Reassignment `json:"reassignments"`
+
+	// Set by roster imports only
+	ParentTeam       *FieldChange `json:"parent_team,omitempty"`
+	SettingsReplaced bool         `json:"settings_replaced,omitempty"`
+}
+
+// ImportResult is the outcome of a roster import (or of its dry run)
+type ImportResult struct {
+	DryRun       bool           `json:"dry_run"`
+	UsersCreated []string       `json:"users_created"`
+	UsersUpdated []MemberChange `json:"users_updated"`
+	Teams        []TeamDiff     `json:"teams"`
+	// Reviews handed over by users the roster deactivates
+	Reassignments []Reassignment `json:"reassignments"`
+}
+
+// MemberChange lists the changed fields of a member kept in the team
+type MemberChange struct {
+	UserID  string        `json:"user_id"`
+	Changes []FieldChange `json:"changes"`
+}
+
+type FieldChange struct {
+	Field string      `json:"field"`
+	Old   interface{} `json:"old"`
+	New   interface{} `json:"new"`
+}
+
+// SizeRule assigns ReviewerCount reviewers to PRs with more than MinLines
+// added plus removed lines.
+type SizeRule struct {
+	MinLines      int `db:"min_lines" json:"min_lines"`
+	ReviewerCount int `db:"reviewer_count" json:"reviewer_count"`
 }
 
 type PRStatus string
@@ -21,21 +176,228 @@ type PRStatus string
 const (
 	OPEN   PRStatus = "OPEN"
 	MERGED PRStatus = "MERGED"
+	// CLOSED PRs were closed on the code host without merging
+	CLOSED PRStatus = "CLOSED"
+)
+
+type PRPriority string
+
+const (
+	PriorityLow      PRPriority = "LOW"
+	PriorityMedium   PRPriority = "MEDIUM"
+	PriorityHigh     PRPriority = "HIGH"
+	PriorityCritical PRPriority = "CRITICAL"
 )
 
+func (p PRPriority) Valid() bool {
+	switch p {
+	case PriorityLow, PriorityMedium, PriorityHigh, PriorityCritical:
+		return true
+	}
+	return false
+}
+
 type PullRequest struct {
 	ID               string    `db:"pull_request_id" json:"pull_request_id"`
 	Title            string    `db:"pull_request_name" json:"pull_request_name"`
 	AuthorID         string    `db:"author_id" json:"author_id"`
 	Status           PRStatus  `db:"status" json:"status"`
 	Reviewers        []User    `json:"assigned_reviewers"`
+	Reviews          []Review  `json:"reviews,omitempty"`
 	CreatedAt        *time.Time `db:"created_at" json:"createdAt,omitempty"`
 	MergedAt         *time.Time `db:"merged_at" json:"mergedAt,omitempty"`
+	ClosedAt         *time.Time `db:"closed_at" json:"closedAt,omitempty"`
+	Repository       string    `db:"repository" json:"repository,omitempty"`
+	Number           int       `db:"number" json:"number,omitempty"`
+	Alias            string    `db:"alias" json:"alias,omitempty"`
+	LinesAdded       int       `db:"lines_added" json:"lines_added"`
+	LinesRemoved     int       `db:"lines_removed" json:"lines_removed"`
+	FilesChanged     int       `db:"files_changed" json:"files_changed"`
+	Priority         PRPriority `db:"priority" json:"priority"`
+	DependsOn        string    `db:"depends_on" json:"depends_on,omitempty"`
+	// ReviewerSync tells whether the reviewers reached the code host
+	ReviewerSync      string `db:"reviewer_sync" json:"reviewer_sync,omitempty"`
+	ReviewerSyncError string `db:"reviewer_sync_error" json:"reviewer_sync_error,omitempty"`
+	// DependencyChain lists the ancestors from the direct parent to the stack root
+	DependencyChain  []PullRequestShort `json:"dependency_chain,omitempty"`
 }
 
 type PullRequestShort struct {
-	ID       string   `json:"pull_request_id"`
-	Title    string   `json:"pull_request_name"`
-	AuthorID string   `json:"author_id"`
-	Status   PRStatus `json:"status"`
-}
\ No newline at end of file
+	ID       string     `db:"pull_request_id" json:"pull_request_id"`
+	Title    string     `db:"pull_request_name" json:"pull_request_name"`
+	AuthorID string     `db:"author_id" json:"author_id"`
+	Status   PRStatus   `db:"status" json:"status"`
+	Priority PRPriority `db:"priority" json:"priority,omitempty"`
+}
+type ReviewState string
+
+const (
+	ReviewPending          ReviewState = "PENDING"
+	ReviewApproved         ReviewState = "APPROVED"
+	ReviewChangesRequested ReviewState = "CHANGES_REQUESTED"
+)
+
+type Review struct {
+	UserID     string      `db:"user_id" json:"user_id"`
+	State      ReviewState `db:"state" json:"state"`
+	ReviewedAt *time.Time  `db:"reviewed_at" json:"reviewed_at,omitempty"`
+	AssignedAt *time.Time  `db:"assigned_at" json:"assigned_at,omitempty"`
+	AcceptedAt *time.Time  `db:"accepted_at" json:"accepted_at,omitempty"`
+	DueAt      *time.Time  `db:"due_at" json:"due_at,omitempty"`
+}
+
+// Reassignment describes one reviewer hand-over. NewReviewerID is empty and
+// Reason explains why when no replacement could be found.
+type Reassignment struct {
+	PullRequestID string `json:"pull_request_id"`
+	OldReviewerID string `json:"old_reviewer_id"`
+	NewReviewerID string `json:"new_reviewer_id,omitempty"`
+	Reason        string `json:"reason,omitempty"`
+}
+
+// Identity providers of user aliases
+const (
+	ProviderGitHub = "github"
+	ProviderGitLab = "gitlab"
+	ProviderEmail  = "email"
+)
+
+// UserAlias maps an external identity, e.g. a GitHub login, to a user
+type UserAlias struct {
+	Provider   string    `db:"provider" json:"provider"`
+	ExternalID string    `db:"external_id" json:"external_id"`
+	UserID     string    `db:"user_id" json:"user_id"`
+	CreatedAt  time.Time `db:"created_at" json:"created_at"`
+}
+
+// Reviewer sync statuses of a PR
+const (
+	ReviewerSyncPending = "PENDING"
+	ReviewerSyncSynced  = "SYNCED"
+	ReviewerSyncFailed  = "FAILED"
+)
+
+// ReviewerSyncTask is an open PR whose reviewers wait to be pushed to the
+// code host. Status, Error and NextAttempt carry the outcome back.
+type ReviewerSyncTask struct {
+	PullRequestID string    `db:"pull_request_id"`
+	Repository    string    `db:"repository"`
+	Number        int       `db:"number"`
+	Provider      string    `db:"provider"`
+	Attempts      int       `db:"reviewer_sync_attempts"`
+	QueuedAt      time.Time `db:"reviewer_sync_queued_at"`
+
+	// Logins are the reviewers' identities at the provider, Missing the
+	// reviewers without one. Pushed are the logins of the last successful
+	// push; only those are ever withdrawn from the code host, and GitHub
+	// never requests them again.
+	Logins  []string
+	Missing []string
+	Pushed  []string
+
+	Status      string
+	Error       string
+	NextAttempt time.Time
+}
+
+// Kinds and statuses of scheduled changes
+const (
+	ChangeDeactivate = "deactivate"
+	ChangeActivate   = "activate"
+	ChangeMoveTeam   = "move_team"
+
+	ChangePending   = "PENDING"
+	ChangeApplied   = "APPLIED"
+	ChangeFailed    = "FAILED"
+	ChangeCancelled = "CANCELLED"
+)
+
+// ScheduledChange is a user state change applied once RunAt has passed.
+// TeamName is the destination of a move; FromTeam limits which team the
+// user leaves (every other team when empty).
+type ScheduledChange struct {
+	ID              int64      `db:"id" json:"id"`
+	Kind            string     `db:"kind" json:"kind"`
+	UserID          string     `db:"user_id" json:"user_id"`
+	TeamName        string     `db:"team_name" json:"team_name,omitempty"`
+	FromTeam        string     `db:"from_team" json:"from_team,omitempty"`
+	ReassignReviews *bool      `db:"reassign_reviews" json:"reassign_reviews,omitempty"`
+	RunAt           time.Time  `db:"run_at" json:"run_at"`
+	Status          string     `db:"status" json:"status"`
+	Error           string     `db:"error" json:"error,omitempty"`
+	CreatedAt       time.Time  `db:"created_at" json:"created_at"`
+	AppliedAt       *time.Time `db:"applied_at" json:"applied_at,omitempty"`
+
+	// Set by the worker for the run that applied the change
+	Reassignments []Reassignment `json:"reassignments,omitempty"`
+}
+
+// RevertResult reports what undoing an operation restored. NotReverted
+// lists the reviewer swaps left in place, with the reason. Restored
+// reviewers start over in ReviewState: the review the replacement left is
+// not carried over.
+type RevertResult struct {
+	OperationID int64          `json:"operation_id"`
+	Reactivated []string       `json:"reactivated"`
+	Restored    []Reassignment `json:"restored"`
+	NotReverted []Reassignment `json:"not_reverted"`
+	ReviewState ReviewState    `json:"review_state"`
+}
+
+// MergePolicy holds the rules checked by /pullRequest/merge. It belongs to
+// either a team or a repository. Zero values disable the corresponding rule.
+type MergePolicy struct {
+	TeamName                string `db:"team_name" json:"team_name,omitempty"`
+	Repository              string `db:"repository" json:"repository,omitempty"`
+	MinApprovals            int    `db:"min_approvals" json:"min_approvals"`
+	BlockOnChangesRequested bool   `db:"block_on_changes_requested" json:"block_on_changes_requested"`
+	MinAgeMinutes           int    `db:"min_age_minutes" json:"min_age_minutes"`
+	RequiredReviewerTeam    string `db:"required_reviewer_team" json:"required_reviewer_team,omitempty"`
+}
+
+// MergeFreeze blocks merges for a team (or every team when TeamName is
+// empty) between StartsAt and EndsAt.
+type MergeFreeze struct {
+	ID       int64     `db:"id" json:"id"`
+	TeamName string    `db:"team_name" json:"team_name,omitempty"`
+	StartsAt time.Time `db:"starts_at" json:"starts_at"`
+	EndsAt   time.Time `db:"ends_at" json:"ends_at"`
+	Reason   string    `db:"reason" json:"reason"`
+}
+
+type RuleViolation struct {
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
+}
+
+type Repository struct {
+	Name          string       `db:"name" json:"repository"`
+	OwningTeam    string       `db:"owning_team" json:"owning_team,omitempty"`
+	Provider      string       `db:"provider" json:"provider,omitempty"`
+	ReviewerCount int          `db:"reviewer_count" json:"reviewer_count"`
+	MergePolicy   *MergePolicy `json:"merge_policy,omitempty"`
+}
+
+// SLABreach is an assignment whose first review came after its due time
+// (or has not come yet)
+type SLABreach struct {
+	PullRequestID string     `db:"pull_request_id" json:"pull_request_id"`
+	UserID        string     `db:"user_id" json:"user_id"`
+	TeamName      string     `db:"team_name" json:"team_name"`
+	DueAt         time.Time  `db:"due_at" json:"due_at"`
+	DetectedAt    time.Time  `db:"detected_at" json:"detected_at"`
+	ReviewedAt    *time.Time `db:"reviewed_at" json:"reviewed_at,omitempty"`
+}
+
+// Escalation is recorded when an assignment stays unanswered past the team's
+// escalation window. EscalatedTo is empty when nobody eligible was found;
+// Error is set by the worker when the escalation could not be recorded.
+type Escalation struct {
+	ID                int64     `db:"id" json:"id"`
+	PullRequestID     string    `db:"pull_request_id" json:"pull_request_id"`
+	TeamName          string    `db:"team_name" json:"team_name"`
+	StalledReviewerID string    `db:"stalled_reviewer_id" json:"stalled_reviewer_id"`
+	EscalatedTo       string    `db:"escalated_to" json:"escalated_to,omitempty"`
+	CreatedAt         time.Time `db:"created_at" json:"created_at"`
+	Error             string    `db:"-" json:"error,omitempty"`
+}
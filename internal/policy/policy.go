@@ -0,0 +1,91 @@
+package policy
+
+import (
+	"fmt"
+	"time"
+
+	"pr-reviewer-service/internal/models"
+)
+
+// Rule names reported in violations
+const (
+	RuleMinApprovals     = "min_approvals"
+	RuleChangesRequested = "no_changes_requested"
+	RuleMinAge           = "min_age"
+	RuleRequiredReviewer = "required_reviewer"
+	RuleMergeFreeze      = "merge_freeze"
+)
+
+// Input is the state of a pull request at merge time.
+type Input struct {
+	CreatedAt *time.Time
+	Reviews   []models.Review
+	// RequiredTeamMembers contains the user IDs of policy.RequiredReviewerTeam.
+	RequiredTeamMembers map[string]bool
+	Freezes             []models.MergeFreeze
+	Now                 time.Time
+}
+
+// Evaluate checks every rule of p against in and returns the failed ones.
+// Freezes are checked even when the policy itself is empty.
+func Evaluate(p models.MergePolicy, in Input) []models.RuleViolation {
+	violations := []models.RuleViolation{}
+
+	approvals := 0
+	changesRequested := 0
+	requiredApproved := false
+	for _, r := range in.Reviews {
+		switch r.State {
+		case models.ReviewApproved:
+			approvals++
+			if in.RequiredTeamMembers[r.UserID] {
+				requiredApproved = true
+			}
+		case models.ReviewChangesRequested:
+			changesRequested++
+		}
+	}
+
+	if p.MinApprovals > 0 && approvals < p.MinApprovals {
+		violations = append(violations, models.RuleViolation{
+			Rule:    RuleMinApprovals,
+			Message: fmt.Sprintf("%d of %d required approvals", approvals, p.MinApprovals),
+		})
+	}
+
+	if p.BlockOnChangesRequested && changesRequested > 0 {
+		violations = append(violations, models.RuleViolation{
+			Rule:    RuleChangesRequested,
+			Message: fmt.Sprintf("%d reviewer(s) requested changes", changesRequested),
+		})
+	}
+
+	if p.MinAgeMinutes > 0 && in.CreatedAt != nil {
+		minAge := time.Duration(p.MinAgeMinutes) * time.Minute
+		if age := in.Now.Sub(*in.CreatedAt); age < minAge {
+			violations = append(violations, models.RuleViolation{
+				Rule:    RuleMinAge,
+				Message: fmt.Sprintf("PR can be merged after %s", in.CreatedAt.Add(minAge).UTC().Format(time.RFC3339)),
+			})
+		}
+	}
+
+	if p.RequiredReviewerTeam != "" && !requiredApproved {
+		violations = append(violations, models.RuleViolation{
+			Rule:    RuleRequiredReviewer,
+			Message: fmt.Sprintf("approval from team %s is required", p.RequiredReviewerTeam),
+		})
+	}
+
+	for _, f := range in.Freezes {
+		if !in.Now.Before(f.StartsAt) && in.Now.Before(f.EndsAt) {
+			msg := fmt.Sprintf("merge freeze until %s", f.EndsAt.UTC().Format(time.RFC3339))
+			if f.Reason != "" {
+				msg += ": " + f.Reason
+			}
+			violations = append(violations, models.RuleViolation{Rule: RuleMergeFreeze, Message: msg})
+		}
+	}
+
+	return violations
+}
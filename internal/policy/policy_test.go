@@ -0,0 +1,122 @@
+package policy
+
+import (
+	"testing"
+	"time"
+
+	"pr-reviewer-service/internal/models"
+)
+
+func TestEvaluate(t *testing.T) {
+	now := time.Date(2025, 11, 10, 12, 0, 0, 0, time.UTC)
+	created := now.Add(-30 * time.Minute)
+
+	tests := []struct {
+		name   string
+		policy models.MergePolicy
+		input  Input
+		want   []string
+	}{
+		{
+			name:   "empty policy passes",
+			policy: models.MergePolicy{},
+			input:  Input{CreatedAt: &created, Now: now},
+			want:   nil,
+		},
+		{
+			name:   "not enough approvals",
+			policy: models.MergePolicy{MinApprovals: 2},
+			input: Input{
+				Reviews: []models.Review{{UserID: "u2", State: models.ReviewApproved}, {UserID: "u3", State: models.ReviewPending}},
+				Now:     now,
+			},
+			want: []string{RuleMinApprovals},
+		},
+		{
+			name:   "changes requested",
+			policy: models.MergePolicy{MinApprovals: 1, BlockOnChangesRequested: true},
+			input: Input{
+				Reviews: []models.Review{{UserID: "u2", State: models.ReviewApproved}, {UserID: "u3", State: models.ReviewChangesRequested}},
+				Now:     now,
+			},
+			want: []string{RuleChangesRequested},
+		},
+		{
+			name:   "too young",
+			policy: models.MergePolicy{MinAgeMinutes: 60},
+			input:  Input{CreatedAt: &created, Now: now},
+			want:   []string{RuleMinAge},
+		},
+		{
+			name:   "required team approved",
+			policy: models.MergePolicy{RequiredReviewerTeam: "security"},
+			input: Input{
+				Reviews:             []models.Review{{UserID: "s1", State: models.ReviewApproved}},
+				RequiredTeamMembers: map[string]bool{"s1": true},
+				Now:                 now,
+			},
+			want: nil,
+		},
+		{
+			name:   "required team missing",
+			policy: models.MergePolicy{RequiredReviewerTeam: "security"},
+			input: Input{
+				Reviews:             []models.Review{{UserID: "u2", State: models.ReviewApproved}},
+				RequiredTeamMembers: map[string]bool{"s1": true},
+				Now:                 now,
+			},
+			want: []string{RuleRequiredReviewer},
+		},
+		{
+			name:   "active freeze without policy",
+			policy: models.MergePolicy{},
+			input: Input{
+				Freezes: []models.MergeFreeze{
+					{StartsAt: now.Add(-time.Hour), EndsAt: now.Add(time.Hour), Reason: "release"},
+					{StartsAt: now.Add(time.Hour), EndsAt: now.Add(2 * time.Hour)},
+				},
+				Now: now,
+			},
+			want: []string{RuleMergeFreeze},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Evaluate(tt.policy, tt.input)
+			if len(got) != len(tt.want) {
+				t.Fatalf("Expected %d violations, got %v", len(tt.want), got)
+			}
+			for i, v := range got {
+				if v.Rule != tt.want[i] {
+					t.Errorf("Expected rule %s, got %s", tt.want[i], v.Rule)
+				}
+			}
+		})
+	}
+}
+
+func TestReviewerCount(t *testing.T) {
+	rules := []models.SizeRule{
+		{MinLines: 500, ReviewerCount: 3},
+		{MinLines: 100, ReviewerCount: 2},
+		{MinLines: 2000, ReviewerCount: 4},
+	}
+
+	tests := []struct {
+		lines int
+		want  int
+	}{
+		{lines: 10, want: 1},
+		{lines: 100, want: 1},
+		{lines: 101, want: 2},
+		{lines: 501, want: 3},
+		{lines: 5000, want: 4},
+	}
+
+	for _, tt := range tests {
+		if got := ReviewerCount(1, rules, tt.lines); got != tt.want {
+			t.Errorf("ReviewerCount(%d lines) = %d, want %d", tt.lines, got, tt.want)
+		}
+	}
+}
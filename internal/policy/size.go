@@ -0,0 +1,18 @@
+package policy
+
+import "pr-reviewer-service/internal/models"
+
+// ReviewerCount scales base by the team size rules: the rule with the largest
+// MinLines below the PR's changed lines wins. Without a matching rule the
+// base count is kept.
+func ReviewerCount(base int, rules []models.SizeRule, changedLines int) int {
+	count := base
+	best := -1
+	for _, rule := range rules {
+		if changedLines > rule.MinLines && rule.MinLines > best {
+			best = rule.MinLines
+			count = rule.ReviewerCount
+		}
+	}
+	return count
+}
@@ -0,0 +1,165 @@
+package roster
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// csvHeader is the column layout of the CSV format: one row per membership,
+// or per user with an empty team for users outside of any team. Team
+// settings are not part of the CSV format. Files without the trailing bot
+// columns are still accepted.
+var csvHeader = []string{"team", "parent_team", "user_id", "username", "role", "is_active", "timezone", "is_bot", "bot_team"}
+
+// csvBotColumns is the number of trailing columns older files lack
+const csvBotColumns = 2
+
+// Parse reads a roster in the given format
+func Parse(format string, r io.Reader) (Roster, error) {
+	var roster Roster
+	switch format {
+	case FormatYAML:
+		dec := yaml.NewDecoder(r)
+		dec.KnownFields(true)
+		if err := dec.Decode(&roster); err != nil && err != io.EOF {
+			return Roster{}, err
+		}
+	case FormatJSON:
+		dec := json.NewDecoder(r)
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(&roster); err != nil {
+			return Roster{}, err
+		}
+	case FormatCSV:
+		return parseCSV(r)
+	default:
+		return Roster{}, fmt.Errorf("unknown format %q", format)
+	}
+	return roster, nil
+}
+
+func parseCSV(r io.Reader) (Roster, error) {
+	rows, err := csv.NewReader(r).ReadAll()
+	if err != nil {
+		return Roster{}, err
+	}
+	legacy := csvHeader[:len(csvHeader)-csvBotColumns]
+	if len(rows) == 0 || (strings.Join(rows[0], ",") != strings.Join(csvHeader, ",") && strings.Join(rows[0], ",") != strings.Join(legacy, ",")) {
+		return Roster{}, fmt.Errorf("csv header must be %s", strings.Join(csvHeader, ","))
+	}
+
+	var roster Roster
+	users := map[string]int{}
+	teams := map[string]int{}
+	for i, row := range rows[1:] {
+		line := i + 2
+		team, parent, userID, username, role, active, tz := row[0], row[1], row[2], row[3], row[4], row[5], row[6]
+
+		u := User{UserID: userID, Username: username, Timezone: tz}
+		if active != "" {
+			b, err := strconv.ParseBool(active)
+			if err != nil {
+				return Roster{}, fmt.Errorf("line %d: is_active must be true or false", line)
+			}
+			u.IsActive = &b
+		}
+		if len(row) == len(csvHeader) {
+			if row[7] != "" {
+				if u.IsBot, err = strconv.ParseBool(row[7]); err != nil {
+					return Roster{}, fmt.Errorf("line %d: is_bot must be true or false", line)
+				}
+			}
+			u.BotTeam = row[8]
+		}
+		// A user on several rows must be described the same way each time
+		if idx, ok := users[userID]; ok {
+			if prev := roster.Users[idx]; prev.Username != u.Username || prev.Active() != u.Active() || prev.Timezone != u.Timezone ||
+				prev.IsBot != u.IsBot || prev.BotTeam != u.BotTeam {
+				return Roster{}, fmt.Errorf("line %d: user %s differs from an earlier row", line, userID)
+			}
+		} else {
+			users[userID] = len(roster.Users)
+			roster.Users = append(roster.Users, u)
+		}
+
+		if team == "" {
+			continue
+		}
+		idx, ok := teams[team]
+		if !ok {
+			idx = len(roster.Teams)
+			teams[team] = idx
+			roster.Teams = append(roster.Teams, Team{Name: team, ParentTeam: parent})
+		} else if roster.Teams[idx].ParentTeam != parent {
+			return Roster{}, fmt.Errorf("line %d: team %s has a different parent_team on an earlier row", line, team)
+		}
+		roster.Teams[idx].Members = append(roster.Teams[idx].Members, Member{UserID: userID, Role: role})
+	}
+	return roster, nil
+}
+
+// ErrCSVExport is returned by Write for a CSV export of a roster with team
+// settings or teams without members, which CSV cannot carry
+var ErrCSVExport = errors.New("csv cannot carry team settings or teams without members; export as yaml or json")
+
+// Write outputs a roster in the given format. CSV carries no assignments
+// and is refused with ErrCSVExport when it would drop a team or its
+// settings, so every export imports back unchanged.
+func Write(format string, w io.Writer, r Roster) error {
+	switch format {
+	case FormatYAML:
+		enc := yaml.NewEncoder(w)
+		enc.SetIndent(2)
+		if err := enc.Encode(r); err != nil {
+			return err
+		}
+		return enc.Close()
+	case FormatJSON:
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(r)
+	case FormatCSV:
+		for _, t := range r.Teams {
+			if !t.Settings.empty() || len(t.Members) == 0 {
+				return fmt.Errorf("%w (team %s)", ErrCSVExport, t.Name)
+			}
+		}
+		return writeCSV(w, r)
+	default:
+		return fmt.Errorf("unknown format %q", format)
+	}
+}
+
+func writeCSV(w io.Writer, r Roster) error {
+	users := map[string]User{}
+	for _, u := range r.Users {
+		users[u.UserID] = u
+	}
+	row := func(team, parent string, u User, role string) []string {
+		return []string{team, parent, u.UserID, u.Username, role, strconv.FormatBool(u.Active()), u.Timezone, strconv.FormatBool(u.IsBot), u.BotTeam}
+	}
+
+	out := csv.NewWriter(w)
+	out.Write(csvHeader)
+	inTeam := map[string]bool{}
+	for _, t := range r.Teams {
+		for _, m := range t.Members {
+			out.Write(row(t.Name, t.ParentTeam, users[m.UserID], m.Role))
+			inTeam[m.UserID] = true
+		}
+	}
+	for _, u := range r.Users {
+		if !inTeam[u.UserID] {
+			out.Write(row("", "", u, ""))
+		}
+	}
+	out.Flush()
+	return out.Error()
+}
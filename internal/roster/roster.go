@@ -0,0 +1,176 @@
+// Package roster reads and writes the users and teams snapshot used by
+// /admin/import and /admin/export, in YAML, JSON or CSV.
+package roster
+
+import (
+	"fmt"
+	"time"
+
+	"pr-reviewer-service/internal/models"
+)
+
+// Supported formats
+const (
+	FormatYAML = "yaml"
+	FormatJSON = "json"
+	FormatCSV  = "csv"
+)
+
+// Roster is the full set of users and teams. Team member lists are
+// authoritative: importing a team removes members missing from it.
+type Roster struct {
+	Users []User `yaml:"users" json:"users"`
+	Teams []Team `yaml:"teams" json:"teams"`
+	// Assignments are exported for reference and ignored on import
+	Assignments []Assignment `yaml:"assignments,omitempty" json:"assignments,omitempty"`
+}
+
+type User struct {
+	UserID   string `yaml:"user_id" json:"user_id"`
+	Username string `yaml:"username" json:"username"`
+	// IsActive defaults to true when omitted
+	IsActive *bool  `yaml:"is_active,omitempty" json:"is_active,omitempty"`
+	Timezone string `yaml:"timezone,omitempty" json:"timezone,omitempty"`
+	IsBot    bool   `yaml:"is_bot,omitempty" json:"is_bot,omitempty"`
+	// BotTeam reviews the PRs of a bot instead of the repository owner
+	BotTeam string `yaml:"bot_team,omitempty" json:"bot_team,omitempty"`
+}
+
+// Active reports the user's active flag, true when unset
+func (u User) Active() bool {
+	return u.IsActive == nil || *u.IsActive
+}
+
+type Team struct {
+	Name       string    `yaml:"name" json:"name"`
+	ParentTeam string    `yaml:"parent_team,omitempty" json:"parent_team,omitempty"`
+	Settings   *Settings `yaml:"settings,omitempty" json:"settings,omitempty"`
+	Members    []Member  `yaml:"members" json:"members"`
+}
+
+// Settings replace the team's settings as a whole when present and are
+// left untouched when omitted
+type Settings struct {
+	ReviewSLAHours    int        `yaml:"review_sla_hours,omitempty" json:"review_sla_hours,omitempty"`
+	Holidays          []string   `yaml:"holidays,omitempty" json:"holidays,omitempty"`
+	EscalationMinutes int        `yaml:"escalation_minutes,omitempty" json:"escalation_minutes,omitempty"`
+	EscalationUserID  string     `yaml:"escalation_user_id,omitempty" json:"escalation_user_id,omitempty"`
+	SizeRules         []SizeRule `yaml:"size_rules,omitempty" json:"size_rules,omitempty"`
+	// KeepReviewsOnDeactivate leaves deactivated members on their reviews
+	KeepReviewsOnDeactivate bool `yaml:"keep_reviews_on_deactivate,omitempty" json:"keep_reviews_on_deactivate,omitempty"`
+}
+
+// empty reports whether s holds no setting at all
+func (s *Settings) empty() bool {
+	return s == nil || (s.ReviewSLAHours == 0 && len(s.Holidays) == 0 && s.EscalationMinutes == 0 &&
+		s.EscalationUserID == "" && len(s.SizeRules) == 0 && !s.KeepReviewsOnDeactivate)
+}
+
+// SizeRule mirrors models.SizeRule for the roster formats
+type SizeRule struct {
+	MinLines      int `yaml:"min_lines" json:"min_lines"`
+	ReviewerCount int `yaml:"reviewer_count" json:"reviewer_count"`
+}
+
+// Assignment lists the reviewers of an open PR
+type Assignment struct {
+	PullRequestID string   `yaml:"pull_request_id" json:"pull_request_id"`
+	AuthorID      string   `yaml:"author_id" json:"author_id"`
+	Reviewers     []string `yaml:"reviewers" json:"reviewers"`
+}
+
+type Member struct {
+	UserID string `yaml:"user_id" json:"user_id"`
+	Role   string `yaml:"role,omitempty" json:"role,omitempty"`
+}
+
+// Validate checks the roster on its own and returns every problem found.
+// References to teams outside the roster (parent and bot teams) are left to
+// the store.
+func (r Roster) Validate() []string {
+	var problems []string
+
+	users := map[string]bool{}
+	for i, u := range r.Users {
+		switch {
+		case u.UserID == "":
+			problems = append(problems, fmt.Sprintf("users[%d]: user_id is required", i))
+		case users[u.UserID]:
+			problems = append(problems, fmt.Sprintf("users[%d]: duplicate user %s", i, u.UserID))
+		}
+		if _, err := time.LoadLocation(u.Timezone); err != nil {
+			problems = append(problems, fmt.Sprintf("users[%d]: unknown timezone %s", i, u.Timezone))
+		}
+		if u.BotTeam != "" && !u.IsBot {
+			problems = append(problems, fmt.Sprintf("users[%d]: bot_team is only allowed for bots", i))
+		}
+		users[u.UserID] = true
+	}
+
+	teams := map[string]string{}
+	for i, t := range r.Teams {
+		if t.Name == "" {
+			problems = append(problems, fmt.Sprintf("teams[%d]: name is required", i))
+			continue
+		}
+		if _, dup := teams[t.Name]; dup {
+			problems = append(problems, fmt.Sprintf("teams[%d]: duplicate team %s", i, t.Name))
+		}
+		teams[t.Name] = t.ParentTeam
+
+		members := map[string]bool{}
+		for j, m := range t.Members {
+			if !users[m.UserID] {
+				problems = append(problems, fmt.Sprintf("teams[%d].members[%d]: user %q is not in users", i, j, m.UserID))
+			}
+			if members[m.UserID] {
+				problems = append(problems, fmt.Sprintf("teams[%d].members[%d]: duplicate member %s", i, j, m.UserID))
+			}
+			members[m.UserID] = true
+			switch m.Role {
+			case "", models.RoleMember, models.RoleLead, models.RoleObserver:
+			default:
+				problems = append(problems, fmt.Sprintf("teams[%d].members[%d]: unknown role %q", i, j, m.Role))
+			}
+		}
+
+		if t.Settings != nil {
+			problems = append(problems, t.Settings.validate(fmt.Sprintf("teams[%d].settings", i))...)
+		}
+	}
+
+	// Parent links inside the roster must not loop
+	for name := range teams {
+		seen := map[string]bool{name: true}
+		for parent := teams[name]; parent != ""; parent = teams[parent] {
+			if seen[parent] {
+				problems = append(problems, fmt.Sprintf("team %s: parent_team forms a cycle", name))
+				break
+			}
+			seen[parent] = true
+		}
+	}
+
+	return problems
+}
+
+func (s Settings) validate(path string) []string {
+	var problems []string
+	if s.ReviewSLAHours < 0 || s.EscalationMinutes < 0 {
+		problems = append(problems, path+": review_sla_hours and escalation_minutes must not be negative")
+	}
+	for _, day := range s.Holidays {
+		if _, err := time.Parse("2006-01-02", day); err != nil {
+			problems = append(problems, fmt.Sprintf("%s: holiday %q is not YYYY-MM-DD", path, day))
+		}
+	}
+	lines := map[int]bool{}
+	for _, rule := range s.SizeRules {
+		if rule.MinLines < 0 || rule.ReviewerCount < 0 || lines[rule.MinLines] {
+			problems = append(problems, path+": size_rules must have unique non-negative min_lines and reviewer_count")
+			break
+		}
+		lines[rule.MinLines] = true
+	}
+	return problems
+}
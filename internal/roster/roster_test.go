@@ -0,0 +1,186 @@
+package roster
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestParseYAML(t *testing.T) {
+	in := `
+users:
+  - user_id: u1
+    username: Alice
+  - user_id: u2
+    username: Bob
+    is_active: false
+teams:
+  - name: backend
+    parent_team: engineering
+    settings:
+      review_sla_hours: 8
+      size_rules:
+        - min_lines: 500
+          reviewer_count: 3
+    members:
+      - user_id: u1
+        role: lead
+      - user_id: u2
+  - name: engineering
+    members: []
+`
+	r, err := Parse(FormatYAML, strings.NewReader(in))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	if problems := r.Validate(); len(problems) > 0 {
+		t.Fatalf("Expected a valid roster, got %v", problems)
+	}
+	if !r.Users[0].Active() || r.Users[1].Active() {
+		t.Errorf("Expected u1 active by default and u2 inactive")
+	}
+	if r.Teams[0].Settings.ReviewSLAHours != 8 || len(r.Teams[0].Settings.SizeRules) != 1 {
+		t.Errorf("Unexpected settings: %+v", r.Teams[0].Settings)
+	}
+
+	if _, err := Parse(FormatYAML, strings.NewReader("teams:\n  - name: x\n    lead: u1\n")); err == nil {
+		t.Errorf("Expected unknown fields to be rejected")
+	}
+}
+
+func TestParseCSV(t *testing.T) {
+	in := `team,parent_team,user_id,username,role,is_active,timezone
+backend,engineering,u1,Alice,lead,true,Europe/Berlin
+backend,engineering,u2,Bob,,false,
+frontend,engineering,u1,Alice,member,true,Europe/Berlin
+,,u3,Carol,,,
+`
+	r, err := Parse(FormatCSV, strings.NewReader(in))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	if len(r.Users) != 3 || len(r.Teams) != 2 {
+		t.Fatalf("Expected 3 users and 2 teams, got %d and %d", len(r.Users), len(r.Teams))
+	}
+	if len(r.Teams[0].Members) != 2 || r.Teams[0].Members[0].Role != "lead" {
+		t.Errorf("Unexpected backend members: %+v", r.Teams[0].Members)
+	}
+
+	conflicting := `team,parent_team,user_id,username,role,is_active,timezone
+backend,,u1,Alice,,true,
+frontend,,u1,Alicia,,true,
+`
+	if _, err := Parse(FormatCSV, strings.NewReader(conflicting)); err == nil {
+		t.Errorf("Expected an error for a user described differently on two rows")
+	}
+}
+
+func TestValidate(t *testing.T) {
+	r := Roster{
+		Users: []User{{UserID: "u1", Username: "Alice"}, {UserID: "u1", Username: "Alice"}, {UserID: "u2", BotTeam: "a"}},
+		Teams: []Team{
+			{Name: "a", ParentTeam: "b", Members: []Member{{UserID: "u1", Role: "owner"}, {UserID: "u9"}}},
+			{Name: "b", ParentTeam: "a", Settings: &Settings{Holidays: []string{"25.12.2025"}}},
+		},
+	}
+
+	problems := r.Validate()
+	for _, want := range []string{"duplicate user", "unknown role", "not in users", "cycle", "holiday", "only allowed for bots"} {
+		found := false
+		for _, p := range problems {
+			if strings.Contains(p, want) {
+				found = true
+			}
+		}
+		if !found {
+			t.Errorf("Expected a problem mentioning %q, got %v", want, problems)
+		}
+	}
+}
+
+func TestWriteRoundTrip(t *testing.T) {
+	inactive := false
+	r := Roster{
+		Users: []User{{UserID: "u1", Username: "Alice"}, {UserID: "u2", Username: "Bob", IsActive: &inactive}, {UserID: "ci-bot", Username: "CI", IsBot: true, BotTeam: "backend"}},
+		Teams: []Team{{
+			Name:       "backend",
+			ParentTeam: "engineering",
+			Settings:   &Settings{ReviewSLAHours: 8, SizeRules: []SizeRule{{MinLines: 500, ReviewerCount: 3}}, KeepReviewsOnDeactivate: true},
+			Members:    []Member{{UserID: "u1", Role: "lead"}, {UserID: "u2", Role: "member"}},
+		}, {
+			Name:     "engineering",
+			Settings: &Settings{EscalationMinutes: 240},
+			Members:  []Member{},
+		}},
+		Assignments: []Assignment{{PullRequestID: "pr-1", AuthorID: "u1", Reviewers: []string{"u2"}}},
+	}
+
+	for _, format := range []string{FormatYAML, FormatJSON} {
+		var buf strings.Builder
+		if err := Write(format, &buf, r); err != nil {
+			t.Fatalf("%s: Write failed: %v", format, err)
+		}
+		got, err := Parse(format, strings.NewReader(buf.String()))
+		if err != nil {
+			t.Fatalf("%s: Parse failed: %v\n%s", format, err, buf.String())
+		}
+		if len(got.Users) != 3 || got.Users[1].Active() || len(got.Teams) != 2 || len(got.Teams[0].Members) != 2 {
+			t.Errorf("%s: roster did not survive the round trip: %+v", format, got)
+		}
+		if bot := got.Users[2]; !bot.IsBot || bot.BotTeam != "backend" {
+			t.Errorf("%s: bot fields were lost: %+v", format, bot)
+		}
+		if got.Teams[0].Settings == nil || got.Teams[0].Settings.SizeRules[0].ReviewerCount != 3 || !got.Teams[0].Settings.KeepReviewsOnDeactivate {
+			t.Errorf("%s: settings were lost: %+v", format, got.Teams[0].Settings)
+		}
+		if empty := got.Teams[1]; empty.Name != "engineering" || empty.Settings == nil || empty.Settings.EscalationMinutes != 240 {
+			t.Errorf("%s: the team without members was lost: %+v", format, empty)
+		}
+	}
+
+	// CSV would drop the settings and the empty team
+	var buf strings.Builder
+	if err := Write(FormatCSV, &buf, r); !errors.Is(err, ErrCSVExport) || buf.Len() != 0 {
+		t.Errorf("Expected ErrCSVExport and no output for CSV, got %v and %q", err, buf.String())
+	}
+}
+
+func TestWriteCSVRoundTrip(t *testing.T) {
+	inactive := false
+	r := Roster{
+		Users: []User{
+			{UserID: "u1", Username: "Alice", Timezone: "Europe/Berlin"},
+			{UserID: "u2", Username: "Bob", IsActive: &inactive},
+			{UserID: "u3", Username: "Carol"},
+			{UserID: "ci-bot", Username: "CI", IsBot: true, BotTeam: "backend"},
+		},
+		Teams: []Team{{
+			Name:       "backend",
+			ParentTeam: "engineering",
+			Settings:   &Settings{},
+			Members:    []Member{{UserID: "u1", Role: "lead"}, {UserID: "u2", Role: "member"}},
+		}, {
+			Name:    "engineering",
+			Members: []Member{{UserID: "u3", Role: "member"}},
+		}},
+	}
+
+	var buf strings.Builder
+	if err := Write(FormatCSV, &buf, r); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+	got, err := Parse(FormatCSV, strings.NewReader(buf.String()))
+	if err != nil {
+		t.Fatalf("Parse failed: %v\n%s", err, buf.String())
+	}
+	if len(got.Users) != 4 || got.Users[1].Active() || got.Users[0].Timezone != "Europe/Berlin" {
+		t.Errorf("Users did not survive the round trip: %+v", got.Users)
+	}
+	if bot := got.Users[3]; !bot.IsBot || bot.BotTeam != "backend" {
+		t.Errorf("Bot fields were lost: %+v", bot)
+	}
+	if len(got.Teams) != 2 || got.Teams[0].ParentTeam != "engineering" || len(got.Teams[0].Members) != 2 ||
+		got.Teams[0].Members[0].Role != "lead" || len(got.Teams[1].Members) != 1 {
+		t.Errorf("Teams did not survive the round trip: %+v", got.Teams)
+	}
+}
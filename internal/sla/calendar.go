@@ -0,0 +1,63 @@
+package sla
+
+import "time"
+
+// Business hours of a working day in the reviewer's local time
+const (
+	DayStart = 9
+	DayEnd   = 18
+)
+
+// maxDays stops the search when a calendar has no working days at all
+const maxDays = 3660
+
+// Calendar knows which hours count towards a review SLA
+type Calendar struct {
+	Location *time.Location
+	// Holidays are dates in 2006-01-02 form, interpreted in Location
+	Holidays map[string]bool
+}
+
+// IsBusinessDay reports whether t falls on a weekday that is not a holiday
+func (c Calendar) IsBusinessDay(t time.Time) bool {
+	t = t.In(c.location())
+	if t.Weekday() == time.Saturday || t.Weekday() == time.Sunday {
+		return false
+	}
+	return !c.Holidays[t.Format("2006-01-02")]
+}
+
+// DueAt returns the moment hours business hours after start
+func (c Calendar) DueAt(start time.Time, hours int) time.Time {
+	loc := c.location()
+	t := start.In(loc)
+	remaining := time.Duration(hours) * time.Hour
+
+	for day := 0; day < maxDays; day++ {
+		y, m, d := t.Date()
+		open := time.Date(y, m, d, DayStart, 0, 0, 0, loc)
+		closing := time.Date(y, m, d, DayEnd, 0, 0, 0, loc)
+
+		if c.IsBusinessDay(t) && t.Before(closing) {
+			if t.Before(open) {
+				t = open
+			}
+			available := closing.Sub(t)
+			if remaining <= available {
+				return t.Add(remaining)
+			}
+			remaining -= available
+		}
+
+		t = time.Date(y, m, d+1, DayStart, 0, 0, 0, loc)
+	}
+
+	return t
+}
+
+func (c Calendar) location() *time.Location {
+	if c.Location == nil {
+		return time.UTC
+	}
+	return c.Location
+}
@@ -0,0 +1,64 @@
+package sla
+
+import (
+	"testing"
+	"time"
+)
+
+func TestDueAt(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Skip("timezone database not available")
+	}
+
+	tests := []struct {
+		name     string
+		calendar Calendar
+		start    time.Time
+		hours    int
+		want     time.Time
+	}{
+		{
+			name:  "within one day",
+			start: time.Date(2025, 11, 10, 10, 0, 0, 0, time.UTC),
+			hours: 4,
+			want:  time.Date(2025, 11, 10, 14, 0, 0, 0, time.UTC),
+		},
+		{
+			name:  "before opening",
+			start: time.Date(2025, 11, 10, 6, 30, 0, 0, time.UTC),
+			hours: 8,
+			want:  time.Date(2025, 11, 10, 17, 0, 0, 0, time.UTC),
+		},
+		{
+			name:  "friday afternoon rolls over the weekend",
+			start: time.Date(2025, 11, 14, 16, 0, 0, 0, time.UTC),
+			hours: 8,
+			want:  time.Date(2025, 11, 17, 15, 0, 0, 0, time.UTC),
+		},
+		{
+			name:     "holiday is skipped",
+			calendar: Calendar{Holidays: map[string]bool{"2025-11-17": true}},
+			start:    time.Date(2025, 11, 14, 16, 0, 0, 0, time.UTC),
+			hours:    8,
+			want:     time.Date(2025, 11, 18, 15, 0, 0, 0, time.UTC),
+		},
+		{
+			name:     "reviewer timezone",
+			calendar: Calendar{Location: berlin},
+			start:    time.Date(2025, 11, 10, 16, 0, 0, 0, time.UTC),
+			hours:    2,
+			// 17:00 in Berlin: one hour today, one tomorrow from 09:00 local
+			want: time.Date(2025, 11, 11, 9, 0, 0, 0, time.UTC),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := tt.calendar.DueAt(tt.start, tt.hours)
+			if !got.Equal(tt.want) {
+				t.Errorf("DueAt() = %s, want %s", got.UTC(), tt.want)
+			}
+		})
+	}
+}
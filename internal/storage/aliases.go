@@ -0,0 +1,91 @@
+package storage
+
+import (
+	"database/sql"
+	"errors"
+	"strings"
+
+	"pr-reviewer-service/internal/models"
+
+	"github.com/jmoiron/sqlx"
+)
+
+func (s *SQLStore) AddUserAlias(a models.UserAlias) (models.UserAlias, error) {
+	var exists bool
+	err := s.db.Get(&exists, "SELECT EXISTS(SELECT 1 FROM users WHERE user_id = $1)", a.UserID)
+	if err != nil {
+		return models.UserAlias{}, err
+	}
+	if !exists {
+		return models.UserAlias{}, ErrNotFound
+	}
+
+	var created models.UserAlias
+	err = s.db.Get(&created, `
+		INSERT INTO user_aliases (provider, external_id, user_id) VALUES ($1, $2, $3)
+		ON CONFLICT DO NOTHING
+		RETURNING provider, external_id, user_id, created_at`,
+		a.Provider, strings.ToLower(a.ExternalID), a.UserID,
+	)
+	if errors.Is(err, sql.ErrNoRows) {
+		return models.UserAlias{}, ErrAliasExists
+	}
+	return created, err
+}
+
+// ListUserAliases returns aliases ordered by user; empty filters match all
+func (s *SQLStore) ListUserAliases(userID, provider string) ([]models.UserAlias, error) {
+	aliases := []models.UserAlias{}
+	err := s.db.Select(&aliases, `
+		SELECT provider, external_id, user_id, created_at
+		FROM user_aliases
+		WHERE ($1 = '' OR user_id = $1) AND ($2 = '' OR provider = $2)
+		ORDER BY user_id, provider, external_id`, userID, provider)
+	return aliases, err
+}
+
+func (s *SQLStore) DeleteUserAlias(provider, externalID string) (models.UserAlias, error) {
+	var deleted models.UserAlias
+	err := s.db.Get(&deleted, `
+		DELETE FROM user_aliases WHERE provider = $1 AND external_id = $2
+		RETURNING provider, external_id, user_id, created_at`,
+		provider, strings.ToLower(externalID),
+	)
+	if errors.Is(err, sql.ErrNoRows) {
+		return deleted, ErrNotFound
+	}
+	return deleted, err
+}
+
+// ResolveUser finds the user behind an identity of the provider (any
+// provider when empty). A user_id matches directly before any alias.
+func (s *SQLStore) ResolveUser(provider, identity string) (string, error) {
+	return s.resolveUserID(s.db, provider, identity)
+}
+
+func (s *SQLStore) resolveUserID(q sqlx.Queryer, provider, identity string) (string, error) {
+	var userIDs []string
+	err := sqlx.Select(q, &userIDs, "SELECT user_id FROM users WHERE user_id = $1", identity)
+	if err != nil {
+		return "", err
+	}
+	if len(userIDs) == 1 {
+		return userIDs[0], nil
+	}
+
+	err = sqlx.Select(q, &userIDs, `
+		SELECT DISTINCT user_id
+		FROM user_aliases
+		WHERE external_id = $1 AND ($2 = '' OR provider = $2)`,
+		strings.ToLower(identity), provider)
+	if err != nil {
+		return "", err
+	}
+	switch len(userIDs) {
+	case 0:
+		return "", ErrNotFound
+	case 1:
+		return userIDs[0], nil
+	}
+	return "", ErrAliasAmbiguous
+}
@@ -0,0 +1,20 @@
+package storage
+
+import (
+	"encoding/json"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// audit records an administrative action in audit_log
+func (s *SQLStore) audit(q sqlx.Execer, action, actor, target string, details interface{}) error {
+	payload, err := json.Marshal(details)
+	if err != nil {
+		return err
+	}
+	_, err = q.Exec(
+		"INSERT INTO audit_log (action, actor, target, details) VALUES ($1, $2, $3, $4)",
+		action, actor, target, payload,
+	)
+	return err
+}
@@ -0,0 +1,89 @@
+package storage
+
+import (
+	"pr-reviewer-service/internal/models"
+)
+
+// SetUserBot flags or unflags the user as a bot and sets the team that
+// reviews its PRs. A missing user is created when flagged, since bot
+// accounts usually only appear as PR authors. Open reviews of a new bot are
+// handed over like on deactivation.
+func (s *SQLStore) SetUserBot(u models.User) (models.User, []models.Reassignment, error) {
+	if u.BotTeam != "" {
+		if err := s.teamExists(u.BotTeam); err != nil {
+			return models.User{}, nil, err
+		}
+	}
+
+	tx, err := s.db.Beginx()
+	if err != nil {
+		return models.User{}, nil, err
+	}
+	defer tx.Rollback()
+
+	var wasBot bool
+	err = tx.Get(&wasBot, "SELECT is_bot FROM users WHERE user_id = $1 FOR UPDATE", u.UserID)
+	switch {
+	case err == nil:
+		_, err = tx.Exec("UPDATE users SET is_bot = $1, bot_team = NULLIF($2, '') WHERE user_id = $3",
+			u.IsBot, u.BotTeam, u.UserID)
+	case !u.IsBot:
+		return models.User{}, nil, ErrNotFound
+	default:
+		if u.Username == "" {
+			u.Username = u.UserID
+		}
+		_, err = tx.Exec(`
+			INSERT INTO users (user_id, username, is_active, is_bot, bot_team)
+			VALUES ($1, $2, true, true, NULLIF($3, ''))`,
+			u.UserID, u.Username, u.BotTeam)
+	}
+	if err != nil {
+		return models.User{}, nil, err
+	}
+
+	reassignments := []models.Reassignment{}
+	if u.IsBot && !wasBot {
+		reassignments, err = s.handOverOpenReviews(tx, u.UserID)
+		if err != nil {
+			return models.User{}, nil, err
+		}
+	}
+
+	err = s.audit(tx, "user.set_bot", "api", u.UserID, map[string]interface{}{"is_bot": u.IsBot, "bot_team": u.BotTeam})
+	if err != nil {
+		return models.User{}, nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return models.User{}, nil, err
+	}
+	user, err := s.GetUser(u.UserID)
+	return user, reassignments, err
+}
+
+type botStat struct {
+	UserID    string `db:"user_id" json:"user_id"`
+	Username  string `db:"username" json:"username"`
+	BotTeam   string `db:"bot_team" json:"bot_team,omitempty"`
+	PRCount   int    `db:"pr_count" json:"pr_count"`
+	OpenPRs   int    `db:"open_prs" json:"open_prs"`
+	MergedPRs int    `db:"merged_prs" json:"merged_prs"`
+}
+
+// botStats counts the PRs of every bot. Bots are left out of the reviewer
+// statistics.
+func (s *SQLStore) botStats() ([]botStat, error) {
+	stats := []botStat{}
+	err := s.db.Select(&stats, `
+		SELECT u.user_id, u.username, COALESCE(u.bot_team, '') AS bot_team,
+		       COUNT(p.pull_request_id) AS pr_count,
+		       COUNT(p.pull_request_id) FILTER (WHERE p.status = 'OPEN') AS open_prs,
+		       COUNT(p.pull_request_id) FILTER (WHERE p.status = 'MERGED') AS merged_prs
+		FROM users u
+		LEFT JOIN prs p ON p.author_id = u.user_id
+		WHERE u.is_bot
+		GROUP BY u.user_id, u.username, u.bot_team
+		ORDER BY pr_count DESC, u.user_id`)
+	return stats, err
+}
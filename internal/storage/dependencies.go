@@ -0,0 +1,105 @@
+package storage
+
+import (
+	"pr-reviewer-service/internal/models"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+)
+
+// maxStackDepth bounds the dependency walk in case of corrupted data
+const maxStackDepth = 100
+
+// LinkPR makes prID depend on parentID. An empty parentID removes the link.
+// Both PRs are locked before the cycle check, so two links that would close
+// a cycle between them cannot both pass it.
+func (s *SQLStore) LinkPR(prID, parentID string) (models.PullRequest, error) {
+	tx, err := s.db.Beginx()
+	if err != nil {
+		return models.PullRequest{}, err
+	}
+	defer tx.Rollback()
+
+	prID, err = s.resolvePRID(tx, prID)
+	if err != nil {
+		return models.PullRequest{}, err
+	}
+	if parentID != "" {
+		parentID, err = s.resolvePRID(tx, parentID)
+		if err != nil {
+			return models.PullRequest{}, err
+		}
+	}
+
+	// Locking in ID order keeps two opposite links from deadlocking
+	var statuses []struct {
+		ID     string `db:"pull_request_id"`
+		Status string `db:"status"`
+	}
+	err = tx.Select(&statuses, `
+		SELECT pull_request_id, status FROM prs
+		WHERE pull_request_id IN ($1, $2)
+		ORDER BY pull_request_id
+		FOR UPDATE`, prID, parentID)
+	if err != nil {
+		return models.PullRequest{}, err
+	}
+	for _, st := range statuses {
+		if st.ID == prID && st.Status == "MERGED" {
+			return models.PullRequest{}, ErrPRMerged
+		}
+	}
+
+	if parentID != "" {
+		// The parent must not already be stacked on top of prID
+		if parentID == prID {
+			return models.PullRequest{}, ErrDepCycle
+		}
+		ancestors, err := s.dependencyChain(tx, parentID)
+		if err != nil {
+			return models.PullRequest{}, err
+		}
+		ids := make([]string, 0, len(ancestors))
+		for _, a := range ancestors {
+			if a.ID == prID {
+				return models.PullRequest{}, ErrDepCycle
+			}
+			ids = append(ids, a.ID)
+		}
+		// The chain must stay as checked until the link is committed
+		if _, err := tx.Exec("SELECT 1 FROM prs WHERE pull_request_id = ANY($1) ORDER BY pull_request_id FOR UPDATE", pq.Array(ids)); err != nil {
+			return models.PullRequest{}, err
+		}
+	}
+
+	_, err = tx.Exec("UPDATE prs SET depends_on = NULLIF($1, '') WHERE pull_request_id = $2", parentID, prID)
+	if err != nil {
+		return models.PullRequest{}, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return models.PullRequest{}, err
+	}
+	return s.GetPR(prID)
+}
+
+// dependencyChain returns the ancestors of prID, direct parent first
+func (s *SQLStore) dependencyChain(q sqlx.Queryer, prID string) ([]models.PullRequestShort, error) {
+	var chain []models.PullRequestShort
+	err := sqlx.Select(q, &chain, `
+		WITH RECURSIVE chain AS (
+			SELECT depends_on AS id, 1 AS depth
+			FROM prs
+			WHERE pull_request_id = $1 AND depends_on IS NOT NULL
+			UNION ALL
+			SELECT p.depends_on, c.depth + 1
+			FROM prs p
+			JOIN chain c ON p.pull_request_id = c.id
+			WHERE p.depends_on IS NOT NULL AND c.depth < $2
+		)
+		SELECT p.pull_request_id, p.pull_request_name, p.author_id, p.status, p.priority
+		FROM chain c
+		JOIN prs p ON p.pull_request_id = c.id
+		ORDER BY c.depth`, prID, maxStackDepth)
+	return chain, err
+}
@@ -0,0 +1,41 @@
+package storage
+
+import (
+	"testing"
+
+	"pr-reviewer-service/internal/models"
+)
+
+func TestLinkPR(t *testing.T) {
+	s := newTestStore(t)
+	err := s.CreateTeam("backend", []models.User{
+		{UserID: "u1", Username: "Alice", IsActive: true},
+		{UserID: "u2", Username: "Bob", IsActive: true},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, id := range []string{"pr-1", "pr-2", "pr-3"} {
+		if _, err := s.CreatePR(newPR(id, "u1")); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if _, err := s.LinkPR("pr-2", "pr-1"); err != nil {
+		t.Fatal(err)
+	}
+	pr, err := s.LinkPR("pr-3", "pr-2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pr.DependencyChain) != 2 || pr.DependencyChain[0].ID != "pr-2" {
+		t.Errorf("Expected pr-3 to be stacked on pr-2 and pr-1, got %+v", pr.DependencyChain)
+	}
+	if _, err := s.LinkPR("pr-1", "pr-3"); err != ErrDepCycle {
+		t.Errorf("Expected ErrDepCycle, got %v", err)
+	}
+
+	if pr, err := s.LinkPR("pr-3", ""); err != nil || len(pr.DependencyChain) != 0 {
+		t.Errorf("Expected the link to be removed, got %+v (%v)", pr.DependencyChain, err)
+	}
+}
@@ -0,0 +1,162 @@
+package storage
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"pr-reviewer-service/internal/models"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// SetTeamEscalation configures the escalation window of a team. Zero minutes
+// turns escalation off; an empty user ID escalates to the team leads.
+func (s *SQLStore) SetTeamEscalation(teamName string, minutes int, userID string) (models.Team, error) {
+	if err := s.teamExists(teamName); err != nil {
+		return models.Team{}, err
+	}
+	if userID != "" {
+		var exists bool
+		err := s.db.Get(&exists, "SELECT EXISTS(SELECT 1 FROM users WHERE user_id = $1)", userID)
+		if err != nil {
+			return models.Team{}, err
+		}
+		if !exists {
+			return models.Team{}, ErrNotFound
+		}
+	}
+
+	_, err := s.db.Exec(
+		"UPDATE teams SET escalation_minutes = NULLIF($1, 0), escalation_user_id = NULLIF($2, '') WHERE name = $3",
+		minutes, userID, teamName,
+	)
+	if err != nil {
+		return models.Team{}, err
+	}
+	return s.GetTeam(teamName)
+}
+
+// EscalateStaleReviews adds an extra reviewer to PRs whose assignment was
+// neither accepted nor reviewed within the escalation window of the
+// reviewer's team. Each stalled assignment is escalated once, and reviewers
+// added by an escalation are never escalated themselves. A failed escalation
+// is reported with its error and the rest still run.
+func (s *SQLStore) EscalateStaleReviews() ([]models.Escalation, error) {
+	var stale []struct {
+		PRID       string `db:"pull_request_id"`
+		ReviewerID string `db:"user_id"`
+		TeamName   string `db:"team_name"`
+	}
+	err := s.db.Select(&stale, `
+		SELECT DISTINCT ON (r.pull_request_id, r.user_id) r.pull_request_id, r.user_id, t.name AS team_name
+		FROM pr_reviewers r
+		JOIN prs p ON p.pull_request_id = r.pull_request_id
+		JOIN team_members tm ON tm.user_id = r.user_id
+		JOIN teams t ON t.name = tm.team_name
+		WHERE p.status = 'OPEN'
+		AND tm.role <> 'observer'
+		AND t.escalation_minutes IS NOT NULL
+		AND r.accepted_at IS NULL
+		AND r.reviewed_at IS NULL
+		AND r.assigned_at < NOW() - make_interval(mins => t.escalation_minutes)
+		AND NOT EXISTS (
+			SELECT 1 FROM pr_escalations e
+			WHERE e.pull_request_id = r.pull_request_id AND e.stalled_reviewer_id = r.user_id
+		)
+		AND NOT EXISTS (
+			SELECT 1 FROM pr_escalations e
+			WHERE e.pull_request_id = r.pull_request_id AND e.escalated_to = r.user_id
+		)
+		ORDER BY r.pull_request_id, r.user_id, t.escalation_minutes`)
+	if err != nil {
+		return nil, err
+	}
+
+	escalations := []models.Escalation{}
+	var errs []error
+	for _, a := range stale {
+		// Every escalation gets its own transaction so one failure does not
+		// hold back the rest
+		tx, err := s.db.Beginx()
+		if err != nil {
+			return escalations, err
+		}
+		e, err := s.escalateInTx(tx, a.PRID, a.ReviewerID, a.TeamName)
+		if err == nil {
+			err = tx.Commit()
+		} else {
+			tx.Rollback()
+		}
+		if err != nil {
+			e = models.Escalation{PullRequestID: a.PRID, TeamName: a.TeamName, StalledReviewerID: a.ReviewerID, Error: err.Error()}
+			errs = append(errs, fmt.Errorf("escalate %s stalled on %s: %w", a.PRID, a.ReviewerID, err))
+		}
+		escalations = append(escalations, e)
+	}
+
+	return escalations, errors.Join(errs...)
+}
+
+func (s *SQLStore) escalateInTx(tx *sqlx.Tx, prID, stalledID, teamName string) (models.Escalation, error) {
+	escalatedTo, err := s.escalationTarget(tx, prID, teamName)
+	if err != nil {
+		return models.Escalation{}, err
+	}
+
+	if escalatedTo != "" {
+		_, err = tx.Exec("INSERT INTO pr_reviewers (pull_request_id, user_id) VALUES ($1, $2)", prID, escalatedTo)
+		if err != nil {
+			return models.Escalation{}, err
+		}
+		if err := s.setDueAt(tx, prID, escalatedTo); err != nil {
+			return models.Escalation{}, err
+		}
+		if err := s.queueReviewerSync(tx, prID); err != nil {
+			return models.Escalation{}, err
+		}
+	}
+
+	var e models.Escalation
+	err = tx.Get(&e, `
+		INSERT INTO pr_escalations (pull_request_id, team_name, stalled_reviewer_id, escalated_to)
+		VALUES ($1, $2, $3, NULLIF($4, ''))
+		RETURNING id, pull_request_id, team_name, stalled_reviewer_id, COALESCE(escalated_to, '') AS escalated_to, created_at`,
+		prID, teamName, stalledID, escalatedTo)
+	return e, err
+}
+
+// escalationTarget picks the team's escalation user, or else one of its
+// leads, applying the same eligibility rules as CreatePR: active, not the
+// author and not already reviewing. Declined reviewers are skipped too.
+// An empty result means nobody is eligible.
+func (s *SQLStore) escalationTarget(q sqlx.Queryer, prID, teamName string) (string, error) {
+	var userID string
+	err := sqlx.Get(q, &userID, `
+		SELECT u.user_id
+		FROM users u
+		JOIN teams t ON t.name = $2
+		LEFT JOIN team_members tm ON tm.team_name = t.name AND tm.user_id = u.user_id
+		WHERE (u.user_id = t.escalation_user_id OR (t.escalation_user_id IS NULL AND tm.role = 'lead'))
+		AND u.is_active = true
+		AND NOT u.is_bot
+		AND u.user_id <> (SELECT author_id FROM prs WHERE pull_request_id = $1)
+		AND u.user_id NOT IN (SELECT user_id FROM pr_reviewers WHERE pull_request_id = $1)
+		AND u.user_id NOT IN (SELECT user_id FROM pr_declines WHERE pull_request_id = $1)
+		ORDER BY u.user_id
+		LIMIT 1`, prID, teamName)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", nil
+	}
+	return userID, err
+}
+
+func (s *SQLStore) ListEscalations(teamName string) ([]models.Escalation, error) {
+	escalations := []models.Escalation{}
+	err := s.db.Select(&escalations, `
+		SELECT id, pull_request_id, team_name, stalled_reviewer_id, COALESCE(escalated_to, '') AS escalated_to, created_at
+		FROM pr_escalations
+		WHERE ($1 = '' OR team_name = $1)
+		ORDER BY created_at DESC`, teamName)
+	return escalations, err
+}
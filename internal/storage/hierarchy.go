@@ -0,0 +1,116 @@
+package storage
+
+import (
+	"pr-reviewer-service/internal/models"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// SetTeamParent moves a team under parentTeam; an empty parent makes it a
+// root team. A parent that is the team itself or one of its descendants is
+// refused with ErrTeamCycle.
+func (s *SQLStore) SetTeamParent(teamName, parentTeam string) (models.Team, error) {
+	if err := s.teamExists(teamName); err != nil {
+		return models.Team{}, err
+	}
+	if parentTeam != "" {
+		if err := s.teamExists(parentTeam); err != nil {
+			return models.Team{}, err
+		}
+		ancestors, err := s.teamAncestors(s.db, parentTeam)
+		if err != nil {
+			return models.Team{}, err
+		}
+		if parentTeam == teamName || contains(ancestors, teamName) {
+			return models.Team{}, ErrTeamCycle
+		}
+	}
+
+	_, err := s.db.Exec("UPDATE teams SET parent_team = NULLIF($1, '') WHERE name = $2", parentTeam, teamName)
+	if err != nil {
+		return models.Team{}, err
+	}
+	return s.GetTeam(teamName)
+}
+
+// teamAncestors returns the ancestors of a team from the root down to its parent
+func (s *SQLStore) teamAncestors(q sqlx.Queryer, teamName string) ([]string, error) {
+	ancestors := []string{}
+	err := sqlx.Select(q, &ancestors, `
+		WITH RECURSIVE up AS (
+			SELECT parent_team AS name, 1 AS depth FROM teams WHERE name = $1 AND parent_team IS NOT NULL
+			UNION
+			SELECT t.parent_team, up.depth + 1
+			FROM teams t JOIN up ON t.name = up.name
+			WHERE t.parent_team IS NOT NULL AND up.depth < 100
+		)
+		SELECT name FROM up ORDER BY depth DESC`, teamName)
+	return ancestors, err
+}
+
+func (s *SQLStore) teamChildren(q sqlx.Queryer, teamName string) ([]string, error) {
+	children := []string{}
+	err := sqlx.Select(q, &children, "SELECT name FROM teams WHERE parent_team = $1 ORDER BY name", teamName)
+	return children, err
+}
+
+// fallbackTeams lists where to look for reviewers once a team is exhausted:
+// its siblings, then its parent, then the parent's siblings and so on up
+// to the root
+func (s *SQLStore) fallbackTeams(q sqlx.Queryer, teamName string) ([]string, error) {
+	ancestors, err := s.teamAncestors(q, teamName)
+	if err != nil {
+		return nil, err
+	}
+
+	var teams []string
+	current := teamName
+	for i := len(ancestors) - 1; i >= 0; i-- {
+		parent := ancestors[i]
+		var siblings []string
+		err = sqlx.Select(q, &siblings,
+			"SELECT name FROM teams WHERE parent_team = $1 AND name <> $2 ORDER BY name", parent, current)
+		if err != nil {
+			return nil, err
+		}
+		teams = append(teams, siblings...)
+		teams = append(teams, parent)
+		current = parent
+	}
+	return teams, nil
+}
+
+// pickReviewersInTree is pickReviewers that tops up from the fallback teams
+// when the team itself has too few candidates
+func (s *SQLStore) pickReviewersInTree(q sqlx.Queryer, teamName string, exclude []string, limit int) ([]string, error) {
+	reviewers, err := s.pickReviewers(q, teamName, exclude, limit)
+	if err != nil || len(reviewers) >= limit {
+		return reviewers, err
+	}
+
+	teams, err := s.fallbackTeams(q, teamName)
+	if err != nil {
+		return nil, err
+	}
+	for _, team := range teams {
+		if len(reviewers) >= limit {
+			break
+		}
+		exclude := append(append([]string{}, exclude...), reviewers...)
+		more, err := s.pickReviewers(q, team, exclude, limit-len(reviewers))
+		if err != nil {
+			return nil, err
+		}
+		reviewers = append(reviewers, more...)
+	}
+	return reviewers, nil
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,230 @@
+package storage
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"pr-reviewer-service/internal/models"
+	"pr-reviewer-service/internal/policy"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// Reviews
+func (s *SQLStore) SubmitReview(prID, userID string, state models.ReviewState) (models.PullRequest, error) {
+	prID, err := s.resolvePRID(s.db, prID)
+	if err != nil {
+		return models.PullRequest{}, err
+	}
+
+	var status string
+	err = s.db.Get(&status, "SELECT status FROM prs WHERE pull_request_id = $1", prID)
+	if err != nil {
+		return models.PullRequest{}, ErrNotFound
+	}
+	if status == "MERGED" {
+		return models.PullRequest{}, ErrPRMerged
+	}
+
+	// Reviewing implies the assignment was accepted
+	result, err := s.db.Exec(`
+		UPDATE pr_reviewers
+		SET state = $1, reviewed_at = $2, accepted_at = COALESCE(accepted_at, $2),
+		    first_reviewed_at = COALESCE(first_reviewed_at, $2)
+		WHERE pull_request_id = $3 AND user_id = $4`,
+		state, time.Now(), prID, userID,
+	)
+	if err != nil {
+		return models.PullRequest{}, err
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return models.PullRequest{}, ErrNotAssigned
+	}
+
+	if err := s.recordLateReview(prID, userID); err != nil {
+		return models.PullRequest{}, err
+	}
+
+	return s.GetPR(prID)
+}
+
+// Merge policies
+
+// mergePolicyColumns is the column list scanned into models.MergePolicy
+const mergePolicyColumns = `COALESCE(team_name, '') AS team_name, COALESCE(repository, '') AS repository,
+	min_approvals, block_on_changes_requested, min_age_minutes,
+	COALESCE(required_reviewer_team, '') AS required_reviewer_team`
+
+// mergeFreezeColumns is the column list scanned into models.MergeFreeze
+const mergeFreezeColumns = "id, COALESCE(team_name, '') AS team_name, starts_at, ends_at, reason"
+
+func (s *SQLStore) SetMergePolicy(p models.MergePolicy) (models.MergePolicy, error) {
+	// A policy is scoped to exactly one team or repository
+	scope, name := "team_name", p.TeamName
+	if p.Repository != "" {
+		scope, name = "repository", p.Repository
+		if _, err := s.GetRepository(p.Repository); err != nil {
+			return models.MergePolicy{}, err
+		}
+	} else if err := s.teamExists(p.TeamName); err != nil {
+		return models.MergePolicy{}, err
+	}
+	if p.RequiredReviewerTeam != "" {
+		if err := s.teamExists(p.RequiredReviewerTeam); err != nil {
+			return models.MergePolicy{}, err
+		}
+	}
+
+	_, err := s.db.Exec(fmt.Sprintf(`
+		INSERT INTO merge_policies (%[1]s, min_approvals, block_on_changes_requested, min_age_minutes, required_reviewer_team)
+		VALUES ($1, $2, $3, $4, NULLIF($5, ''))
+		ON CONFLICT (%[1]s) DO UPDATE SET
+			min_approvals = EXCLUDED.min_approvals,
+			block_on_changes_requested = EXCLUDED.block_on_changes_requested,
+			min_age_minutes = EXCLUDED.min_age_minutes,
+			required_reviewer_team = EXCLUDED.required_reviewer_team`, scope),
+		name, p.MinApprovals, p.BlockOnChangesRequested, p.MinAgeMinutes, p.RequiredReviewerTeam)
+	if err != nil {
+		return models.MergePolicy{}, err
+	}
+
+	return s.getMergePolicy(s.db, scope, name)
+}
+
+func (s *SQLStore) GetMergePolicy(teamName string) (models.MergePolicy, error) {
+	return s.getMergePolicy(s.db, "team_name", teamName)
+}
+
+func (s *SQLStore) getMergePolicy(q sqlx.Queryer, scope, name string) (models.MergePolicy, error) {
+	var p models.MergePolicy
+	err := sqlx.Get(q, &p, fmt.Sprintf(`
+		SELECT `+mergePolicyColumns+`
+		FROM merge_policies
+		WHERE %s = $1`, scope), name)
+	if errors.Is(err, sql.ErrNoRows) {
+		return models.MergePolicy{}, ErrNotFound
+	}
+	return p, err
+}
+
+func (s *SQLStore) AddMergeFreeze(f models.MergeFreeze) (models.MergeFreeze, error) {
+	if f.TeamName != "" {
+		if err := s.teamExists(f.TeamName); err != nil {
+			return models.MergeFreeze{}, err
+		}
+	}
+
+	err := s.db.Get(&f.ID, `
+		INSERT INTO merge_freezes (team_name, starts_at, ends_at, reason)
+		VALUES (NULLIF($1, ''), $2, $3, $4)
+		RETURNING id`,
+		f.TeamName, f.StartsAt, f.EndsAt, f.Reason)
+	if err != nil {
+		return models.MergeFreeze{}, err
+	}
+
+	return f, nil
+}
+
+// ListMergeFreezes returns freezes that have not ended yet. An empty team name
+// returns every freeze, otherwise the team's own and the global ones.
+func (s *SQLStore) ListMergeFreezes(teamName string) ([]models.MergeFreeze, error) {
+	freezes := []models.MergeFreeze{}
+	err := s.db.Select(&freezes, `
+		SELECT `+mergeFreezeColumns+`
+		FROM merge_freezes
+		WHERE ends_at > NOW()
+		AND ($1 = '' OR team_name IS NULL OR team_name = $1)
+		ORDER BY starts_at`, teamName)
+	return freezes, err
+}
+
+// evaluateMergePolicy checks the PR against its policy and active freezes.
+// A repository policy takes precedence over the team one; the team is the
+// repository's owning team or, failing that, the author's team. MergePR
+// runs it inside the merge transaction so it sees the state it merges on.
+func (s *SQLStore) evaluateMergePolicy(q sqlx.Queryer, prID string) ([]models.RuleViolation, error) {
+	var pr struct {
+		AuthorID   string     `db:"author_id"`
+		Repository string     `db:"repository"`
+		CreatedAt  *time.Time `db:"created_at"`
+	}
+	err := sqlx.Get(q, &pr, "SELECT author_id, COALESCE(repository, '') AS repository, created_at FROM prs WHERE pull_request_id = $1", prID)
+	if err != nil {
+		return nil, err
+	}
+
+	var teamName string
+	if pr.Repository != "" {
+		err = sqlx.Get(q, &teamName, "SELECT COALESCE(owning_team, '') FROM repositories WHERE name = $1", pr.Repository)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if teamName == "" {
+		err = sqlx.Get(q, &teamName, "SELECT team_name FROM team_members WHERE user_id = $1 ORDER BY team_name LIMIT 1", pr.AuthorID)
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			return nil, err
+		}
+	}
+
+	p, err := s.getMergePolicy(q, "repository", pr.Repository)
+	if errors.Is(err, ErrNotFound) {
+		p, err = s.getMergePolicy(q, "team_name", teamName)
+	}
+	if err != nil && !errors.Is(err, ErrNotFound) {
+		return nil, err
+	}
+
+	in := policy.Input{
+		CreatedAt:           pr.CreatedAt,
+		RequiredTeamMembers: map[string]bool{},
+		Now:                 time.Now(),
+	}
+
+	// Reviews are locked so none changes until the merge commits
+	err = sqlx.Select(q, &in.Reviews, `
+		SELECT user_id, state, reviewed_at, assigned_at, accepted_at, due_at
+		FROM pr_reviewers
+		WHERE pull_request_id = $1
+		FOR SHARE`, prID)
+	if err != nil {
+		return nil, err
+	}
+
+	if p.RequiredReviewerTeam != "" {
+		var members []string
+		err = sqlx.Select(q, &members, "SELECT user_id FROM team_members WHERE team_name = $1", p.RequiredReviewerTeam)
+		if err != nil {
+			return nil, err
+		}
+		for _, m := range members {
+			in.RequiredTeamMembers[m] = true
+		}
+	}
+
+	err = sqlx.Select(q, &in.Freezes, `
+		SELECT id, COALESCE(team_name, '') AS team_name, starts_at, ends_at, reason
+		FROM merge_freezes
+		WHERE ends_at > NOW()
+		AND (team_name IS NULL OR team_name = $1)`, teamName)
+	if err != nil {
+		return nil, err
+	}
+
+	return policy.Evaluate(p, in), nil
+}
+
+func (s *SQLStore) teamExists(name string) error {
+	var exists bool
+	err := s.db.Get(&exists, "SELECT EXISTS(SELECT 1 FROM teams WHERE name = $1)", name)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return ErrNotFound
+	}
+	return nil
+}
@@ -0,0 +1,180 @@
+package storage
+
+import (
+	"database/sql"
+	"errors"
+	"strconv"
+
+	"pr-reviewer-service/internal/models"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+)
+
+// Operation kinds that can be reverted
+const (
+	opMassDeactivate = "mass_deactivate"
+	opReassign       = "reassign"
+)
+
+// Reasons a reviewer swap is left in place on revert
+const (
+	revertPRClosed         = "PR_NOT_OPEN"
+	revertReviewerChanged  = "REVIEWER_CHANGED"
+	revertReviewerInactive = "REVIEWER_INACTIVE"
+)
+
+// recordOperation stores the change set of an operation so that it can be
+// reverted later: the users it deactivated and the reviewers it swapped
+func (s *SQLStore) recordOperation(tx *sqlx.Tx, kind, target string, deactivated []string, swaps []models.Reassignment) (int64, error) {
+	var id int64
+	err := tx.Get(&id, "INSERT INTO operations (kind, target) VALUES ($1, $2) RETURNING id", kind, target)
+	if err != nil {
+		return 0, err
+	}
+
+	for _, userID := range deactivated {
+		_, err := tx.Exec("INSERT INTO operation_changes (operation_id, user_id) VALUES ($1, $2)", id, userID)
+		if err != nil {
+			return 0, err
+		}
+	}
+	for _, swap := range swaps {
+		if swap.NewReviewerID == "" {
+			continue
+		}
+		_, err := tx.Exec(
+			"INSERT INTO operation_changes (operation_id, user_id, pull_request_id, new_reviewer_id) VALUES ($1, $2, $3, $4)",
+			id, swap.OldReviewerID, swap.PullRequestID, swap.NewReviewerID,
+		)
+		if err != nil {
+			return 0, err
+		}
+	}
+	return id, nil
+}
+
+// RevertOperation reactivates the users an operation deactivated and puts
+// the original reviewers back. A swap is only undone while the PR is open
+// and the replacement still holds the assignment; the rest is reported.
+func (s *SQLStore) RevertOperation(id int64) (models.RevertResult, error) {
+	result := models.RevertResult{
+		OperationID: id,
+		Reactivated: []string{},
+		Restored:    []models.Reassignment{},
+		NotReverted: []models.Reassignment{},
+		ReviewState: models.ReviewPending,
+	}
+
+	tx, err := s.db.Beginx()
+	if err != nil {
+		return result, err
+	}
+	defer tx.Rollback()
+
+	var reverted sql.NullTime
+	err = tx.Get(&reverted, "SELECT reverted_at FROM operations WHERE id = $1 FOR UPDATE", id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return result, ErrNotFound
+	}
+	if err != nil {
+		return result, err
+	}
+	if reverted.Valid {
+		return result, ErrReverted
+	}
+
+	var changes []struct {
+		UserID        string         `db:"user_id"`
+		PullRequestID sql.NullString `db:"pull_request_id"`
+		NewReviewerID sql.NullString `db:"new_reviewer_id"`
+	}
+	err = tx.Select(&changes, `
+		SELECT user_id, pull_request_id, new_reviewer_id
+		FROM operation_changes
+		WHERE operation_id = $1
+		ORDER BY id DESC`, id)
+	if err != nil {
+		return result, err
+	}
+
+	// Users first, so that restored reviewers are active again
+	var users []string
+	for _, c := range changes {
+		if !c.PullRequestID.Valid {
+			users = append(users, c.UserID)
+		}
+	}
+	err = tx.Select(&result.Reactivated, `
+		UPDATE users SET is_active = true
+		WHERE user_id = ANY($1) AND NOT is_active
+		RETURNING user_id`, pq.Array(users))
+	if err != nil {
+		return result, err
+	}
+
+	for _, c := range changes {
+		if !c.PullRequestID.Valid {
+			continue
+		}
+		r := models.Reassignment{PullRequestID: c.PullRequestID.String, OldReviewerID: c.NewReviewerID.String, NewReviewerID: c.UserID}
+		reason, err := s.revertBlocker(tx, r)
+		if err != nil {
+			return result, err
+		}
+		if reason != "" {
+			r.Reason = reason
+			result.NotReverted = append(result.NotReverted, r)
+			continue
+		}
+		if err := s.replaceReviewer(tx, r.PullRequestID, r.OldReviewerID, r.NewReviewerID); err != nil {
+			return result, err
+		}
+		result.Restored = append(result.Restored, r)
+	}
+
+	if _, err := tx.Exec("UPDATE operations SET reverted_at = NOW() WHERE id = $1", id); err != nil {
+		return result, err
+	}
+	if err := s.audit(tx, "revert_operation", "admin", strconv.FormatInt(id, 10), result); err != nil {
+		return result, err
+	}
+	return result, tx.Commit()
+}
+
+// revertBlocker explains why the swap r (replacement -> original) can no
+// longer be undone, or returns "" when it can
+func (s *SQLStore) revertBlocker(tx *sqlx.Tx, r models.Reassignment) (string, error) {
+	var status string
+	err := tx.Get(&status, "SELECT status FROM prs WHERE pull_request_id = $1", r.PullRequestID)
+	if err != nil {
+		return "", err
+	}
+	if status != string(models.OPEN) {
+		return revertPRClosed, nil
+	}
+
+	// The original reviewer may have been deactivated or turned into a bot
+	// by something other than the operation being reverted
+	var original models.User
+	err = tx.Get(&original, "SELECT is_active, is_bot FROM users WHERE user_id = $1", r.NewReviewerID)
+	if err != nil {
+		return "", err
+	}
+	if !original.IsActive || original.IsBot {
+		return revertReviewerInactive, nil
+	}
+
+	var current []string
+	err = tx.Select(&current, "SELECT user_id FROM pr_reviewers WHERE pull_request_id = $1", r.PullRequestID)
+	if err != nil {
+		return "", err
+	}
+	if !contains(current, r.OldReviewerID) {
+		return revertReviewerChanged, nil
+	}
+	if contains(current, r.NewReviewerID) {
+		return ErrAssigned.Error(), nil
+	}
+	return "", nil
+}
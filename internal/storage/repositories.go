@@ -0,0 +1,86 @@
+package storage
+
+import (
+	"database/sql"
+	"errors"
+
+	"pr-reviewer-service/internal/models"
+)
+
+// Repositories
+func (s *SQLStore) CreateRepository(repo models.Repository) (models.Repository, error) {
+	if repo.OwningTeam != "" {
+		if err := s.teamExists(repo.OwningTeam); err != nil {
+			return models.Repository{}, err
+		}
+	}
+
+	result, err := s.db.Exec(`
+		INSERT INTO repositories (name, owning_team, reviewer_count, provider)
+		VALUES ($1, NULLIF($2, ''), $3, NULLIF($4, ''))
+		ON CONFLICT (name) DO NOTHING`,
+		repo.Name, repo.OwningTeam, repo.ReviewerCount, repo.Provider)
+	if err != nil {
+		return models.Repository{}, err
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return models.Repository{}, ErrRepoExists
+	}
+
+	return s.GetRepository(repo.Name)
+}
+
+func (s *SQLStore) GetRepository(name string) (models.Repository, error) {
+	var repo models.Repository
+	err := s.db.Get(&repo, `
+		SELECT name, COALESCE(owning_team, '') AS owning_team, reviewer_count, COALESCE(provider, '') AS provider
+		FROM repositories
+		WHERE name = $1`, name)
+	if errors.Is(err, sql.ErrNoRows) {
+		return repo, ErrNotFound
+	}
+	if err != nil {
+		return repo, err
+	}
+
+	p, err := s.getMergePolicy(s.db, "repository", name)
+	if err == nil {
+		repo.MergePolicy = &p
+	} else if !errors.Is(err, ErrNotFound) {
+		return repo, err
+	}
+
+	return repo, nil
+}
+
+// UpdateRepository replaces the repository's settings; an empty owning team
+// or provider is stored as NULL
+func (s *SQLStore) UpdateRepository(repo models.Repository) (models.Repository, error) {
+	if repo.OwningTeam != "" {
+		if err := s.teamExists(repo.OwningTeam); err != nil {
+			return models.Repository{}, err
+		}
+	}
+
+	result, err := s.db.Exec(
+		"UPDATE repositories SET owning_team = NULLIF($1, ''), reviewer_count = $2, provider = NULLIF($3, '') WHERE name = $4",
+		repo.OwningTeam, repo.ReviewerCount, repo.Provider, repo.Name,
+	)
+	if err != nil {
+		return models.Repository{}, err
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return models.Repository{}, ErrNotFound
+	}
+
+	return s.GetRepository(repo.Name)
+}
+
+func (s *SQLStore) ListRepositories() ([]models.Repository, error) {
+	repos := []models.Repository{}
+	err := s.db.Select(&repos, `
+		SELECT name, COALESCE(owning_team, '') AS owning_team, reviewer_count, COALESCE(provider, '') AS provider
+		FROM repositories
+		ORDER BY name`)
+	return repos, err
+}
@@ -0,0 +1,200 @@
+package storage
+
+import (
+	"time"
+
+	"pr-reviewer-service/internal/models"
+)
+
+// ackTimeoutReason is recorded for assignments reassigned by the timeout worker
+const ackTimeoutReason = "acknowledgement timeout"
+
+// RespondToAssignment accepts or declines a review assignment. A decline
+// hands the PR to a replacement and returns its user ID.
+func (s *SQLStore) RespondToAssignment(prID, userID string, accept bool, reason string) (models.PullRequest, string, error) {
+	prID, err := s.resolvePRID(s.db, prID)
+	if err != nil {
+		return models.PullRequest{}, "", err
+	}
+
+	if accept {
+		var status string
+		err = s.db.Get(&status, "SELECT status FROM prs WHERE pull_request_id = $1", prID)
+		if err != nil {
+			return models.PullRequest{}, "", ErrNotFound
+		}
+		if err := openPRStatus(status); err != nil {
+			return models.PullRequest{}, "", err
+		}
+
+		result, err := s.db.Exec(
+			"UPDATE pr_reviewers SET accepted_at = COALESCE(accepted_at, NOW()) WHERE pull_request_id = $1 AND user_id = $2",
+			prID, userID,
+		)
+		if err != nil {
+			return models.PullRequest{}, "", err
+		}
+		if n, _ := result.RowsAffected(); n == 0 {
+			return models.PullRequest{}, "", ErrNotAssigned
+		}
+
+		pr, err := s.GetPR(prID)
+		return pr, "", err
+	}
+
+	tx, err := s.db.Beginx()
+	if err != nil {
+		return models.PullRequest{}, "", err
+	}
+	defer tx.Rollback()
+
+	newReviewerID, err := s.reassignInTx(tx, prID, userID)
+	if err != nil {
+		return models.PullRequest{}, "", err
+	}
+
+	_, err = tx.Exec(`
+		INSERT INTO pr_declines (pull_request_id, user_id, reason) VALUES ($1, $2, $3)
+		ON CONFLICT (pull_request_id, user_id) DO UPDATE SET reason = EXCLUDED.reason, declined_at = NOW()`,
+		prID, userID, reason)
+	if err != nil {
+		return models.PullRequest{}, "", err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return models.PullRequest{}, "", err
+	}
+
+	pr, err := s.GetPR(prID)
+	return pr, newReviewerID, err
+}
+
+// ReassignStaleAssignments treats assignments that were neither accepted nor
+// reviewed within timeout as declined and hands them over. Assignments
+// without a replacement candidate are reported, left in place and retried
+// once another timeout has passed since the failed attempt. Reviewers added
+// by an escalation are left alone.
+func (s *SQLStore) ReassignStaleAssignments(timeout time.Duration) ([]models.Reassignment, error) {
+	var stale []struct {
+		PRID       string `db:"pull_request_id"`
+		ReviewerID string `db:"user_id"`
+	}
+	err := s.db.Select(&stale, `
+		SELECT r.pull_request_id, r.user_id
+		FROM pr_reviewers r
+		JOIN prs p ON p.pull_request_id = r.pull_request_id
+		WHERE p.status = 'OPEN'
+		AND r.accepted_at IS NULL
+		AND r.reviewed_at IS NULL
+		AND (r.ack_timeout_failed_at IS NULL OR r.ack_timeout_failed_at < $1)
+		AND r.assigned_at < $1
+		AND NOT EXISTS (
+			SELECT 1 FROM pr_escalations e
+			WHERE e.pull_request_id = r.pull_request_id AND e.escalated_to = r.user_id
+		)`,
+		time.Now().Add(-timeout))
+	if err != nil {
+		return nil, err
+	}
+
+	reassignments := []models.Reassignment{}
+	for _, a := range stale {
+		r := models.Reassignment{PullRequestID: a.PRID, OldReviewerID: a.ReviewerID}
+
+		// Every hand-over gets its own transaction so one failure does not
+		// hold back the rest
+		tx, err := s.db.Beginx()
+		if err != nil {
+			return reassignments, err
+		}
+		r.NewReviewerID, err = s.reassignInTx(tx, a.PRID, a.ReviewerID)
+		if err == nil {
+			_, err = tx.Exec(`
+				INSERT INTO pr_declines (pull_request_id, user_id, reason, automatic) VALUES ($1, $2, $3, true)
+				ON CONFLICT (pull_request_id, user_id) DO NOTHING`,
+				a.PRID, a.ReviewerID, ackTimeoutReason)
+		}
+		if err == nil {
+			err = tx.Commit()
+		} else {
+			tx.Rollback()
+		}
+		if err != nil {
+			r.NewReviewerID = ""
+			r.Reason = err.Error()
+		}
+		if err == ErrNoCandidate || err == ErrNotFound {
+			_, err = s.db.Exec(
+				"UPDATE pr_reviewers SET ack_timeout_failed_at = NOW() WHERE pull_request_id = $1 AND user_id = $2",
+				a.PRID, a.ReviewerID)
+			if err != nil {
+				return reassignments, err
+			}
+		}
+		reassignments = append(reassignments, r)
+	}
+
+	return reassignments, nil
+}
+
+// AddReviewer assigns a reviewer by hand on top of the automatic ones. Any
+// active user but the author can be added, observers included.
+func (s *SQLStore) AddReviewer(prID, userID string) (models.PullRequest, error) {
+	prID, err := s.resolvePRID(s.db, prID)
+	if err != nil {
+		return models.PullRequest{}, err
+	}
+
+	tx, err := s.db.Beginx()
+	if err != nil {
+		return models.PullRequest{}, err
+	}
+	defer tx.Rollback()
+
+	var pr struct {
+		Status   string `db:"status"`
+		AuthorID string `db:"author_id"`
+	}
+	if err := tx.Get(&pr, "SELECT status, author_id FROM prs WHERE pull_request_id = $1", prID); err != nil {
+		return models.PullRequest{}, ErrNotFound
+	}
+	if err := openPRStatus(pr.Status); err != nil {
+		return models.PullRequest{}, err
+	}
+	if pr.AuthorID == userID {
+		return models.PullRequest{}, ErrAuthor
+	}
+
+	var user struct {
+		IsActive bool `db:"is_active"`
+		IsBot    bool `db:"is_bot"`
+	}
+	if err := tx.Get(&user, "SELECT is_active, is_bot FROM users WHERE user_id = $1", userID); err != nil || !user.IsActive {
+		return models.PullRequest{}, ErrNotFound
+	}
+	if user.IsBot {
+		return models.PullRequest{}, ErrBot
+	}
+
+	result, err := tx.Exec(
+		"INSERT INTO pr_reviewers (pull_request_id, user_id) VALUES ($1, $2) ON CONFLICT DO NOTHING",
+		prID, userID,
+	)
+	if err != nil {
+		return models.PullRequest{}, err
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return models.PullRequest{}, ErrAssigned
+	}
+	if err := s.setDueAt(tx, prID, userID); err != nil {
+		return models.PullRequest{}, err
+	}
+	if err := s.queueReviewerSync(tx, prID); err != nil {
+		return models.PullRequest{}, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return models.PullRequest{}, err
+	}
+	return s.GetPR(prID)
+}
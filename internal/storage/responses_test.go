@@ -0,0 +1,84 @@
+package storage
+
+import (
+	"testing"
+	"time"
+
+	"pr-reviewer-service/internal/models"
+)
+
+func TestReassignStaleAssignmentsBackoff(t *testing.T) {
+	s := newTestStore(t)
+
+	err := s.CreateTeam("backend", []models.User{
+		{UserID: "u1", Username: "Alice", IsActive: true},
+		{UserID: "u2", Username: "Bob", IsActive: true},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.CreatePR(newPR("pr-1", "u1")); err != nil {
+		t.Fatal(err)
+	}
+	s.db.MustExec("UPDATE pr_reviewers SET assigned_at = NOW() - INTERVAL '2 hours'")
+
+	// u2 is the only candidate, so the hand-over fails and is marked
+	got, err := s.ReassignStaleAssignments(time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 1 || got[0].OldReviewerID != "u2" || got[0].NewReviewerID != "" || got[0].Reason == "" {
+		t.Fatalf("Expected a failed hand-over of u2, got %+v", got)
+	}
+
+	// A candidate showing up does not trigger a retry before the backoff
+	if _, err := s.AddTeamMember("backend", models.User{UserID: "u3", Username: "Carol", IsActive: true}); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := s.ReassignStaleAssignments(time.Hour); err != nil || len(got) != 0 {
+		t.Fatalf("Expected no retry within the backoff, got %+v (%v)", got, err)
+	}
+
+	// Once another timeout has passed, the assignment is retried
+	s.db.MustExec("UPDATE pr_reviewers SET ack_timeout_failed_at = NOW() - INTERVAL '2 hours'")
+	got, err = s.ReassignStaleAssignments(time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 1 || got[0].NewReviewerID != "u3" {
+		t.Fatalf("Expected u2 to be handed over to u3, got %+v", got)
+	}
+	var failedAt *time.Time
+	if err := s.db.Get(&failedAt, "SELECT ack_timeout_failed_at FROM pr_reviewers WHERE pull_request_id = 'pr-1' AND user_id = 'u3'"); err != nil || failedAt != nil {
+		t.Errorf("Expected the new assignment to start without a failure marker, got %v (%v)", failedAt, err)
+	}
+}
+
+func TestReassignStaleAssignmentsMarksReviewersWithoutTeam(t *testing.T) {
+	s := newTestStore(t)
+
+	err := s.CreateTeam("backend", []models.User{
+		{UserID: "u1", Username: "Alice", IsActive: true},
+		{UserID: "u2", Username: "Bob", IsActive: true},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.CreatePR(newPR("pr-1", "u1")); err != nil {
+		t.Fatal(err)
+	}
+	// The reviewer has no team to pick a replacement from
+	s.db.MustExec("DELETE FROM team_members WHERE user_id = 'u2'")
+	s.db.MustExec("UPDATE pr_reviewers SET assigned_at = NOW() - INTERVAL '2 hours'")
+
+	got, err := s.ReassignStaleAssignments(time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 1 || got[0].NewReviewerID != "" {
+		t.Fatalf("Expected a failed hand-over of u2, got %+v", got)
+	}
+	if got, err := s.ReassignStaleAssignments(time.Hour); err != nil || len(got) != 0 {
+		t.Errorf("Expected the teamless reviewer to wait for the backoff, got %+v (%v)", got, err)
+	}
+}
@@ -0,0 +1,92 @@
+package storage
+
+import (
+	"pr-reviewer-service/internal/models"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+)
+
+// queueReviewerSync marks the reviewers of the PR for pushing to the code
+// host. PRs of repositories without a provider are left alone.
+func (s *SQLStore) queueReviewerSync(q sqlx.Execer, prID string) error {
+	_, err := q.Exec(`
+		UPDATE prs p
+		SET reviewer_sync = 'PENDING', reviewer_sync_error = NULL, reviewer_sync_attempts = 0,
+		    reviewer_sync_queued_at = clock_timestamp(), reviewer_sync_next_at = NOW()
+		FROM repositories r
+		WHERE r.name = p.repository AND r.provider IS NOT NULL AND p.pull_request_id = $1`, prID)
+	return err
+}
+
+// PendingReviewerSyncs returns up to limit open PRs whose reviewers are due
+// to be pushed, with the reviewers' aliases at the provider
+func (s *SQLStore) PendingReviewerSyncs(limit int) ([]models.ReviewerSyncTask, error) {
+	tasks := []models.ReviewerSyncTask{}
+	err := s.db.Select(&tasks, `
+		SELECT p.pull_request_id, p.repository, p.number, r.provider,
+		       p.reviewer_sync_attempts, p.reviewer_sync_queued_at
+		FROM prs p
+		JOIN repositories r ON r.name = p.repository
+		WHERE p.reviewer_sync = 'PENDING'
+		AND p.reviewer_sync_next_at <= NOW()
+		AND p.status = 'OPEN'
+		AND r.provider IS NOT NULL
+		ORDER BY p.reviewer_sync_next_at
+		LIMIT $1`, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range tasks {
+		var reviewers []struct {
+			UserID     string `db:"user_id"`
+			ExternalID string `db:"external_id"`
+		}
+		err := s.db.Select(&reviewers, `
+			SELECT r.user_id, COALESCE(MIN(a.external_id), '') AS external_id
+			FROM pr_reviewers r
+			LEFT JOIN user_aliases a ON a.user_id = r.user_id AND a.provider = $2
+			WHERE r.pull_request_id = $1
+			GROUP BY r.user_id
+			ORDER BY r.user_id`, tasks[i].PullRequestID, tasks[i].Provider)
+		if err != nil {
+			return nil, err
+		}
+		var pushed pq.StringArray
+		err = s.db.Get(&pushed, "SELECT reviewer_sync_pushed FROM prs WHERE pull_request_id = $1", tasks[i].PullRequestID)
+		if err != nil {
+			return nil, err
+		}
+		tasks[i].Pushed = pushed
+		tasks[i].Logins, tasks[i].Missing = []string{}, []string{}
+		for _, r := range reviewers {
+			if r.ExternalID == "" {
+				tasks[i].Missing = append(tasks[i].Missing, r.UserID)
+			} else {
+				tasks[i].Logins = append(tasks[i].Logins, r.ExternalID)
+			}
+		}
+	}
+	return tasks, nil
+}
+
+// FinishReviewerSync records the outcome of a push. The status is dropped
+// when the reviewers changed in the meantime, since the newer assignment is
+// queued; the pushed logins are kept either way.
+func (s *SQLStore) FinishReviewerSync(t models.ReviewerSyncTask) error {
+	_, err := s.db.Exec(
+		"UPDATE prs SET reviewer_sync_pushed = $1 WHERE pull_request_id = $2",
+		pq.Array(t.Pushed), t.PullRequestID)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.db.Exec(`
+		UPDATE prs
+		SET reviewer_sync = $1, reviewer_sync_error = NULLIF($2, ''),
+		    reviewer_sync_attempts = reviewer_sync_attempts + 1, reviewer_sync_next_at = $3
+		WHERE pull_request_id = $4 AND reviewer_sync_queued_at = $5`,
+		t.Status, t.Error, t.NextAttempt, t.PullRequestID, t.QueuedAt)
+	return err
+}
@@ -0,0 +1,401 @@
+package storage
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"pr-reviewer-service/internal/models"
+	"pr-reviewer-service/internal/roster"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+)
+
+// InvalidRosterError lists every problem that stopped an import
+type InvalidRosterError struct {
+	Problems []string
+}
+
+func (e *InvalidRosterError) Error() string {
+	return "INVALID_ROSTER"
+}
+
+// ImportRoster applies a roster in a single transaction: users are created
+// or updated, teams created and their members reconciled (removed members'
+// open reviews are handed over), then parents and settings applied. Users
+// and teams missing from the roster are left alone. A dry run validates and
+// computes the same result, then rolls back.
+func (s *SQLStore) ImportRoster(r roster.Roster, dryRun bool) (models.ImportResult, error) {
+	result := models.ImportResult{
+		DryRun:        dryRun,
+		UsersCreated:  []string{},
+		UsersUpdated:  []models.MemberChange{},
+		Teams:         []models.TeamDiff{},
+		Reassignments: []models.Reassignment{},
+	}
+
+	problems := r.Validate()
+	more, err := s.checkRosterReferences(r)
+	if err != nil {
+		return result, err
+	}
+	if problems = append(problems, more...); len(problems) > 0 {
+		return result, &InvalidRosterError{Problems: problems}
+	}
+
+	tx, err := s.db.Beginx()
+	if err != nil {
+		return result, err
+	}
+	defer tx.Rollback()
+
+	users := map[string]roster.User{}
+	for _, u := range r.Users {
+		users[u.UserID] = u
+		change, reassignments, created, err := s.upsertRosterUser(tx, u)
+		if err != nil {
+			return result, err
+		}
+		result.Reassignments = append(result.Reassignments, reassignments...)
+		if created {
+			result.UsersCreated = append(result.UsersCreated, u.UserID)
+		} else if len(change.Changes) > 0 {
+			result.UsersUpdated = append(result.UsersUpdated, change)
+		}
+	}
+
+	for _, t := range r.Teams {
+		members := make([]models.MemberSpec, 0, len(t.Members))
+		for _, m := range t.Members {
+			u := users[m.UserID]
+			active := u.Active()
+			members = append(members, models.MemberSpec{UserID: u.UserID, Username: u.Username, IsActive: &active, Role: m.Role})
+		}
+		diff, err := s.reconcileInTx(tx, t.Name, members)
+		if err != nil {
+			return result, err
+		}
+		result.Teams = append(result.Teams, diff)
+	}
+
+	// Parents and settings once every team exists
+	for i, t := range r.Teams {
+		diff := &result.Teams[i]
+
+		var parent string
+		if err := tx.Get(&parent, "SELECT COALESCE(parent_team, '') FROM teams WHERE name = $1", t.Name); err != nil {
+			return result, err
+		}
+		if parent != t.ParentTeam {
+			_, err := tx.Exec("UPDATE teams SET parent_team = NULLIF($1, '') WHERE name = $2", t.ParentTeam, t.Name)
+			if err != nil {
+				return result, err
+			}
+			diff.ParentTeam = &models.FieldChange{Field: "parent_team", Old: parent, New: t.ParentTeam}
+		}
+
+		if t.Settings != nil {
+			if err := s.applyTeamSettings(tx, t.Name, *t.Settings); err != nil {
+				return result, err
+			}
+			diff.SettingsReplaced = true
+		}
+	}
+
+	// Bot teams once every team exists
+	for _, u := range r.Users {
+		_, err := tx.Exec(
+			"UPDATE users SET bot_team = NULLIF($1, '') WHERE user_id = $2 AND bot_team IS DISTINCT FROM NULLIF($1, '')",
+			u.BotTeam, u.UserID)
+		if err != nil {
+			return result, err
+		}
+	}
+
+	// Parents outside of the roster can still close a loop
+	for _, t := range r.Teams {
+		ancestors, err := s.teamAncestors(tx, t.Name)
+		if err != nil {
+			return result, err
+		}
+		if contains(ancestors, t.Name) {
+			return result, &InvalidRosterError{Problems: []string{fmt.Sprintf("team %s: parent_team forms a cycle", t.Name)}}
+		}
+	}
+
+	if dryRun {
+		return result, nil
+	}
+
+	summary := map[string]int{
+		"users_created": len(result.UsersCreated),
+		"users_updated": len(result.UsersUpdated),
+		"teams":         len(result.Teams),
+	}
+	if err := s.audit(tx, "roster.import", "admin", "roster", summary); err != nil {
+		return result, err
+	}
+	return result, tx.Commit()
+}
+
+// checkRosterReferences reports parent teams, bot teams and escalation users
+// that are neither in the roster nor in the database
+func (s *SQLStore) checkRosterReferences(r roster.Roster) ([]string, error) {
+	var problems []string
+
+	teams := map[string]bool{}
+	for _, t := range r.Teams {
+		teams[t.Name] = true
+	}
+	users := map[string]bool{}
+	for _, u := range r.Users {
+		users[u.UserID] = true
+	}
+
+	for _, t := range r.Teams {
+		if t.ParentTeam != "" && !teams[t.ParentTeam] {
+			err := s.teamExists(t.ParentTeam)
+			if errors.Is(err, ErrNotFound) {
+				problems = append(problems, fmt.Sprintf("team %s: parent_team %s does not exist", t.Name, t.ParentTeam))
+			} else if err != nil {
+				return nil, err
+			}
+		}
+		if t.Settings != nil && t.Settings.EscalationUserID != "" && !users[t.Settings.EscalationUserID] {
+			var exists bool
+			err := s.db.Get(&exists, "SELECT EXISTS(SELECT 1 FROM users WHERE user_id = $1)", t.Settings.EscalationUserID)
+			if err != nil {
+				return nil, err
+			}
+			if !exists {
+				problems = append(problems, fmt.Sprintf("team %s: escalation_user_id %s does not exist", t.Name, t.Settings.EscalationUserID))
+			}
+		}
+	}
+	for _, u := range r.Users {
+		if u.BotTeam != "" && !teams[u.BotTeam] {
+			err := s.teamExists(u.BotTeam)
+			if errors.Is(err, ErrNotFound) {
+				problems = append(problems, fmt.Sprintf("user %s: bot_team %s does not exist", u.UserID, u.BotTeam))
+			} else if err != nil {
+				return nil, err
+			}
+		}
+	}
+	return problems, nil
+}
+
+// upsertRosterUser creates the user or updates the fields that differ. An
+// empty timezone keeps the current one. A deactivated user hands their
+// reviews over like /users/setIsActive, a new bot like /users/setBot. The
+// bot team is left to the caller, as it may not exist yet.
+func (s *SQLStore) upsertRosterUser(tx *sqlx.Tx, u roster.User) (models.MemberChange, []models.Reassignment, bool, error) {
+	change := models.MemberChange{UserID: u.UserID}
+
+	var old models.User
+	err := tx.Get(&old, `
+		SELECT user_id, username, is_active, timezone, is_bot, COALESCE(bot_team, '') AS bot_team
+		FROM users WHERE user_id = $1`, u.UserID)
+	if errors.Is(err, sql.ErrNoRows) {
+		_, err = tx.Exec(
+			"INSERT INTO users (user_id, username, is_active, timezone, is_bot) VALUES ($1, $2, $3, COALESCE(NULLIF($4, ''), 'UTC'), $5)",
+			u.UserID, u.Username, u.Active(), u.Timezone, u.IsBot,
+		)
+		return change, nil, true, err
+	}
+	if err != nil {
+		return change, nil, false, err
+	}
+
+	if old.Username != u.Username {
+		change.Changes = append(change.Changes, models.FieldChange{Field: "username", Old: old.Username, New: u.Username})
+	}
+	if old.IsActive != u.Active() {
+		change.Changes = append(change.Changes, models.FieldChange{Field: "is_active", Old: old.IsActive, New: u.Active()})
+	}
+	if u.Timezone != "" && old.Timezone != u.Timezone {
+		change.Changes = append(change.Changes, models.FieldChange{Field: "timezone", Old: old.Timezone, New: u.Timezone})
+	}
+	if old.IsBot != u.IsBot {
+		change.Changes = append(change.Changes, models.FieldChange{Field: "is_bot", Old: old.IsBot, New: u.IsBot})
+	}
+	if old.BotTeam != u.BotTeam {
+		change.Changes = append(change.Changes, models.FieldChange{Field: "bot_team", Old: old.BotTeam, New: u.BotTeam})
+	}
+	if len(change.Changes) == 0 {
+		return change, nil, false, nil
+	}
+
+	_, err = tx.Exec(
+		"UPDATE users SET username = $1, timezone = COALESCE(NULLIF($2, ''), timezone), is_bot = $3 WHERE user_id = $4",
+		u.Username, u.Timezone, u.IsBot, u.UserID,
+	)
+	if err != nil {
+		return change, nil, false, err
+	}
+
+	reassignments := []models.Reassignment{}
+	switch {
+	case old.IsActive && !u.Active():
+		reassignments, err = s.deactivateInTx(tx, u.UserID, nil)
+	case !old.IsActive && u.Active():
+		_, err = tx.Exec("UPDATE users SET is_active = true WHERE user_id = $1", u.UserID)
+	}
+	if err != nil {
+		return change, nil, false, err
+	}
+	if u.IsBot && !old.IsBot {
+		more, err := s.handOverOpenReviews(tx, u.UserID)
+		if err != nil {
+			return change, nil, false, err
+		}
+		reassignments = append(reassignments, more...)
+	}
+	return change, reassignments, false, nil
+}
+
+// applyTeamSettings replaces SLA, holidays, escalation, size rules and the
+// review hand-off on deactivation
+func (s *SQLStore) applyTeamSettings(tx *sqlx.Tx, teamName string, settings roster.Settings) error {
+	_, err := tx.Exec(`
+		UPDATE teams
+		SET review_sla_hours = NULLIF($1, 0), escalation_minutes = NULLIF($2, 0), escalation_user_id = NULLIF($3, ''),
+		    keep_reviews_on_deactivate = $4
+		WHERE name = $5`,
+		settings.ReviewSLAHours, settings.EscalationMinutes, settings.EscalationUserID, settings.KeepReviewsOnDeactivate, teamName)
+	if err != nil {
+		return err
+	}
+
+	for _, query := range []string{
+		"DELETE FROM team_holidays WHERE team_name = $1",
+		"DELETE FROM team_size_rules WHERE team_name = $1",
+	} {
+		if _, err := tx.Exec(query, teamName); err != nil {
+			return err
+		}
+	}
+	for _, day := range settings.Holidays {
+		_, err := tx.Exec("INSERT INTO team_holidays (team_name, day) VALUES ($1, $2) ON CONFLICT DO NOTHING", teamName, day)
+		if err != nil {
+			return err
+		}
+	}
+	for _, rule := range settings.SizeRules {
+		_, err := tx.Exec(
+			"INSERT INTO team_size_rules (team_name, min_lines, reviewer_count) VALUES ($1, $2, $3)",
+			teamName, rule.MinLines, rule.ReviewerCount,
+		)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ExportRoster returns every user and team with memberships and settings in
+// the form ImportRoster accepts, plus the reviewers of open PRs on request
+func (s *SQLStore) ExportRoster(includeAssignments bool) (roster.Roster, error) {
+	var r roster.Roster
+
+	var users []models.User
+	err := s.db.Select(&users, `
+		SELECT user_id, username, is_active, timezone, is_bot, COALESCE(bot_team, '') AS bot_team
+		FROM users ORDER BY user_id`)
+	if err != nil {
+		return r, err
+	}
+	r.Users = make([]roster.User, 0, len(users))
+	for _, u := range users {
+		active := u.IsActive
+		r.Users = append(r.Users, roster.User{
+			UserID:   u.UserID,
+			Username: u.Username,
+			IsActive: &active,
+			Timezone: u.Timezone,
+			IsBot:    u.IsBot,
+			BotTeam:  u.BotTeam,
+		})
+	}
+
+	var teams []struct {
+		Name              string `db:"name"`
+		ParentTeam        string `db:"parent_team"`
+		ReviewSLAHours    int    `db:"review_sla_hours"`
+		EscalationMinutes int    `db:"escalation_minutes"`
+		EscalationUserID  string `db:"escalation_user_id"`
+		KeepReviews       bool   `db:"keep_reviews_on_deactivate"`
+	}
+	err = s.db.Select(&teams, `
+		SELECT name, COALESCE(parent_team, '') AS parent_team,
+		       COALESCE(review_sla_hours, 0) AS review_sla_hours,
+		       COALESCE(escalation_minutes, 0) AS escalation_minutes,
+		       COALESCE(escalation_user_id, '') AS escalation_user_id,
+		       keep_reviews_on_deactivate
+		FROM teams
+		ORDER BY name`)
+	if err != nil {
+		return r, err
+	}
+
+	r.Teams = make([]roster.Team, 0, len(teams))
+	for _, t := range teams {
+		team := roster.Team{
+			Name:       t.Name,
+			ParentTeam: t.ParentTeam,
+			Settings: &roster.Settings{
+				ReviewSLAHours:          t.ReviewSLAHours,
+				EscalationMinutes:       t.EscalationMinutes,
+				EscalationUserID:        t.EscalationUserID,
+				KeepReviewsOnDeactivate: t.KeepReviews,
+			},
+			Members: []roster.Member{},
+		}
+		var members []models.User
+		err = s.db.Select(&members, "SELECT user_id, role FROM team_members WHERE team_name = $1 ORDER BY user_id", t.Name)
+		if err != nil {
+			return r, err
+		}
+		for _, m := range members {
+			team.Members = append(team.Members, roster.Member{UserID: m.UserID, Role: m.Role})
+		}
+		team.Settings.Holidays, err = s.teamHolidays(s.db, t.Name)
+		if err != nil {
+			return r, err
+		}
+		rules, err := s.teamSizeRules(s.db, t.Name)
+		if err != nil {
+			return r, err
+		}
+		for _, rule := range rules {
+			team.Settings.SizeRules = append(team.Settings.SizeRules, roster.SizeRule{MinLines: rule.MinLines, ReviewerCount: rule.ReviewerCount})
+		}
+		r.Teams = append(r.Teams, team)
+	}
+
+	if !includeAssignments {
+		return r, nil
+	}
+
+	var rows []struct {
+		PRID      string         `db:"pull_request_id"`
+		AuthorID  string         `db:"author_id"`
+		Reviewers pq.StringArray `db:"reviewers"`
+	}
+	err = s.db.Select(&rows, `
+		SELECT p.pull_request_id, p.author_id,
+		       COALESCE(array_agg(r.user_id ORDER BY r.user_id) FILTER (WHERE r.user_id IS NOT NULL), '{}') AS reviewers
+		FROM prs p
+		LEFT JOIN pr_reviewers r ON r.pull_request_id = p.pull_request_id
+		WHERE p.status = 'OPEN'
+		GROUP BY p.pull_request_id, p.author_id
+		ORDER BY p.pull_request_id`)
+	if err != nil {
+		return r, err
+	}
+	for _, row := range rows {
+		r.Assignments = append(r.Assignments, roster.Assignment{PullRequestID: row.PRID, AuthorID: row.AuthorID, Reviewers: row.Reviewers})
+	}
+	return r, nil
+}
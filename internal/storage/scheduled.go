@@ -0,0 +1,188 @@
+package storage
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"pr-reviewer-service/internal/models"
+
+	"github.com/jmoiron/sqlx"
+)
+
+const scheduledChangeColumns = `id, kind, user_id, COALESCE(team_name, '') AS team_name,
+	COALESCE(from_team, '') AS from_team, reassign_reviews, run_at, status, error, created_at, applied_at`
+
+// ScheduleChange stores a change for the worker. The user and the teams it
+// names must exist.
+func (s *SQLStore) ScheduleChange(c models.ScheduledChange) (models.ScheduledChange, error) {
+	var exists bool
+	err := s.db.Get(&exists, "SELECT EXISTS(SELECT 1 FROM users WHERE user_id = $1)", c.UserID)
+	if err != nil {
+		return models.ScheduledChange{}, err
+	}
+	if !exists {
+		return models.ScheduledChange{}, ErrNotFound
+	}
+	for _, team := range []string{c.TeamName, c.FromTeam} {
+		if team == "" {
+			continue
+		}
+		if err := s.teamExists(team); err != nil {
+			return models.ScheduledChange{}, err
+		}
+	}
+
+	var created models.ScheduledChange
+	err = s.db.Get(&created, `
+		INSERT INTO scheduled_changes (kind, user_id, team_name, from_team, reassign_reviews, run_at)
+		VALUES ($1, $2, NULLIF($3, ''), NULLIF($4, ''), $5, $6)
+		RETURNING `+scheduledChangeColumns,
+		c.Kind, c.UserID, c.TeamName, c.FromTeam, c.ReassignReviews, c.RunAt,
+	)
+	return created, err
+}
+
+// ListScheduledChanges returns changes by run time; empty filters match all
+func (s *SQLStore) ListScheduledChanges(status, userID string) ([]models.ScheduledChange, error) {
+	changes := []models.ScheduledChange{}
+	err := s.db.Select(&changes, `
+		SELECT `+scheduledChangeColumns+`
+		FROM scheduled_changes
+		WHERE ($1 = '' OR status::text = $1) AND ($2 = '' OR user_id = $2)
+		ORDER BY run_at, id`, status, userID)
+	return changes, err
+}
+
+// CancelScheduledChange cancels a change that has not been applied yet
+func (s *SQLStore) CancelScheduledChange(id int64) (models.ScheduledChange, error) {
+	var c models.ScheduledChange
+	err := s.db.Get(&c, `
+		UPDATE scheduled_changes SET status = 'CANCELLED'
+		WHERE id = $1 AND status = 'PENDING'
+		RETURNING `+scheduledChangeColumns, id)
+	if !errors.Is(err, sql.ErrNoRows) {
+		return c, err
+	}
+
+	var exists bool
+	if err := s.db.Get(&exists, "SELECT EXISTS(SELECT 1 FROM scheduled_changes WHERE id = $1)", id); err != nil {
+		return c, err
+	}
+	if exists {
+		return c, ErrNotPending
+	}
+	return c, ErrNotFound
+}
+
+// ApplyScheduledChanges applies every pending change that is due, each in
+// its own transaction. A change that fails is marked FAILED with the error
+// and does not hold up the others.
+func (s *SQLStore) ApplyScheduledChanges() ([]models.ScheduledChange, error) {
+	var ids []int64
+	err := s.db.Select(&ids, "SELECT id FROM scheduled_changes WHERE status = 'PENDING' AND run_at <= NOW() ORDER BY run_at, id")
+	if err != nil {
+		return nil, err
+	}
+
+	applied := []models.ScheduledChange{}
+	for _, id := range ids {
+		c, ok, err := s.applyScheduledChange(id)
+		if err != nil {
+			return applied, err
+		}
+		if ok {
+			applied = append(applied, c)
+		}
+	}
+	return applied, nil
+}
+
+func (s *SQLStore) applyScheduledChange(id int64) (models.ScheduledChange, bool, error) {
+	var c models.ScheduledChange
+	tx, err := s.db.Beginx()
+	if err != nil {
+		return c, false, err
+	}
+	defer tx.Rollback()
+
+	// Cancelled in the meantime or taken by another instance
+	err = tx.Get(&c, "SELECT "+scheduledChangeColumns+" FROM scheduled_changes WHERE id = $1 AND status = 'PENDING' FOR UPDATE SKIP LOCKED", id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return c, false, nil
+	}
+	if err != nil {
+		return c, false, err
+	}
+
+	if _, err := tx.Exec("SAVEPOINT scheduled_change"); err != nil {
+		return c, false, err
+	}
+	c.Status = models.ChangeApplied
+	c.Reassignments, err = s.applyChangeInTx(tx, c)
+	if err != nil {
+		if _, err := tx.Exec("ROLLBACK TO SAVEPOINT scheduled_change"); err != nil {
+			return c, false, err
+		}
+		c.Status, c.Error, c.Reassignments = models.ChangeFailed, err.Error(), nil
+	}
+
+	err = tx.Get(&c.AppliedAt, `
+		UPDATE scheduled_changes SET status = $1, error = $2, applied_at = NOW()
+		WHERE id = $3
+		RETURNING applied_at`, c.Status, c.Error, id)
+	if err != nil {
+		return c, false, err
+	}
+	if err := s.audit(tx, "scheduled_"+c.Kind, "scheduler", c.UserID, c); err != nil {
+		return c, false, err
+	}
+	return c, true, tx.Commit()
+}
+
+// applyChangeInTx has the same hand-off semantics as the immediate
+// endpoints: deactivation follows DeactivateUser and leaving a team follows
+// RemoveTeamMember
+func (s *SQLStore) applyChangeInTx(tx *sqlx.Tx, c models.ScheduledChange) ([]models.Reassignment, error) {
+	switch c.Kind {
+	case models.ChangeDeactivate:
+		return s.deactivateInTx(tx, c.UserID, c.ReassignReviews)
+	case models.ChangeActivate:
+		_, err := tx.Exec("UPDATE users SET is_active = true WHERE user_id = $1", c.UserID)
+		return []models.Reassignment{}, err
+	case models.ChangeMoveTeam:
+		return s.moveUserInTx(tx, c.UserID, c.FromTeam, c.TeamName)
+	}
+	return nil, fmt.Errorf("unknown change kind %q", c.Kind)
+}
+
+// moveUserInTx adds the user to toTeam and removes them from fromTeam, or
+// from every other team when fromTeam is empty
+func (s *SQLStore) moveUserInTx(tx *sqlx.Tx, userID, fromTeam, toTeam string) ([]models.Reassignment, error) {
+	var leaving []string
+	err := tx.Select(&leaving, `
+		SELECT team_name FROM team_members
+		WHERE user_id = $1 AND team_name <> $2 AND ($3 = '' OR team_name = $3)
+		ORDER BY team_name`, userID, toTeam, fromTeam)
+	if err != nil {
+		return nil, err
+	}
+	if fromTeam != "" && len(leaving) == 0 {
+		return nil, fmt.Errorf("%s is not a member of %s", userID, fromTeam)
+	}
+
+	reassignments := []models.Reassignment{}
+	for _, team := range leaving {
+		handedOver, err := s.handOverTeamReviews(tx, team, userID)
+		if err != nil {
+			return nil, err
+		}
+		reassignments = append(reassignments, handedOver...)
+		if _, err := tx.Exec("DELETE FROM team_members WHERE team_name = $1 AND user_id = $2", team, userID); err != nil {
+			return nil, err
+		}
+	}
+
+	_, err = tx.Exec("INSERT INTO team_members (team_name, user_id) VALUES ($1, $2) ON CONFLICT DO NOTHING", toTeam, userID)
+	return reassignments, err
+}
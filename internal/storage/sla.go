@@ -0,0 +1,224 @@
+package storage
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+
+	"pr-reviewer-service/internal/models"
+	"pr-reviewer-service/internal/sla"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// prTeamSQL selects the team the PR pr is routed to, like CreatePR does:
+// the repository's owning team, the bot author's team or the author's first
+// team by name
+func prTeamSQL(pr string) string {
+	return `
+		SELECT COALESCE(repo.owning_team, bot.bot_team,
+		       (SELECT team_name FROM team_members WHERE user_id = p.author_id ORDER BY team_name LIMIT 1))
+		FROM prs p
+		LEFT JOIN repositories repo ON repo.name = p.repository
+		LEFT JOIN users bot ON bot.user_id = p.author_id AND bot.is_bot
+		WHERE p.pull_request_id = ` + pr
+}
+
+// reviewerTeamSQL selects the team whose SLA times the reviewer user of the
+// PR pr: a team with an SLA first, then the one the PR was routed to, then
+// by name
+func reviewerTeamSQL(pr, user string) string {
+	return `
+		SELECT tm.team_name
+		FROM team_members tm
+		JOIN teams t ON t.name = tm.team_name
+		WHERE tm.user_id = ` + user + `
+		ORDER BY t.review_sla_hours IS NULL, tm.team_name IS NOT DISTINCT FROM (` + prTeamSQL(pr) + `) DESC, tm.team_name
+		LIMIT 1`
+}
+
+func (s *SQLStore) SetTeamSLA(teamName string, hours int, holidays []string) (models.Team, error) {
+	if err := s.teamExists(teamName); err != nil {
+		return models.Team{}, err
+	}
+
+	tx, err := s.db.Beginx()
+	if err != nil {
+		return models.Team{}, err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec("UPDATE teams SET review_sla_hours = NULLIF($1, 0) WHERE name = $2", hours, teamName)
+	if err != nil {
+		return models.Team{}, err
+	}
+
+	// The holiday list is replaced as a whole
+	_, err = tx.Exec("DELETE FROM team_holidays WHERE team_name = $1", teamName)
+	if err != nil {
+		return models.Team{}, err
+	}
+	for _, day := range holidays {
+		_, err = tx.Exec(
+			"INSERT INTO team_holidays (team_name, day) VALUES ($1, $2) ON CONFLICT DO NOTHING",
+			teamName, day,
+		)
+		if err != nil {
+			return models.Team{}, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return models.Team{}, err
+	}
+	return s.GetTeam(teamName)
+}
+
+func (s *SQLStore) ListSLABreaches(teamName, userID string) ([]models.SLABreach, error) {
+	breaches := []models.SLABreach{}
+	err := s.db.Select(&breaches, `
+		SELECT pull_request_id, user_id, team_name, due_at, detected_at, reviewed_at
+		FROM sla_breaches
+		WHERE ($1 = '' OR team_name = $1)
+		AND ($2 = '' OR user_id = $2)
+		ORDER BY due_at DESC`, teamName, userID)
+	return breaches, err
+}
+
+// RecordSLABreaches stores assignments on open PRs that are past due without
+// a review. It is idempotent and meant to be run periodically.
+func (s *SQLStore) RecordSLABreaches() (int, error) {
+	result, err := s.db.Exec(`
+		INSERT INTO sla_breaches (pull_request_id, user_id, team_name, due_at)
+		SELECT r.pull_request_id, r.user_id,
+		       COALESCE((`+reviewerTeamSQL("r.pull_request_id", "r.user_id")+`), ''),
+		       r.due_at
+		FROM pr_reviewers r
+		JOIN prs p ON p.pull_request_id = r.pull_request_id
+		WHERE p.status = 'OPEN'
+		AND r.first_reviewed_at IS NULL
+		AND r.due_at < NOW()
+		ON CONFLICT (pull_request_id, user_id, due_at) DO NOTHING`)
+	if err != nil {
+		return 0, err
+	}
+	n, _ := result.RowsAffected()
+	return int(n), nil
+}
+
+// recordLateReview marks a breach (recorded or not yet) with the time of the
+// first review when it came after the due time
+func (s *SQLStore) recordLateReview(prID, userID string) error {
+	_, err := s.db.Exec(`
+		INSERT INTO sla_breaches (pull_request_id, user_id, team_name, due_at, reviewed_at)
+		SELECT r.pull_request_id, r.user_id,
+		       COALESCE((`+reviewerTeamSQL("r.pull_request_id", "r.user_id")+`), ''),
+		       r.due_at, r.first_reviewed_at
+		FROM pr_reviewers r
+		WHERE r.pull_request_id = $1 AND r.user_id = $2
+		AND r.first_reviewed_at > r.due_at
+		ON CONFLICT (pull_request_id, user_id, due_at) DO UPDATE SET reviewed_at = EXCLUDED.reviewed_at`,
+		prID, userID)
+	return err
+}
+
+// setDueAt computes the review deadline of a fresh assignment from the SLA
+// of the reviewer's team, the team holidays and the reviewer's timezone.
+// A reviewer in several teams with an SLA is timed by the team the PR was
+// routed to, if they are in it. Reviewers without an SLA get no deadline.
+func (s *SQLStore) setDueAt(q sqlx.Ext, prID, userID string) error {
+	var info struct {
+		TeamName string `db:"team_name"`
+		Hours    int    `db:"review_sla_hours"`
+		Timezone string `db:"timezone"`
+	}
+	err := sqlx.Get(q, &info, `
+		SELECT t.name AS team_name, t.review_sla_hours, u.timezone
+		FROM users u
+		JOIN teams t ON t.name = (`+reviewerTeamSQL("$1", "$2")+`)
+		WHERE u.user_id = $2 AND t.review_sla_hours IS NOT NULL`, prID, userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		_, err = q.Exec("UPDATE pr_reviewers SET due_at = NULL WHERE pull_request_id = $1 AND user_id = $2", prID, userID)
+		return err
+	}
+	if err != nil {
+		return err
+	}
+
+	// An unknown timezone falls back to UTC rather than failing the assignment
+	loc, err := time.LoadLocation(info.Timezone)
+	if err != nil {
+		loc = time.UTC
+	}
+	holidays, err := s.teamHolidays(q, info.TeamName)
+	if err != nil {
+		return err
+	}
+	calendar := sla.Calendar{Location: loc, Holidays: map[string]bool{}}
+	for _, day := range holidays {
+		calendar.Holidays[day] = true
+	}
+
+	_, err = q.Exec(
+		"UPDATE pr_reviewers SET due_at = $1 WHERE pull_request_id = $2 AND user_id = $3",
+		calendar.DueAt(time.Now(), info.Hours), prID, userID,
+	)
+	return err
+}
+
+func (s *SQLStore) teamHolidays(q sqlx.Queryer, teamName string) ([]string, error) {
+	holidays := []string{}
+	err := sqlx.Select(q, &holidays, `
+		SELECT to_char(day, 'YYYY-MM-DD')
+		FROM team_holidays
+		WHERE team_name = $1
+		ORDER BY day`, teamName)
+	return holidays, err
+}
+
+// slaCompliance reports the share of due assignments reviewed in time, per
+// reviewer and per team. Only assignments that were reviewed or are already
+// past due are counted.
+func (s *SQLStore) slaCompliance() (map[string]interface{}, error) {
+	var reviewers []struct {
+		UserID      string  `db:"user_id"`
+		Assignments int     `db:"assignments"`
+		Breaches    int     `db:"breaches"`
+		Compliance  float64 `db:"compliance"`
+	}
+	err := s.db.Select(&reviewers, `
+		SELECT user_id, COUNT(*) as assignments,
+		       COUNT(*) FILTER (WHERE COALESCE(first_reviewed_at, NOW()) > due_at) as breaches,
+		       1 - COUNT(*) FILTER (WHERE COALESCE(first_reviewed_at, NOW()) > due_at)::float / COUNT(*) as compliance
+		FROM pr_reviewers
+		WHERE due_at IS NOT NULL AND (first_reviewed_at IS NOT NULL OR due_at < NOW())
+		GROUP BY user_id
+		ORDER BY compliance`)
+	if err != nil {
+		return nil, err
+	}
+
+	var teams []struct {
+		TeamName    string  `db:"team_name"`
+		Assignments int     `db:"assignments"`
+		Breaches    int     `db:"breaches"`
+		Compliance  float64 `db:"compliance"`
+	}
+	err = s.db.Select(&teams, `
+		SELECT tm.team_name, COUNT(*) as assignments,
+		       COUNT(*) FILTER (WHERE COALESCE(r.first_reviewed_at, NOW()) > r.due_at) as breaches,
+		       1 - COUNT(*) FILTER (WHERE COALESCE(r.first_reviewed_at, NOW()) > r.due_at)::float / COUNT(*) as compliance
+		FROM pr_reviewers r
+		JOIN team_members tm ON tm.user_id = r.user_id
+		WHERE r.due_at IS NOT NULL AND (r.first_reviewed_at IS NOT NULL OR r.due_at < NOW())
+		GROUP BY tm.team_name
+		ORDER BY compliance`)
+	if err != nil {
+		return nil, err
+	}
+
+	return map[string]interface{}{
+		"reviewers": reviewers,
+		"teams":     teams,
+	}, nil
+}
@@ -0,0 +1,52 @@
+package storage
+
+import (
+	"testing"
+	"time"
+
+	"pr-reviewer-service/internal/models"
+)
+
+func TestDueAtUsesThePRTeam(t *testing.T) {
+	s := newTestStore(t)
+	err := s.CreateTeam("backend", []models.User{
+		{UserID: "u2", Username: "Bob", IsActive: true},
+		{UserID: "u3", Username: "Carol", IsActive: true},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.CreateTeam("zeta", []models.User{{UserID: "u1", Username: "Alice", IsActive: true}}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.AddTeamMember("zeta", models.User{UserID: "u2"}); err != nil {
+		t.Fatal(err)
+	}
+	// backend comes first by name but the PR is zeta's
+	if _, err := s.SetTeamSLA("backend", 1, nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.SetTeamSLA("zeta", 1000, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := s.CreatePR(newPR("pr-1", "u1")); err != nil {
+		t.Fatal(err)
+	}
+	var dueAt time.Time
+	if err := s.db.Get(&dueAt, "SELECT due_at FROM pr_reviewers WHERE pull_request_id = 'pr-1' AND user_id = 'u2'"); err != nil {
+		t.Fatal(err)
+	}
+	if dueAt.Before(time.Now().Add(7 * 24 * time.Hour)) {
+		t.Errorf("Expected u2 to be timed by the zeta SLA, got %v", dueAt)
+	}
+
+	s.db.MustExec("UPDATE pr_reviewers SET due_at = NOW() - INTERVAL '1 hour'")
+	if _, err := s.RecordSLABreaches(); err != nil {
+		t.Fatal(err)
+	}
+	var team string
+	if err := s.db.Get(&team, "SELECT team_name FROM sla_breaches WHERE user_id = 'u2'"); err != nil || team != "zeta" {
+		t.Errorf("Expected the breach to count for zeta, got %q (%v)", team, err)
+	}
+}
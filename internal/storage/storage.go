@@ -8,31 +8,160 @@ import (
 	"time"
 
 	"pr-reviewer-service/internal/models"
+	"pr-reviewer-service/internal/policy"
+	"pr-reviewer-service/internal/roster"
 
 	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
 )
 
 // Error definitions
 var (
-	ErrTeamExists  = errors.New("TEAM_EXISTS")
-	ErrPRExists    = errors.New("PR_EXISTS")
-	ErrNotFound    = errors.New("NOT_FOUND")
-	ErrPRMerged    = errors.New("PR_MERGED")
-	ErrNotAssigned = errors.New("NOT_ASSIGNED")
-	ErrNoCandidate = errors.New("NO_CANDIDATE")
+	ErrTeamExists   = errors.New("TEAM_EXISTS")
+	ErrPRExists     = errors.New("PR_EXISTS")
+	ErrNotFound     = errors.New("NOT_FOUND")
+	ErrPRMerged     = errors.New("PR_MERGED")
+	ErrPRClosed     = errors.New("PR_CLOSED")
+	ErrNotAssigned  = errors.New("NOT_ASSIGNED")
+	ErrNoCandidate  = errors.New("NO_CANDIDATE")
+	ErrRepoExists   = errors.New("REPOSITORY_EXISTS")
+	ErrPRAmbiguous  = errors.New("PR_AMBIGUOUS")
+	ErrParentOpen   = errors.New("PARENT_OPEN")
+	ErrDepCycle     = errors.New("DEPENDENCY_CYCLE")
+	ErrMemberExists = errors.New("MEMBER_EXISTS")
+	ErrTeamCycle    = errors.New("TEAM_CYCLE")
+	ErrAssigned     = errors.New("ALREADY_ASSIGNED")
+	ErrAuthor       = errors.New("AUTHOR_CANNOT_REVIEW")
+	ErrUserExists   = errors.New("USER_EXISTS")
+	ErrBot          = errors.New("BOT_CANNOT_REVIEW")
+	ErrNoTeam       = errors.New("AUTHOR_TEAM_NOT_FOUND")
+	ErrReverted     = errors.New("ALREADY_REVERTED")
+	ErrNotPending   = errors.New("NOT_PENDING")
+	ErrAliasExists  = errors.New("ALIAS_EXISTS")
+
+	// ErrAliasAmbiguous is returned when an identity is an alias of several
+	// users under different providers
+	ErrAliasAmbiguous = errors.New("ALIAS_AMBIGUOUS")
+
+	// ErrDeliveryInProgress is returned by RecordDelivery while an earlier
+	// attempt of the same webhook delivery is still being handled
+	ErrDeliveryInProgress = errors.New("DELIVERY_IN_PROGRESS")
 )
 
+// defaultReviewerCount is used for PRs outside of a repository
+const defaultReviewerCount = 2
+
+// prColumns is the column list scanned into models.PullRequest
+const prColumns = `pull_request_id, pull_request_name, author_id, status, created_at, merged_at, closed_at,
+	COALESCE(repository, '') AS repository, COALESCE(number, 0) AS number, COALESCE(alias, '') AS alias,
+	lines_added, lines_removed, files_changed, priority, COALESCE(depends_on, '') AS depends_on,
+	COALESCE(reviewer_sync, '') AS reviewer_sync, COALESCE(reviewer_sync_error, '') AS reviewer_sync_error`
+
+// PolicyViolationError is returned by MergePR when the merge policy fails
+type PolicyViolationError struct {
+	Violations []models.RuleViolation
+}
+
+func (e *PolicyViolationError) Error() string {
+	return "POLICY_VIOLATION"
+}
+
+// TeamBusyError is returned by DeleteTeam when members still review open PRs
+type TeamBusyError struct {
+	PullRequestIDs []string
+}
+
+func (e *TeamBusyError) Error() string {
+	return "TEAM_HAS_OPEN_REVIEWS"
+}
+
+// TeamRequiredError is returned by DeleteTeam without a team to move to
+// while merge policies require a review from the team
+type TeamRequiredError struct {
+	Policies []models.MergePolicy
+}
+
+func (e *TeamRequiredError) Error() string {
+	return "TEAM_REQUIRED_BY_POLICY"
+}
+
+// UnresolvedReviewsError aborts a mass deactivation that would leave reviews
+// without a replacement; Reassignments is the full hand-off report
+type UnresolvedReviewsError struct {
+	Reassignments []models.Reassignment
+}
+
+func (e *UnresolvedReviewsError) Error() string {
+	return "UNRESOLVED_REVIEWS"
+}
+
 type Store interface {
 	CreateTeam(name string, members []models.User) error
 	GetTeam(name string) (models.Team, error)
 	SetUserActive(userID string, active bool) (models.User, error)
-	CreatePR(pr models.PullRequest) error
+	CreatePR(pr models.PullRequest) (models.PullRequest, error)
 	GetPR(id string) (models.PullRequest, error)
-	MergePR(id string) (models.PullRequest, error)
-	ReassignReviewer(prID, oldReviewerID string) (models.PullRequest, string, error)
+	MergePR(id string, force bool) (models.PullRequest, error)
+	ClosePR(id, provider string) (models.PullRequest, error)
+	RecordExternalMerge(id, provider string) (models.PullRequest, error)
+	ReopenPR(id, provider string) (models.PullRequest, error)
+	SubmitReview(prID, userID string, state models.ReviewState) (models.PullRequest, error)
+	ReassignReviewer(prID, oldReviewerID string) (models.PullRequest, string, int64, error)
 	ListPRsAssignedTo(userID string) ([]models.PullRequest, error)
 	GetStats() (map[string]interface{}, error)
-	MassDeactivate(teamName string, excludeUsers []string) (map[string]interface{}, error)
+	MassDeactivate(teamName string, excludeUsers []string, dryRun, abortIfUnresolved bool) (map[string]interface{}, error)
+	SetMergePolicy(p models.MergePolicy) (models.MergePolicy, error)
+	GetMergePolicy(teamName string) (models.MergePolicy, error)
+	AddMergeFreeze(f models.MergeFreeze) (models.MergeFreeze, error)
+	ListMergeFreezes(teamName string) ([]models.MergeFreeze, error)
+	CreateRepository(repo models.Repository) (models.Repository, error)
+	GetRepository(name string) (models.Repository, error)
+	UpdateRepository(repo models.Repository) (models.Repository, error)
+	ListRepositories() ([]models.Repository, error)
+	SetTeamSizeRules(teamName string, rules []models.SizeRule) ([]models.SizeRule, error)
+	LinkPR(prID, parentID string) (models.PullRequest, error)
+	RespondToAssignment(prID, userID string, accept bool, reason string) (models.PullRequest, string, error)
+	ReassignStaleAssignments(timeout time.Duration) ([]models.Reassignment, error)
+	SetTeamSLA(teamName string, hours int, holidays []string) (models.Team, error)
+	ListSLABreaches(teamName, userID string) ([]models.SLABreach, error)
+	RecordSLABreaches() (int, error)
+	SetTeamEscalation(teamName string, minutes int, userID string) (models.Team, error)
+	EscalateStaleReviews() ([]models.Escalation, error)
+	ListEscalations(teamName string) ([]models.Escalation, error)
+	AddTeamMember(teamName string, member models.User) (models.Team, error)
+	RemoveTeamMember(teamName, userID string) ([]models.Reassignment, error)
+	RenameTeam(oldName, newName string) (models.Team, error)
+	UpdateTeam(name string, changes []models.TeamChange) (models.Team, error)
+	DeleteTeam(name, moveTo string) (map[string]interface{}, error)
+	ReconcileTeam(name string, members []models.MemberSpec, dryRun bool) (models.TeamDiff, error)
+	SetTeamParent(teamName, parentTeam string) (models.Team, error)
+	AddReviewer(prID, userID string) (models.PullRequest, error)
+	ImportRoster(r roster.Roster, dryRun bool) (models.ImportResult, error)
+	ExportRoster(includeAssignments bool) (roster.Roster, error)
+	GetUser(userID string) (models.User, error)
+	GetUserProfile(userID string, historyLimit int) (models.UserProfile, error)
+	ListUsers(f models.UserFilter) ([]models.User, int, error)
+	CreateUser(u models.User) (models.User, error)
+	UpdateUser(userID string, p models.UserPatch) (models.User, error)
+	UpdateUserActive(u models.User, active bool) (models.User, []models.Reassignment, error)
+	DeactivateUser(userID string, reassign *bool) ([]models.Reassignment, error)
+	SetUserBot(u models.User) (models.User, []models.Reassignment, error)
+	SetTeamKeepReviews(teamName string, keep bool) (models.Team, error)
+	RevertOperation(id int64) (models.RevertResult, error)
+	ScheduleChange(c models.ScheduledChange) (models.ScheduledChange, error)
+	ListScheduledChanges(status, userID string) ([]models.ScheduledChange, error)
+	CancelScheduledChange(id int64) (models.ScheduledChange, error)
+	ApplyScheduledChanges() ([]models.ScheduledChange, error)
+	AddUserAlias(a models.UserAlias) (models.UserAlias, error)
+	ListUserAliases(userID, provider string) ([]models.UserAlias, error)
+	DeleteUserAlias(provider, externalID string) (models.UserAlias, error)
+	ResolveUser(provider, identity string) (string, error)
+	RecordDelivery(provider, deliveryID, event string) (bool, error)
+	FinishDelivery(provider, deliveryID string) error
+	ForgetDelivery(provider, deliveryID string) error
+	PendingReviewerSyncs(limit int) ([]models.ReviewerSyncTask, error)
+	FinishReviewerSync(t models.ReviewerSyncTask) error
+	ListTeams() ([]string, error)
 }
 
 type SQLStore struct {
@@ -69,8 +198,10 @@ func (s *SQLStore) CreateTeam(name string, members []models.User) error {
 	for _, m := range members {
 		// Insert or update user
 		_, err := tx.Exec(
-			"INSERT INTO users (user_id, username, is_active) VALUES ($1, $2, $3) ON CONFLICT (user_id) DO UPDATE SET username = EXCLUDED.username, is_active = EXCLUDED.is_active",
-			m.UserID, m.Username, m.IsActive,
+			`INSERT INTO users (user_id, username, is_active, timezone) VALUES ($1, $2, $3, COALESCE(NULLIF($4, ''), 'UTC'))
+			ON CONFLICT (user_id) DO UPDATE SET username = EXCLUDED.username, is_active = EXCLUDED.is_active,
+				timezone = CASE WHEN $4 = '' THEN users.timezone ELSE EXCLUDED.timezone END`,
+			m.UserID, m.Username, m.IsActive, m.Timezone,
 		)
 		if err != nil {
 			tx.Rollback()
@@ -79,8 +210,8 @@ func (s *SQLStore) CreateTeam(name string, members []models.User) error {
 
 		// Add to team
 		_, err = tx.Exec(
-			"INSERT INTO team_members (team_name, user_id) VALUES ($1, $2) ON CONFLICT DO NOTHING",
-			name, m.UserID,
+			"INSERT INTO team_members (team_name, user_id, role) VALUES ($1, $2, COALESCE(NULLIF($3, ''), 'member')::member_role) ON CONFLICT DO NOTHING",
+			name, m.UserID, m.Role,
 		)
 		if err != nil {
 			tx.Rollback()
@@ -97,7 +228,7 @@ func (s *SQLStore) GetTeam(name string) (models.Team, error) {
 	
 	var members []models.User
 	err := s.db.Select(&members, `
-		SELECT u.user_id, u.username, u.is_active 
+		SELECT u.user_id, u.username, u.is_active, u.timezone, tm.role
 		FROM users u 
 		JOIN team_members tm ON tm.user_id = u.user_id 
 		WHERE tm.team_name = $1`, name)
@@ -106,6 +237,38 @@ func (s *SQLStore) GetTeam(name string) (models.Team, error) {
 	}
 	
 	team.Members = members
+
+	err = s.db.Get(&team, `
+		SELECT name, COALESCE(review_sla_hours, 0) AS review_sla_hours,
+		       COALESCE(escalation_minutes, 0) AS escalation_minutes,
+		       COALESCE(escalation_user_id, '') AS escalation_user_id,
+		       COALESCE(parent_team, '') AS parent_team,
+		       keep_reviews_on_deactivate
+		FROM teams
+		WHERE name = $1`, name)
+	if errors.Is(err, sql.ErrNoRows) {
+		return team, ErrNotFound
+	}
+	if err != nil {
+		return team, err
+	}
+	team.Children, err = s.teamChildren(s.db, name)
+	if err != nil {
+		return team, err
+	}
+	team.Path, err = s.teamAncestors(s.db, name)
+	if err != nil {
+		return team, err
+	}
+	team.Holidays, err = s.teamHolidays(s.db, name)
+	if err != nil {
+		return team, err
+	}
+
+	team.SizeRules, err = s.teamSizeRules(s.db, name)
+	if err != nil {
+		return team, err
+	}
 	return team, nil
 }
 
@@ -133,65 +296,154 @@ func (s *SQLStore) SetUserActive(userID string, active bool) (models.User, error
 }
 
 // PRs
-func (s *SQLStore) CreatePR(pr models.PullRequest) error {
+func (s *SQLStore) CreatePR(pr models.PullRequest) (models.PullRequest, error) {
+	tx, err := s.db.Beginx()
+	if err != nil {
+		return models.PullRequest{}, err
+	}
+	defer tx.Rollback()
+
+	reviewerCount := defaultReviewerCount
+	var teamName string
+
+	if pr.Repository != "" {
+		var repo models.Repository
+		err = tx.Get(&repo, `
+			SELECT name, COALESCE(owning_team, '') AS owning_team, reviewer_count
+			FROM repositories
+			WHERE name = $1`, pr.Repository)
+		if err != nil {
+			return models.PullRequest{}, ErrNotFound
+		}
+		reviewerCount = repo.ReviewerCount
+		teamName = repo.OwningTeam
+
+		// Repository PRs are keyed by number, the caller's ID becomes an alias
+		if pr.Alias == "" {
+			pr.Alias = pr.ID
+		}
+		pr.ID = fmt.Sprintf("%s#%d", pr.Repository, pr.Number)
+	}
+
+	// The author may be given by an alias such as a GitHub login
+	pr.AuthorID, err = s.resolveUserID(tx, "", pr.AuthorID)
+	if err != nil {
+		return models.PullRequest{}, err
+	}
+
+	// Bot PRs go to the bot's team. Otherwise, without an owning team,
+	// reviewers come from the author's team.
+	var botTeam string
+	err = tx.Get(&botTeam, "SELECT COALESCE(bot_team, '') FROM users WHERE user_id = $1 AND is_bot", pr.AuthorID)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return models.PullRequest{}, err
+	}
+	if botTeam != "" {
+		teamName = botTeam
+	}
+	if teamName == "" {
+		err = tx.Get(&teamName, "SELECT team_name FROM team_members WHERE user_id = $1 ORDER BY team_name LIMIT 1", pr.AuthorID)
+		if err != nil {
+			return models.PullRequest{}, ErrNoTeam
+		}
+	}
+
 	// Check if PR already exists
 	var existingPR string
-	err := s.db.Get(&existingPR, "SELECT pull_request_id FROM prs WHERE pull_request_id = $1", pr.ID)
+	err = tx.Get(&existingPR, "SELECT pull_request_id FROM prs WHERE pull_request_id = $1", pr.ID)
 	if err == nil {
-		return ErrPRExists
+		return models.PullRequest{}, ErrPRExists
+	}
+
+	if pr.DependsOn != "" {
+		pr.DependsOn, err = s.resolvePRID(tx, pr.DependsOn)
+		if err != nil {
+			return models.PullRequest{}, err
+		}
+	}
+
+	if pr.Priority == "" {
+		pr.Priority = models.PriorityMedium
 	}
 
 	// Create PR
-	_, err = s.db.Exec(
-		"INSERT INTO prs (pull_request_id, pull_request_name, author_id, status, created_at) VALUES ($1, $2, $3, $4, $5)",
-		pr.ID, pr.Title, pr.AuthorID, pr.Status, pr.CreatedAt,
+	_, err = tx.Exec(`
+		INSERT INTO prs (pull_request_id, pull_request_name, author_id, status, created_at, repository, number, alias,
+		                 lines_added, lines_removed, files_changed, priority, depends_on)
+		VALUES ($1, $2, $3, $4, $5, NULLIF($6, ''), NULLIF($7, 0), NULLIF($8, ''), $9, $10, $11, $12, NULLIF($13, ''))`,
+		pr.ID, pr.Title, pr.AuthorID, pr.Status, pr.CreatedAt, pr.Repository, pr.Number, pr.Alias,
+		pr.LinesAdded, pr.LinesRemoved, pr.FilesChanged, pr.Priority, pr.DependsOn,
 	)
 	if err != nil {
-		return err
+		return models.PullRequest{}, err
 	}
 
-	// Get author's team and assign reviewers
-	var teamName string
-	err = s.db.Get(&teamName, "SELECT team_name FROM team_members WHERE user_id = $1", pr.AuthorID)
+	// Bigger PRs get more reviewers according to the team size rules
+	rules, err := s.teamSizeRules(tx, teamName)
 	if err != nil {
-		return errors.New("author team not found")
+		return models.PullRequest{}, err
 	}
+	reviewerCount = policy.ReviewerCount(reviewerCount, rules, pr.LinesAdded+pr.LinesRemoved)
 
-	// Find active reviewers from the same team (excluding author)
+	// Reviewers of the parent PR keep reviewing the stack when still eligible
 	var reviewers []string
-	err = s.db.Select(&reviewers, `
-		SELECT u.user_id 
-		FROM users u 
-		JOIN team_members tm ON tm.user_id = u.user_id 
-		WHERE tm.team_name = $1 
-		AND u.is_active = true 
-		AND u.user_id != $2 
-		LIMIT 2`,
-		teamName, pr.AuthorID)
+	if pr.DependsOn != "" {
+		err = tx.Select(&reviewers, `
+			SELECT u.user_id
+			FROM pr_reviewers r
+			JOIN users u ON u.user_id = r.user_id
+			JOIN team_members tm ON tm.user_id = u.user_id
+			WHERE r.pull_request_id = $1
+			AND tm.team_name = $2
+			AND tm.role <> 'observer'
+			AND u.is_active = true
+			AND NOT u.is_bot
+			AND u.user_id <> $3
+			LIMIT $4`,
+			pr.DependsOn, teamName, pr.AuthorID, reviewerCount)
+		if err != nil {
+			return models.PullRequest{}, err
+		}
+	}
+
+	others, err := s.pickReviewersInTree(tx, teamName, append([]string{pr.AuthorID}, reviewers...), reviewerCount-len(reviewers))
 	if err != nil {
-		return err
+		return models.PullRequest{}, err
 	}
+	reviewers = append(reviewers, others...)
 
 	// Assign reviewers
 	for _, reviewerID := range reviewers {
-		_, err = s.db.Exec(
+		_, err = tx.Exec(
 			"INSERT INTO pr_reviewers (pull_request_id, user_id) VALUES ($1, $2)",
 			pr.ID, reviewerID,
 		)
 		if err != nil {
-			return err
+			return models.PullRequest{}, err
+		}
+		if err := s.setDueAt(tx, pr.ID, reviewerID); err != nil {
+			return models.PullRequest{}, err
 		}
 	}
+	if err := s.queueReviewerSync(tx, pr.ID); err != nil {
+		return models.PullRequest{}, err
+	}
 
-	return nil
+	if err := tx.Commit(); err != nil {
+		return models.PullRequest{}, err
+	}
+
+	return s.GetPR(pr.ID)
 }
 
 func (s *SQLStore) GetPR(id string) (models.PullRequest, error) {
 	var pr models.PullRequest
-	err := s.db.Get(&pr, `
-		SELECT pull_request_id, pull_request_name, author_id, status, created_at, merged_at 
-		FROM prs 
-		WHERE pull_request_id = $1`, id)
+	id, err := s.resolvePRID(s.db, id)
+	if err != nil {
+		return pr, err
+	}
+
+	err = s.db.Get(&pr, "SELECT "+prColumns+" FROM prs WHERE pull_request_id = $1", id)
 	if err != nil {
 		return pr, err
 	}
@@ -212,93 +464,374 @@ func (s *SQLStore) GetPR(id string) (models.PullRequest, error) {
 	}
 	pr.Reviewers = reviewers
 
+	var reviews []models.Review
+	err = s.db.Select(&reviews, `
+		SELECT user_id, state, reviewed_at, assigned_at, accepted_at, due_at
+		FROM pr_reviewers
+		WHERE pull_request_id = $1`, id)
+	if err != nil {
+		return pr, err
+	}
+	pr.Reviews = reviews
+
+	pr.DependencyChain, err = s.dependencyChain(s.db, id)
+	if err != nil {
+		return pr, err
+	}
+
 	return pr, nil
 }
 
-func (s *SQLStore) MergePR(id string) (models.PullRequest, error) {
-	// Check if PR exists and is not already merged
+// resolvePRID maps a pull request ID or a legacy alias to the canonical ID
+func (s *SQLStore) resolvePRID(q sqlx.Queryer, id string) (string, error) {
+	var ids []string
+	err := sqlx.Select(q, &ids, "SELECT pull_request_id FROM prs WHERE pull_request_id = $1", id)
+	if err != nil {
+		return "", err
+	}
+	if len(ids) == 1 {
+		return ids[0], nil
+	}
+
+	err = sqlx.Select(q, &ids, "SELECT pull_request_id FROM prs WHERE alias = $1 LIMIT 2", id)
+	if err != nil {
+		return "", err
+	}
+	switch len(ids) {
+	case 0:
+		return "", ErrNotFound
+	case 1:
+		return ids[0], nil
+	default:
+		return "", ErrPRAmbiguous
+	}
+}
+
+func (s *SQLStore) SetTeamSizeRules(teamName string, rules []models.SizeRule) ([]models.SizeRule, error) {
+	if err := s.teamExists(teamName); err != nil {
+		return nil, err
+	}
+
+	tx, err := s.db.Beginx()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	// The request replaces the whole rule set
+	_, err = tx.Exec("DELETE FROM team_size_rules WHERE team_name = $1", teamName)
+	if err != nil {
+		return nil, err
+	}
+	for _, rule := range rules {
+		_, err = tx.Exec(
+			"INSERT INTO team_size_rules (team_name, min_lines, reviewer_count) VALUES ($1, $2, $3)",
+			teamName, rule.MinLines, rule.ReviewerCount,
+		)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return s.teamSizeRules(s.db, teamName)
+}
+
+func (s *SQLStore) teamSizeRules(q sqlx.Queryer, teamName string) ([]models.SizeRule, error) {
+	rules := []models.SizeRule{}
+	err := sqlx.Select(q, &rules, `
+		SELECT min_lines, reviewer_count
+		FROM team_size_rules
+		WHERE team_name = $1
+		ORDER BY min_lines`, teamName)
+	return rules, err
+}
+
+// pickReviewers returns up to limit active members of teamName, skipping
+// exclude and observers
+func (s *SQLStore) pickReviewers(q sqlx.Queryer, teamName string, exclude []string, limit int) ([]string, error) {
+	var reviewers []string
+	err := sqlx.Select(q, &reviewers, `
+		SELECT u.user_id
+		FROM users u
+		JOIN team_members tm ON tm.user_id = u.user_id
+		WHERE tm.team_name = $1
+		AND tm.role <> 'observer'
+		AND u.is_active = true
+		AND NOT u.is_bot
+		AND u.user_id <> ALL($2)
+		LIMIT $3`,
+		teamName, pq.Array(exclude), limit)
+	return reviewers, err
+}
+
+func (s *SQLStore) MergePR(id string, force bool) (models.PullRequest, error) {
+	id, err := s.resolvePRID(s.db, id)
+	if err != nil {
+		return models.PullRequest{}, err
+	}
+
+	tx, err := s.db.Beginx()
+	if err != nil {
+		return models.PullRequest{}, err
+	}
+	defer tx.Rollback()
+
+	// The PR row stays locked while the policy is checked, so nothing the
+	// merge depends on can change before it commits
 	var currentStatus string
-	err := s.db.Get(&currentStatus, "SELECT status FROM prs WHERE pull_request_id = $1", id)
+	err = tx.Get(&currentStatus, "SELECT status FROM prs WHERE pull_request_id = $1 FOR UPDATE", id)
 	if err != nil {
 		return models.PullRequest{}, ErrNotFound
 	}
 
+	if currentStatus == "CLOSED" {
+		return models.PullRequest{}, ErrPRClosed
+	}
 	if currentStatus != "MERGED" {
-		_, err = s.db.Exec(
+		// A stacked PR waits for its parent regardless of the merge policy
+		var parentOpen bool
+		err = tx.Get(&parentOpen, `
+			SELECT EXISTS(
+				SELECT 1 FROM prs c JOIN prs p ON p.pull_request_id = c.depends_on
+				WHERE c.pull_request_id = $1 AND p.status = 'OPEN'
+			)`, id)
+		if err != nil {
+			return models.PullRequest{}, err
+		}
+		if parentOpen {
+			return models.PullRequest{}, ErrParentOpen
+		}
+
+		violations, err := s.evaluateMergePolicy(tx, id)
+		if err != nil {
+			return models.PullRequest{}, err
+		}
+		if len(violations) > 0 && !force {
+			return models.PullRequest{}, &PolicyViolationError{Violations: violations}
+		}
+
+		_, err = tx.Exec(
 			"UPDATE prs SET status = 'MERGED', merged_at = $1 WHERE pull_request_id = $2",
 			time.Now(), id,
 		)
 		if err != nil {
 			return models.PullRequest{}, err
 		}
+
+		// Bypassing the policy is only allowed for admins and always leaves a trace
+		if len(violations) > 0 {
+			err = s.audit(tx, "pr.force_merge", "admin", id, map[string]interface{}{"violations": violations})
+			if err != nil {
+				return models.PullRequest{}, err
+			}
+		}
+
+		if err := tx.Commit(); err != nil {
+			return models.PullRequest{}, err
+		}
 	}
 
 	return s.GetPR(id)
 }
 
-func (s *SQLStore) ReassignReviewer(prID, oldReviewerID string) (models.PullRequest, string, error) {
-	// Check if PR is merged
+// ClosePR marks an open PR closed without merging after the code host
+// closed it. Closing a closed PR changes nothing.
+func (s *SQLStore) ClosePR(id, provider string) (models.PullRequest, error) {
+	return s.setPRClosed(id, provider, true)
+}
+
+// RecordExternalMerge marks the PR merged after the code host merged it.
+// Neither stacked parents nor the merge policy can stop a merge that already
+// happened; the webhook is recorded as the actor. Recording a merged PR
+// again changes nothing.
+func (s *SQLStore) RecordExternalMerge(id, provider string) (models.PullRequest, error) {
+	id, err := s.resolvePRID(s.db, id)
+	if err != nil {
+		return models.PullRequest{}, err
+	}
+
+	tx, err := s.db.Beginx()
+	if err != nil {
+		return models.PullRequest{}, err
+	}
+	defer tx.Rollback()
+
+	var currentStatus string
+	if err := tx.Get(&currentStatus, "SELECT status FROM prs WHERE pull_request_id = $1 FOR UPDATE", id); err != nil {
+		return models.PullRequest{}, ErrNotFound
+	}
+	if currentStatus != "MERGED" {
+		_, err = tx.Exec("UPDATE prs SET status = 'MERGED', merged_at = NOW(), closed_at = NULL WHERE pull_request_id = $1", id)
+		if err != nil {
+			return models.PullRequest{}, err
+		}
+		err = s.audit(tx, "pr.external_merge", "webhook", id, map[string]interface{}{"provider": provider})
+		if err != nil {
+			return models.PullRequest{}, err
+		}
+		if err := tx.Commit(); err != nil {
+			return models.PullRequest{}, err
+		}
+	}
+
+	return s.GetPR(id)
+}
+
+// ReopenPR reopens a closed PR with its reviewers kept after the code host
+// reopened it. Reopening an open PR changes nothing.
+func (s *SQLStore) ReopenPR(id, provider string) (models.PullRequest, error) {
+	return s.setPRClosed(id, provider, false)
+}
+
+// setPRClosed closes or reopens a PR and audits the change with the webhook
+// as the actor
+func (s *SQLStore) setPRClosed(id, provider string, closed bool) (models.PullRequest, error) {
+	id, err := s.resolvePRID(s.db, id)
+	if err != nil {
+		return models.PullRequest{}, err
+	}
+
+	tx, err := s.db.Beginx()
+	if err != nil {
+		return models.PullRequest{}, err
+	}
+	defer tx.Rollback()
+
+	var currentStatus string
+	if err := tx.Get(&currentStatus, "SELECT status FROM prs WHERE pull_request_id = $1 FOR UPDATE", id); err != nil {
+		return models.PullRequest{}, ErrNotFound
+	}
+	if currentStatus == "MERGED" {
+		return models.PullRequest{}, ErrPRMerged
+	}
+
+	action := ""
+	switch {
+	case closed && currentStatus == "OPEN":
+		action = "pr.close"
+		_, err = tx.Exec("UPDATE prs SET status = 'CLOSED', closed_at = NOW() WHERE pull_request_id = $1", id)
+	case !closed && currentStatus == "CLOSED":
+		action = "pr.reopen"
+		_, err = tx.Exec("UPDATE prs SET status = 'OPEN', closed_at = NULL WHERE pull_request_id = $1", id)
+	}
+	if err != nil {
+		return models.PullRequest{}, err
+	}
+	if action != "" {
+		if err := s.audit(tx, action, "webhook", id, map[string]interface{}{"provider": provider}); err != nil {
+			return models.PullRequest{}, err
+		}
+		if err := tx.Commit(); err != nil {
+			return models.PullRequest{}, err
+		}
+	}
+	return s.GetPR(id)
+}
+
+// ReassignReviewer also returns the ID of the recorded operation, which
+// /admin/operations/{id}/revert undoes
+func (s *SQLStore) ReassignReviewer(prID, oldReviewerID string) (models.PullRequest, string, int64, error) {
+	prID, err := s.resolvePRID(s.db, prID)
+	if err != nil {
+		return models.PullRequest{}, "", 0, err
+	}
+
+	tx, err := s.db.Beginx()
+	if err != nil {
+		return models.PullRequest{}, "", 0, err
+	}
+	defer tx.Rollback()
+
+	newReviewerID, err := s.reassignInTx(tx, prID, oldReviewerID)
+	if err != nil {
+		return models.PullRequest{}, "", 0, err
+	}
+
+	swap := models.Reassignment{PullRequestID: prID, OldReviewerID: oldReviewerID, NewReviewerID: newReviewerID}
+	operationID, err := s.recordOperation(tx, opReassign, prID, nil, []models.Reassignment{swap})
+	if err != nil {
+		return models.PullRequest{}, "", 0, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return models.PullRequest{}, "", 0, err
+	}
+
+	pr, _ := s.GetPR(prID)
+	return pr, newReviewerID, operationID, nil
+}
+
+// openPRStatus returns ErrPRMerged or ErrPRClosed unless status is OPEN
+func openPRStatus(status string) error {
+	switch status {
+	case "OPEN":
+		return nil
+	case "MERGED":
+		return ErrPRMerged
+	default:
+		return ErrPRClosed
+	}
+}
+
+// reassignInTx replaces oldReviewerID on an open PR with a teammate
+func (s *SQLStore) reassignInTx(tx *sqlx.Tx, prID, oldReviewerID string) (string, error) {
+	// Only open PRs get their reviewers changed
 	var status string
-	err := s.db.Get(&status, "SELECT status FROM prs WHERE pull_request_id = $1", prID)
+	err := tx.Get(&status, "SELECT status FROM prs WHERE pull_request_id = $1", prID)
 	if err != nil {
-		return models.PullRequest{}, "", ErrNotFound
+		return "", ErrNotFound
 	}
-	if status == "MERGED" {
-		return models.PullRequest{}, "", ErrPRMerged
+	if err := openPRStatus(status); err != nil {
+		return "", err
 	}
 
 	// Check if old reviewer is assigned
 	var isAssigned bool
-	err = s.db.Get(&isAssigned, "SELECT EXISTS(SELECT 1 FROM pr_reviewers WHERE pull_request_id = $1 AND user_id = $2)", prID, oldReviewerID)
+	err = tx.Get(&isAssigned, "SELECT EXISTS(SELECT 1 FROM pr_reviewers WHERE pull_request_id = $1 AND user_id = $2)", prID, oldReviewerID)
 	if err != nil || !isAssigned {
-		return models.PullRequest{}, "", ErrNotAssigned
+		return "", ErrNotAssigned
 	}
 
-	// Get team of old reviewer
-	var teamName string
-	err = s.db.Get(&teamName, "SELECT team_name FROM team_members WHERE user_id = $1", oldReviewerID)
+	newReviewerID, err := s.findReplacementReviewer(tx, oldReviewerID, prID)
 	if err != nil {
-		return models.PullRequest{}, "", ErrNotFound
+		return "", err
 	}
 
-	// Find replacement (active user from same team, not already assigned, not the old reviewer)
-	var newReviewerID string
-	err = s.db.Get(&newReviewerID, `
-		SELECT u.user_id 
-		FROM users u 
-		JOIN team_members tm ON tm.user_id = u.user_id 
-		WHERE tm.team_name = $1 
-		AND u.is_active = true 
-		AND u.user_id != $2
-		AND u.user_id NOT IN (
-			SELECT user_id FROM pr_reviewers WHERE pull_request_id = $3
-		)
-		LIMIT 1`,
-		teamName, oldReviewerID, prID)
-	if err != nil {
-		return models.PullRequest{}, "", ErrNoCandidate
+	if err := s.replaceReviewer(tx, prID, oldReviewerID, newReviewerID); err != nil {
+		return "", err
 	}
+	return newReviewerID, nil
+}
 
-	// Perform reassignment
-	_, err = s.db.Exec(
-		"UPDATE pr_reviewers SET user_id = $1 WHERE pull_request_id = $2 AND user_id = $3",
+// replaceReviewer hands an assignment over; the review state and the SLA
+// deadline start from scratch
+func (s *SQLStore) replaceReviewer(q sqlx.Ext, prID, oldReviewerID, newReviewerID string) error {
+	_, err := q.Exec(`
+		UPDATE pr_reviewers
+		SET user_id = $1, state = 'PENDING', reviewed_at = NULL, first_reviewed_at = NULL,
+		    assigned_at = NOW(), accepted_at = NULL, ack_timeout_failed_at = NULL
+		WHERE pull_request_id = $2 AND user_id = $3`,
 		newReviewerID, prID, oldReviewerID,
 	)
 	if err != nil {
-		return models.PullRequest{}, "", err
+		return err
 	}
-
-	pr, _ := s.GetPR(prID)
-	return pr, newReviewerID, nil
+	if err := s.setDueAt(q, prID, newReviewerID); err != nil {
+		return err
+	}
+	return s.queueReviewerSync(q, prID)
 }
 
 func (s *SQLStore) ListPRsAssignedTo(userID string) ([]models.PullRequest, error) {
 	var prs []models.PullRequest
 	err := s.db.Select(&prs, `
-		SELECT p.pull_request_id, p.pull_request_name, p.author_id, p.status, p.created_at, p.merged_at 
-		FROM prs p 
-		JOIN pr_reviewers r ON r.pull_request_id = p.pull_request_id 
-		WHERE r.user_id = $1`, userID)
+		SELECT `+prColumns+`
+		FROM prs
+		WHERE pull_request_id IN (SELECT pull_request_id FROM pr_reviewers WHERE user_id = $1)
+		ORDER BY priority DESC, created_at ASC`, userID)
 	if err != nil {
 		return nil, err
 	}
@@ -326,6 +859,7 @@ func (s *SQLStore) GetStats() (map[string]interface{}, error) {
 		SELECT u.user_id, u.username, COUNT(pr.user_id) as assignment_count
 		FROM users u
 		LEFT JOIN pr_reviewers pr ON u.user_id = pr.user_id
+		WHERE NOT u.is_bot
 		GROUP BY u.user_id, u.username
 		ORDER BY assignment_count DESC`)
 	if err != nil {
@@ -334,10 +868,13 @@ func (s *SQLStore) GetStats() (map[string]interface{}, error) {
 
 	// PR statistics
 	var prStats struct {
-		TotalPRs     int     `db:"total_prs"`
-		OpenPRs      int     `db:"open_prs"`
-		MergedPRs    int     `db:"merged_prs"`
-		AvgReviewers float64 `db:"avg_reviewers"`
+		TotalPRs        int     `db:"total_prs"`
+		OpenPRs         int     `db:"open_prs"`
+		MergedPRs       int     `db:"merged_prs"`
+		ClosedPRs       int     `db:"closed_prs"`
+		AvgReviewers    float64 `db:"avg_reviewers"`
+		AvgLinesChanged float64 `db:"avg_lines_changed"`
+		AvgFilesChanged float64 `db:"avg_files_changed"`
 	}
 	
 	err = s.db.Get(&prStats, `
@@ -345,9 +882,13 @@ func (s *SQLStore) GetStats() (map[string]interface{}, error) {
 			COUNT(*) as total_prs,
 			COUNT(CASE WHEN status = 'OPEN' THEN 1 END) as open_prs,
 			COUNT(CASE WHEN status = 'MERGED' THEN 1 END) as merged_prs,
-			COALESCE(AVG(reviewer_count), 0) as avg_reviewers
+			COUNT(CASE WHEN status = 'CLOSED' THEN 1 END) as closed_prs,
+			COALESCE(AVG(reviewer_count), 0) as avg_reviewers,
+			COALESCE(AVG(lines_changed), 0) as avg_lines_changed,
+			COALESCE(AVG(files_changed), 0) as avg_files_changed
 		FROM (
-			SELECT p.pull_request_id, p.status, COUNT(r.user_id) as reviewer_count
+			SELECT p.pull_request_id, p.status, COUNT(r.user_id) as reviewer_count,
+			       p.lines_added + p.lines_removed as lines_changed, p.files_changed
 			FROM prs p
 			LEFT JOIN pr_reviewers r ON p.pull_request_id = r.pull_request_id
 			GROUP BY p.pull_request_id, p.status
@@ -357,34 +898,120 @@ func (s *SQLStore) GetStats() (map[string]interface{}, error) {
 	}
 
 	// Team statistics
+	// Total counts roll up every sub-team of the team
 	var teamStats []struct {
-		TeamName  string `db:"team_name"`
-		UserCount int    `db:"user_count"`
-		PRCount   int    `db:"pr_count"`
+		TeamName       string `db:"team_name"`
+		ParentTeam     string `db:"parent_team"`
+		UserCount      int    `db:"user_count"`
+		PRCount        int    `db:"pr_count"`
+		TotalUserCount int    `db:"total_user_count"`
+		TotalPRCount   int    `db:"total_pr_count"`
 	}
 	
 	err = s.db.Select(&teamStats, `
-		SELECT t.name as team_name, 
-		       COUNT(DISTINCT tm.user_id) as user_count,
-		       COUNT(DISTINCT p.pull_request_id) as pr_count
+		WITH RECURSIVE tree AS (
+			SELECT name AS root, name FROM teams
+			UNION
+			SELECT tree.root, c.name FROM teams c JOIN tree ON c.parent_team = tree.name
+		)
+		SELECT t.name as team_name, COALESCE(t.parent_team, '') as parent_team,
+		       COUNT(DISTINCT tm.user_id) FILTER (WHERE tree.name = t.name) as user_count,
+		       COUNT(DISTINCT p.pull_request_id) FILTER (WHERE tree.name = t.name) as pr_count,
+		       COUNT(DISTINCT tm.user_id) as total_user_count,
+		       COUNT(DISTINCT p.pull_request_id) as total_pr_count
 		FROM teams t
-		LEFT JOIN team_members tm ON t.name = tm.team_name
+		JOIN tree ON tree.root = t.name
+		LEFT JOIN team_members tm ON tree.name = tm.team_name
 		LEFT JOIN prs p ON tm.user_id = p.author_id
-		GROUP BY t.name`)
+		GROUP BY t.name, t.parent_team`)
+	if err != nil {
+		return nil, err
+	}
+
+	// PR size distribution, buckets by added plus removed lines
+	var sizeStats []struct {
+		Bucket       string  `db:"bucket"`
+		PRCount      int     `db:"pr_count"`
+		AvgReviewers float64 `db:"avg_reviewers"`
+	}
+
+	err = s.db.Select(&sizeStats, `
+		SELECT bucket, COUNT(*) as pr_count, AVG(reviewer_count) as avg_reviewers
+		FROM (
+			SELECT CASE
+			           WHEN p.lines_added + p.lines_removed < 100 THEN 'small'
+			           WHEN p.lines_added + p.lines_removed <= 500 THEN 'medium'
+			           ELSE 'large'
+			       END as bucket,
+			       (SELECT COUNT(*) FROM pr_reviewers r WHERE r.pull_request_id = p.pull_request_id) as reviewer_count
+			FROM prs p
+		) sized
+		GROUP BY bucket
+		ORDER BY bucket`)
+	if err != nil {
+		return nil, err
+	}
+
+	// Declined assignments were handed over, so they are counted on top of
+	// the current ones
+	var declineStats []struct {
+		UserID      string  `db:"user_id"`
+		Assignments int     `db:"assignments"`
+		Declines    int     `db:"declines"`
+		DeclineRate float64 `db:"decline_rate"`
+	}
+
+	err = s.db.Select(&declineStats, `
+		SELECT user_id, assignments + declines as assignments, declines,
+		       CASE WHEN assignments + declines = 0 THEN 0
+		            ELSE declines::float / (assignments + declines) END as decline_rate
+		FROM (
+			SELECT u.user_id,
+			       (SELECT COUNT(*) FROM pr_reviewers r WHERE r.user_id = u.user_id) as assignments,
+			       (SELECT COUNT(*) FROM pr_declines d WHERE d.user_id = u.user_id) as declines
+			FROM users u
+		) counts
+		WHERE declines > 0
+		ORDER BY decline_rate DESC`)
+	if err != nil {
+		return nil, err
+	}
+
+	slaStats, err := s.slaCompliance()
+	if err != nil {
+		return nil, err
+	}
+
+	botStats, err := s.botStats()
 	if err != nil {
 		return nil, err
 	}
 
 	stats["user_assignments"] = userAssignments
+	stats["reviewer_declines"] = declineStats
+	stats["sla_compliance"] = slaStats
 	stats["pr_statistics"] = prStats
+	stats["pr_size_statistics"] = sizeStats
 	stats["team_statistics"] = teamStats
+	stats["bot_statistics"] = botStats
 	stats["total_users"] = len(userAssignments)
+	stats["total_bots"] = len(botStats)
 
 	return stats, nil
 }
 
 // Mass deactivation
-func (s *SQLStore) MassDeactivate(teamName string, excludeUsers []string) (map[string]interface{}, error) {
+
+// MassDeactivate deactivates every member of the team except excludeUsers
+// and hands their open reviews over. Each review is reported with its new
+// reviewer or the reason it stays. A dry run rolls everything back; with
+// abortIfUnresolved nothing is saved when any review would stay. Otherwise
+// the change set is recorded under the returned operation_id.
+func (s *SQLStore) MassDeactivate(teamName string, excludeUsers []string, dryRun, abortIfUnresolved bool) (map[string]interface{}, error) {
+	if err := s.teamExists(teamName); err != nil {
+		return nil, err
+	}
+
 	tx, err := s.db.Beginx()
 	if err != nil {
 		return nil, err
@@ -402,64 +1029,95 @@ func (s *SQLStore) MassDeactivate(teamName string, excludeUsers []string) (map[s
 		}
 		query += fmt.Sprintf(" AND user_id NOT IN (%s)", strings.Join(placeholders, ","))
 	}
-	query += ")"
+	query += ") AND is_active RETURNING user_id"
 
-	result, err := tx.Exec(query, args...)
-	if err != nil {
+	var deactivated []string
+	if err := tx.Select(&deactivated, query, args...); err != nil {
 		return nil, err
 	}
 
-	deactivatedCount, _ := result.RowsAffected()
-
 	var prsWithInactiveReviewers []struct {
 		PRID       string `db:"pull_request_id"`
 		ReviewerID string `db:"user_id"`
 	}
 	
+	// Only the users switched off here hand over, so a revert never brings
+	// back reviewers who were inactive before
 	err = tx.Select(&prsWithInactiveReviewers, `
 		SELECT DISTINCT pr.pull_request_id, rev.user_id
 		FROM prs pr
 		JOIN pr_reviewers rev ON pr.pull_request_id = rev.pull_request_id
-		JOIN users u ON rev.user_id = u.user_id
 		WHERE pr.status = 'OPEN' 
-		AND u.is_active = false
-		AND rev.user_id IN (
-			SELECT user_id FROM team_members WHERE team_name = $1
-		)`, teamName)
+		AND rev.user_id = ANY($1)
+		ORDER BY pr.pull_request_id, rev.user_id`, pq.Array(deactivated))
 	if err != nil {
 		return nil, err
 	}
 
 	reassignedPRs := []string{}
+	reassignments := []models.Reassignment{}
+	unresolved := 0
 	for _, pr := range prsWithInactiveReviewers {
-		newReviewerID, err := s.findReplacementReviewer(tx, pr.ReviewerID, pr.PRID)
-		if err == nil {
-			_, err = tx.Exec(
-				"UPDATE pr_reviewers SET user_id = $1 WHERE pull_request_id = $2 AND user_id = $3",
-				newReviewerID, pr.PRID, pr.ReviewerID,
-			)
-			if err == nil {
-				reassignedPRs = append(reassignedPRs, pr.PRID)
-			}
+		r, err := s.handOverReview(tx, pr.PRID, pr.ReviewerID)
+		if err != nil {
+			return nil, err
+		}
+		if r.NewReviewerID != "" {
+			reassignedPRs = append(reassignedPRs, pr.PRID)
+		} else {
+			unresolved++
 		}
+		reassignments = append(reassignments, r)
 	}
 
-	if err := tx.Commit(); err != nil {
-		return nil, err
+	if abortIfUnresolved && unresolved > 0 {
+		return nil, &UnresolvedReviewsError{Reassignments: reassignments}
 	}
-
-	return map[string]interface{}{
+	result := map[string]interface{}{
 		"team_name":         teamName,
-		"deactivated_users": deactivatedCount,
+		"dry_run":           dryRun,
+		"deactivated_users": len(deactivated),
 		"reassigned_prs":    reassignedPRs,
 		"reassigned_count":  len(reassignedPRs),
-	}, nil
+		"unresolved_count":  unresolved,
+		"reassignments":     reassignments,
+	}
+	if dryRun {
+		return result, nil
+	}
+
+	operationID, err := s.recordOperation(tx, opMassDeactivate, teamName, deactivated, reassignments)
+	if err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	result["operation_id"] = operationID
+	return result, nil
 }
 
-// Helper function for finding replacement reviewer
+// Helper function for finding replacement reviewer: an active teammate of
+// the old reviewer who is not the author, not assigned to the PR and has not
+// declined it. A reviewer in several teams is replaced from the PR's team.
 func (s *SQLStore) findReplacementReviewer(tx *sqlx.Tx, oldReviewerID, prID string) (string, error) {
 	var teamName string
-	err := tx.Get(&teamName, "SELECT team_name FROM team_members WHERE user_id = $1", oldReviewerID)
+	err := tx.Get(&teamName, `
+		SELECT team_name FROM team_members
+		WHERE user_id = $1
+		ORDER BY team_name IS NOT DISTINCT FROM (`+prTeamSQL("$2")+`) DESC, team_name
+		LIMIT 1`, oldReviewerID, prID)
+	if err != nil {
+		return "", ErrNotFound
+	}
+
+	return s.findReplacementInTeam(tx, teamName, oldReviewerID, prID)
+}
+
+// findReplacementInTeam is findReplacementReviewer with the team given. When
+// the team has nobody left, sibling and parent teams are searched.
+func (s *SQLStore) findReplacementInTeam(tx *sqlx.Tx, teamName, oldReviewerID, prID string) (string, error) {
+	teams, err := s.fallbackTeams(tx, teamName)
 	if err != nil {
 		return "", err
 	}
@@ -469,14 +1127,24 @@ func (s *SQLStore) findReplacementReviewer(tx *sqlx.Tx, oldReviewerID, prID stri
 		SELECT u.user_id 
 		FROM users u 
 		JOIN team_members tm ON u.user_id = tm.user_id 
-		WHERE tm.team_name = $1 
+		WHERE tm.team_name = ANY($1::text[])
+		AND tm.role <> 'observer'
 		AND u.is_active = true 
+		AND NOT u.is_bot
 		AND u.user_id != $2
+		AND u.user_id <> (SELECT author_id FROM prs WHERE pull_request_id = $3)
 		AND u.user_id NOT IN (
 			SELECT user_id FROM pr_reviewers WHERE pull_request_id = $3
 		)
+		AND u.user_id NOT IN (
+			SELECT user_id FROM pr_declines WHERE pull_request_id = $3
+		)
+		ORDER BY array_position($1::text[], tm.team_name)
 		LIMIT 1`,
-		teamName, oldReviewerID, prID)
-	
+		pq.Array(append([]string{teamName}, teams...)), oldReviewerID, prID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", ErrNoCandidate
+	}
+
 	return newReviewerID, err
-}
\ No newline at end of file
+}
@@ -0,0 +1,111 @@
+package storage
+
+import (
+	"database/sql"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"pr-reviewer-service/internal/models"
+)
+
+// newTestStore returns a store on a fresh schema of the PostgreSQL database
+// in TEST_DATABASE_URL with every migration applied. Tests using it are
+// skipped when the variable is unset.
+func newTestStore(t *testing.T) *SQLStore {
+	t.Helper()
+	url := os.Getenv("TEST_DATABASE_URL")
+	if url == "" {
+		t.Skip("TEST_DATABASE_URL is not set")
+	}
+
+	admin, err := sql.Open("postgres", url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	schema := fmt.Sprintf("test_%d", time.Now().UnixNano())
+	if _, err := admin.Exec("CREATE SCHEMA " + schema); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		admin.Exec("DROP SCHEMA " + schema + " CASCADE")
+		admin.Close()
+	})
+
+	sep := "?"
+	if strings.Contains(url, "?") {
+		sep = "&"
+	}
+	db, err := sql.Open("postgres", url+sep+"search_path="+schema)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	files, err := filepath.Glob("../../migrations/*.sql")
+	if err != nil || len(files) == 0 {
+		t.Fatalf("no migrations found: %v", err)
+	}
+	sort.Strings(files)
+	for _, f := range files {
+		data, err := os.ReadFile(f)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := db.Exec(string(data)); err != nil {
+			t.Fatalf("%s: %v", filepath.Base(f), err)
+		}
+	}
+	return NewSQLStore(db).(*SQLStore)
+}
+
+// newPR returns an open PR created now, ready for CreatePR
+func newPR(id, authorID string) models.PullRequest {
+	now := time.Now()
+	return models.PullRequest{ID: id, Title: "PR " + id, AuthorID: authorID, Status: models.OPEN, CreatedAt: &now}
+}
+
+func TestMergeClosedPR(t *testing.T) {
+	s := newTestStore(t)
+	err := s.CreateTeam("backend", []models.User{
+		{UserID: "u1", Username: "Alice", IsActive: true},
+		{UserID: "u2", Username: "Bob", IsActive: true},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.CreatePR(newPR("pr-1", "u1")); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := s.ClosePR("pr-1", "github"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.MergePR("pr-1", true); err != ErrPRClosed {
+		t.Fatalf("Expected ErrPRClosed, got %v", err)
+	}
+	pr, err := s.GetPR("pr-1")
+	if err != nil || pr.Status != models.CLOSED || pr.ClosedAt == nil {
+		t.Errorf("Expected pr-1 to stay closed, got %+v (%v)", pr, err)
+	}
+
+	// Closing twice and reopening leave one audit entry per change
+	s.ClosePR("pr-1", "github")
+	if _, err := s.ReopenPR("pr-1", "github"); err != nil {
+		t.Fatal(err)
+	}
+	var actions []string
+	if err := s.db.Select(&actions, "SELECT action FROM audit_log WHERE target = 'pr-1' ORDER BY id"); err != nil {
+		t.Fatal(err)
+	}
+	if len(actions) != 2 || actions[0] != "pr.close" || actions[1] != "pr.reopen" {
+		t.Errorf("Expected pr.close and pr.reopen in the audit log, got %v", actions)
+	}
+	if _, err := s.MergePR("pr-1", true); err != nil {
+		t.Errorf("Expected the reopened PR to merge, got %v", err)
+	}
+}
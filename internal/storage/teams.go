@@ -0,0 +1,551 @@
+package storage
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"pr-reviewer-service/internal/models"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// AddTeamMember adds the user to an existing team. A new user is created
+// from member; an existing one keeps their name and active flag.
+func (s *SQLStore) AddTeamMember(teamName string, member models.User) (models.Team, error) {
+	if err := s.teamExists(teamName); err != nil {
+		return models.Team{}, err
+	}
+
+	tx, err := s.db.Beginx()
+	if err != nil {
+		return models.Team{}, err
+	}
+	defer tx.Rollback()
+
+	var isMember bool
+	err = tx.Get(&isMember, "SELECT EXISTS(SELECT 1 FROM team_members WHERE team_name = $1 AND user_id = $2)", teamName, member.UserID)
+	if err != nil {
+		return models.Team{}, err
+	}
+	if isMember {
+		return models.Team{}, ErrMemberExists
+	}
+
+	if err := s.insertMember(tx, teamName, member); err != nil {
+		return models.Team{}, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return models.Team{}, err
+	}
+	return s.GetTeam(teamName)
+}
+
+// insertMember creates the user unless they exist and adds them to the team
+func (s *SQLStore) insertMember(q sqlx.Execer, teamName string, member models.User) error {
+	_, err := q.Exec(
+		`INSERT INTO users (user_id, username, is_active, timezone) VALUES ($1, $2, $3, COALESCE(NULLIF($4, ''), 'UTC'))
+		ON CONFLICT (user_id) DO NOTHING`,
+		member.UserID, member.Username, member.IsActive, member.Timezone,
+	)
+	if err != nil {
+		return err
+	}
+
+	_, err = q.Exec(
+		"INSERT INTO team_members (team_name, user_id, role) VALUES ($1, $2, COALESCE(NULLIF($3, ''), 'member')::member_role)",
+		teamName, member.UserID, member.Role,
+	)
+	return err
+}
+
+// RemoveTeamMember takes the user out of the team and hands their open
+// reviews of the team's PRs to other members. Reviews without a candidate
+// stay with the user and are reported with a reason.
+func (s *SQLStore) RemoveTeamMember(teamName, userID string) ([]models.Reassignment, error) {
+	tx, err := s.db.Beginx()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	reassignments, err := s.removeMemberInTx(tx, teamName, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return reassignments, nil
+}
+
+func (s *SQLStore) removeMemberInTx(tx *sqlx.Tx, teamName, userID string) ([]models.Reassignment, error) {
+	reassignments, err := s.handOverTeamReviews(tx, teamName, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := tx.Exec("DELETE FROM team_members WHERE team_name = $1 AND user_id = $2", teamName, userID)
+	if err != nil {
+		return nil, err
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return nil, ErrNotFound
+	}
+	return reassignments, nil
+}
+
+// handOverTeamReviews moves the user's reviews of open PRs that belong to
+// the team (owned by it through the repository or, failing that, authored by
+// one of its members) to other members of the team
+func (s *SQLStore) handOverTeamReviews(tx *sqlx.Tx, teamName, userID string) ([]models.Reassignment, error) {
+	var prIDs []string
+	err := tx.Select(&prIDs, `
+		SELECT p.pull_request_id
+		FROM prs p
+		JOIN pr_reviewers r ON r.pull_request_id = p.pull_request_id
+		LEFT JOIN repositories repo ON repo.name = p.repository
+		WHERE p.status = 'OPEN'
+		AND r.user_id = $2
+		AND (repo.owning_team = $1 OR (repo.owning_team IS NULL AND p.author_id IN (
+			SELECT user_id FROM team_members WHERE team_name = $1
+		)))
+		ORDER BY p.pull_request_id`, teamName, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	reassignments := []models.Reassignment{}
+	for _, prID := range prIDs {
+		r := models.Reassignment{PullRequestID: prID, OldReviewerID: userID}
+		newReviewerID, err := s.findReplacementInTeam(tx, teamName, userID, prID)
+		if errors.Is(err, ErrNoCandidate) {
+			r.Reason = err.Error()
+			reassignments = append(reassignments, r)
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		if err := s.replaceReviewer(tx, prID, userID, newReviewerID); err != nil {
+			return nil, err
+		}
+		r.NewReviewerID = newReviewerID
+		reassignments = append(reassignments, r)
+	}
+
+	return reassignments, nil
+}
+
+// RenameTeam renames a team; references follow through ON UPDATE CASCADE and
+// the history tables are updated alongside
+func (s *SQLStore) RenameTeam(oldName, newName string) (models.Team, error) {
+	if err := s.teamExists(oldName); err != nil {
+		return models.Team{}, err
+	}
+	if err := s.teamExists(newName); err == nil {
+		return models.Team{}, ErrTeamExists
+	}
+
+	tx, err := s.db.Beginx()
+	if err != nil {
+		return models.Team{}, err
+	}
+	defer tx.Rollback()
+
+	if err := s.renameInTx(tx, oldName, newName); err != nil {
+		return models.Team{}, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return models.Team{}, err
+	}
+	return s.GetTeam(newName)
+}
+
+func (s *SQLStore) renameInTx(tx *sqlx.Tx, oldName, newName string) error {
+	for _, query := range []string{
+		"UPDATE teams SET name = $2 WHERE name = $1",
+		"UPDATE sla_breaches SET team_name = $2 WHERE team_name = $1",
+		"UPDATE pr_escalations SET team_name = $2 WHERE team_name = $1",
+	} {
+		if _, err := tx.Exec(query, oldName, newName); err != nil {
+			return err
+		}
+	}
+
+	return s.audit(tx, "team.rename", "api", oldName, map[string]string{"old_name": oldName, "new_name": newName})
+}
+
+// UpdateTeam applies changes to the team in order and in one transaction,
+// so a change that fails leaves the team as it was. Adding a member twice
+// or removing a non-member is not an error. The team is returned under its
+// final name.
+func (s *SQLStore) UpdateTeam(name string, changes []models.TeamChange) (models.Team, error) {
+	tx, err := s.db.Beginx()
+	if err != nil {
+		return models.Team{}, err
+	}
+	defer tx.Rollback()
+
+	var locked string
+	err = tx.Get(&locked, "SELECT name FROM teams WHERE name = $1 FOR UPDATE", name)
+	if errors.Is(err, sql.ErrNoRows) {
+		return models.Team{}, ErrNotFound
+	}
+	if err != nil {
+		return models.Team{}, err
+	}
+
+	for _, c := range changes {
+		switch c.Op {
+		case models.TeamAddMembers:
+			for _, m := range c.Members {
+				var exists, isMember bool
+				err := tx.QueryRow(`
+					SELECT EXISTS(SELECT 1 FROM users WHERE user_id = $2),
+					       EXISTS(SELECT 1 FROM team_members WHERE team_name = $1 AND user_id = $2)`,
+					name, m.UserID).Scan(&exists, &isMember)
+				if err != nil {
+					return models.Team{}, err
+				}
+				if !exists {
+					return models.Team{}, ErrNotFound
+				}
+				if isMember {
+					continue
+				}
+				if err := s.insertMember(tx, name, models.User{UserID: m.UserID, Role: m.Role}); err != nil {
+					return models.Team{}, err
+				}
+			}
+		case models.TeamRemoveMembers:
+			for _, m := range c.Members {
+				if _, err := s.removeMemberInTx(tx, name, m.UserID); err != nil && err != ErrNotFound {
+					return models.Team{}, err
+				}
+			}
+		case models.TeamReplaceMembers:
+			diff, err := s.reconcileInTx(tx, name, c.Members)
+			if err != nil {
+				return models.Team{}, err
+			}
+			if err := s.audit(tx, "team.reconcile", "api", name, diff); err != nil {
+				return models.Team{}, err
+			}
+		case models.TeamRename:
+			if c.NewName == "" || c.NewName == name {
+				continue
+			}
+			var taken bool
+			if err := tx.Get(&taken, "SELECT EXISTS(SELECT 1 FROM teams WHERE name = $1)", c.NewName); err != nil {
+				return models.Team{}, err
+			}
+			if taken {
+				return models.Team{}, ErrTeamExists
+			}
+			if err := s.renameInTx(tx, name, c.NewName); err != nil {
+				return models.Team{}, err
+			}
+			name = c.NewName
+		default:
+			return models.Team{}, fmt.Errorf("unknown team change %q", c.Op)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return models.Team{}, err
+	}
+	return s.GetTeam(name)
+}
+
+// DeleteTeam removes a team. With moveTo its members, repositories, merge
+// freezes and merge policy go to that team, unless it has a policy of its
+// own, and policies requiring a review from the team require one from
+// moveTo instead. Without moveTo the delete is refused while members still
+// review open PRs or policies require the team. The report lists what moved,
+// changed or was dropped with the team and who was left without a team.
+func (s *SQLStore) DeleteTeam(name, moveTo string) (map[string]interface{}, error) {
+	if err := s.teamExists(name); err != nil {
+		return nil, err
+	}
+	if moveTo != "" {
+		if err := s.teamExists(moveTo); err != nil {
+			return nil, err
+		}
+	}
+
+	tx, err := s.db.Beginx()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	members := []string{}
+	err = tx.Select(&members, "SELECT user_id FROM team_members WHERE team_name = $1 ORDER BY user_id", name)
+	if err != nil {
+		return nil, err
+	}
+
+	repositories := []string{}
+	orphaned := []string{}
+	movedPolicies := []models.MergePolicy{}
+	changedPolicies := []models.MergePolicy{}
+	movedFreezes := []models.MergeFreeze{}
+	if moveTo != "" {
+		_, err = tx.Exec(`
+			INSERT INTO team_members (team_name, user_id, role)
+			SELECT $2, user_id, role FROM team_members WHERE team_name = $1
+			ON CONFLICT DO NOTHING`, name, moveTo)
+		if err != nil {
+			return nil, err
+		}
+		err = tx.Select(&repositories, "UPDATE repositories SET owning_team = $2 WHERE owning_team = $1 RETURNING name", name, moveTo)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := tx.Exec("UPDATE users SET bot_team = $2 WHERE bot_team = $1", name, moveTo); err != nil {
+			return nil, err
+		}
+
+		err = tx.Select(&changedPolicies, `
+			UPDATE merge_policies SET required_reviewer_team = $2
+			WHERE required_reviewer_team = $1
+			RETURNING `+mergePolicyColumns, name, moveTo)
+		if err != nil {
+			return nil, err
+		}
+		err = tx.Select(&movedPolicies, `
+			UPDATE merge_policies SET team_name = $2
+			WHERE team_name = $1
+			AND NOT EXISTS (SELECT 1 FROM merge_policies WHERE team_name = $2)
+			RETURNING `+mergePolicyColumns, name, moveTo)
+		if err != nil {
+			return nil, err
+		}
+		err = tx.Select(&movedFreezes, "UPDATE merge_freezes SET team_name = $2 WHERE team_name = $1 RETURNING "+mergeFreezeColumns, name, moveTo)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		var busy []string
+		err = tx.Select(&busy, `
+			SELECT DISTINCT r.pull_request_id
+			FROM pr_reviewers r
+			JOIN prs p ON p.pull_request_id = r.pull_request_id
+			JOIN team_members tm ON tm.user_id = r.user_id
+			WHERE p.status = 'OPEN' AND tm.team_name = $1
+			ORDER BY r.pull_request_id`, name)
+		if err != nil {
+			return nil, err
+		}
+		if len(busy) > 0 {
+			return nil, &TeamBusyError{PullRequestIDs: busy}
+		}
+
+		// Dropping the rule would silently weaken these policies
+		var requiring []models.MergePolicy
+		err = tx.Select(&requiring, "SELECT "+mergePolicyColumns+" FROM merge_policies WHERE required_reviewer_team = $1 ORDER BY id", name)
+		if err != nil {
+			return nil, err
+		}
+		if len(requiring) > 0 {
+			return nil, &TeamRequiredError{Policies: requiring}
+		}
+
+		// Owned repositories are released (owning_team is SET NULL)
+		err = tx.Select(&repositories, "SELECT name FROM repositories WHERE owning_team = $1 ORDER BY name", name)
+		if err != nil {
+			return nil, err
+		}
+		err = tx.Select(&orphaned, `
+			SELECT user_id FROM team_members
+			WHERE team_name = $1
+			AND user_id NOT IN (SELECT user_id FROM team_members WHERE team_name <> $1)
+			ORDER BY user_id`, name)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// Whatever policy and freezes are still the team's go with it
+	droppedPolicies := []models.MergePolicy{}
+	err = tx.Select(&droppedPolicies, "SELECT "+mergePolicyColumns+" FROM merge_policies WHERE team_name = $1", name)
+	if err != nil {
+		return nil, err
+	}
+	droppedFreezes := []models.MergeFreeze{}
+	err = tx.Select(&droppedFreezes, "SELECT "+mergeFreezeColumns+" FROM merge_freezes WHERE team_name = $1 ORDER BY id", name)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := tx.Exec("DELETE FROM teams WHERE name = $1", name); err != nil {
+		return nil, err
+	}
+
+	report := map[string]interface{}{
+		"team_name":              name,
+		"moved_to":               moveTo,
+		"members":                members,
+		"repositories":           repositories,
+		"orphaned_members":       orphaned,
+		"moved_merge_policies":   movedPolicies,
+		"changed_merge_policies": changedPolicies,
+		"dropped_merge_policies": droppedPolicies,
+		"moved_merge_freezes":    movedFreezes,
+		"dropped_merge_freezes":  droppedFreezes,
+	}
+	if err := s.audit(tx, "team.delete", "api", name, report); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return report, nil
+}
+
+// ReconcileTeam makes the team's roster match members: missing users are
+// added, extra ones removed with their open reviews handed over, and
+// username, active flag and role updated. Deactivated members hand their
+// reviews over as well; fields a member leaves out keep their stored value.
+// The team is created if needed. A dry run computes the same diff and rolls
+// everything back.
+func (s *SQLStore) ReconcileTeam(name string, members []models.MemberSpec, dryRun bool) (models.TeamDiff, error) {
+	tx, err := s.db.Beginx()
+	if err != nil {
+		return models.TeamDiff{}, err
+	}
+	defer tx.Rollback()
+
+	diff, err := s.reconcileInTx(tx, name, members)
+	if err != nil {
+		return diff, err
+	}
+	diff.DryRun = dryRun
+	if dryRun {
+		return diff, nil
+	}
+
+	if err := s.audit(tx, "team.reconcile", "api", name, diff); err != nil {
+		return diff, err
+	}
+	return diff, tx.Commit()
+}
+
+func (s *SQLStore) reconcileInTx(tx *sqlx.Tx, name string, members []models.MemberSpec) (models.TeamDiff, error) {
+	diff := models.TeamDiff{
+		TeamName:      name,
+		Added:         []string{},
+		Removed:       []string{},
+		Updated:       []models.MemberChange{},
+		Reassignments: []models.Reassignment{},
+	}
+
+	result, err := tx.Exec("INSERT INTO teams (name) VALUES ($1) ON CONFLICT DO NOTHING", name)
+	if err != nil {
+		return diff, err
+	}
+	n, _ := result.RowsAffected()
+	diff.Created = n > 0
+
+	var current []models.User
+	err = tx.Select(&current, `
+		SELECT u.user_id, u.username, u.is_active, tm.role
+		FROM users u
+		JOIN team_members tm ON tm.user_id = u.user_id
+		WHERE tm.team_name = $1
+		ORDER BY u.user_id`, name)
+	if err != nil {
+		return diff, err
+	}
+	existing := map[string]models.User{}
+	for _, u := range current {
+		existing[u.UserID] = u
+	}
+
+	// Additions and updates go first so removed members' reviews can be
+	// handed to the roster as it is meant to be
+	desired := map[string]bool{}
+	for _, m := range members {
+		desired[m.UserID] = true
+		old, ok := existing[m.UserID]
+		if !ok {
+			// A user from another team joins as they are and is then
+			// updated like the members
+			err := tx.Get(&old, "SELECT user_id, username, is_active FROM users WHERE user_id = $1", m.UserID)
+			if err != nil && !errors.Is(err, sql.ErrNoRows) {
+				return diff, err
+			}
+			user := models.User{
+				UserID:   m.UserID,
+				Username: m.Username,
+				IsActive: m.IsActive == nil || *m.IsActive,
+				Timezone: m.Timezone,
+				Role:     m.Role,
+			}
+			if err := s.insertMember(tx, name, user); err != nil {
+				return diff, err
+			}
+			diff.Added = append(diff.Added, m.UserID)
+			if old.UserID == "" {
+				continue
+			}
+			old.Role = m.Role
+		}
+
+		var changes []models.FieldChange
+		if m.Username != "" && old.Username != m.Username {
+			changes = append(changes, models.FieldChange{Field: "username", Old: old.Username, New: m.Username})
+			if _, err := tx.Exec("UPDATE users SET username = $1 WHERE user_id = $2", m.Username, m.UserID); err != nil {
+				return diff, err
+			}
+		}
+		if m.IsActive != nil && old.IsActive != *m.IsActive {
+			changes = append(changes, models.FieldChange{Field: "is_active", Old: old.IsActive, New: *m.IsActive})
+			if *m.IsActive {
+				_, err = tx.Exec("UPDATE users SET is_active = true WHERE user_id = $1", m.UserID)
+			} else {
+				// Deactivation hands reviews over like /users/setIsActive
+				var reassignments []models.Reassignment
+				reassignments, err = s.deactivateInTx(tx, m.UserID, nil)
+				diff.Reassignments = append(diff.Reassignments, reassignments...)
+			}
+			if err != nil {
+				return diff, err
+			}
+		}
+		if m.Role != "" && old.Role != m.Role {
+			changes = append(changes, models.FieldChange{Field: "role", Old: old.Role, New: m.Role})
+			_, err = tx.Exec("UPDATE team_members SET role = $1 WHERE team_name = $2 AND user_id = $3", m.Role, name, m.UserID)
+			if err != nil {
+				return diff, err
+			}
+		}
+		if len(changes) == 0 {
+			continue
+		}
+		diff.Updated = append(diff.Updated, models.MemberChange{UserID: m.UserID, Changes: changes})
+	}
+
+	for _, u := range current {
+		if desired[u.UserID] {
+			continue
+		}
+		reassignments, err := s.handOverTeamReviews(tx, name, u.UserID)
+		if err != nil {
+			return diff, err
+		}
+		_, err = tx.Exec("DELETE FROM team_members WHERE team_name = $1 AND user_id = $2", name, u.UserID)
+		if err != nil {
+			return diff, err
+		}
+		diff.Removed = append(diff.Removed, u.UserID)
+		diff.Reassignments = append(diff.Reassignments, reassignments...)
+	}
+
+	return diff, nil
+}
@@ -0,0 +1,111 @@
+package storage
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"pr-reviewer-service/internal/models"
+)
+
+func TestDeleteTeamMergePolicies(t *testing.T) {
+	s := newTestStore(t)
+	for _, name := range []string{"backend", "platform", "qa"} {
+		if err := s.CreateTeam(name, []models.User{{UserID: name + "-1", Username: name, IsActive: true}}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, p := range []models.MergePolicy{
+		{TeamName: "backend", MinApprovals: 1, RequiredReviewerTeam: "qa"},
+		{TeamName: "qa", MinApprovals: 2},
+	} {
+		if _, err := s.SetMergePolicy(p); err != nil {
+			t.Fatal(err)
+		}
+	}
+	now := time.Now()
+	if _, err := s.AddMergeFreeze(models.MergeFreeze{TeamName: "qa", StartsAt: now, EndsAt: now.Add(time.Hour), Reason: "release"}); err != nil {
+		t.Fatal(err)
+	}
+
+	// The backend policy would silently lose its required review
+	_, err := s.DeleteTeam("qa", "")
+	required, ok := err.(*TeamRequiredError)
+	if !ok || len(required.Policies) != 1 || required.Policies[0].TeamName != "backend" {
+		t.Fatalf("Expected the backend policy to block the delete, got %v", err)
+	}
+
+	report, err := s.DeleteTeam("qa", "platform")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p, _ := s.GetMergePolicy("backend"); p.RequiredReviewerTeam != "platform" {
+		t.Errorf("Expected backend to require platform now, got %+v", p)
+	}
+	if p, err := s.GetMergePolicy("platform"); err != nil || p.MinApprovals != 2 {
+		t.Errorf("Expected the qa policy to move to platform, got %+v (%v)", p, err)
+	}
+	if freezes, _ := s.ListMergeFreezes("platform"); len(freezes) != 1 || freezes[0].TeamName != "platform" {
+		t.Errorf("Expected the qa freeze to move to platform, got %+v", freezes)
+	}
+	if len(report["changed_merge_policies"].([]models.MergePolicy)) != 1 ||
+		len(report["moved_merge_policies"].([]models.MergePolicy)) != 1 ||
+		len(report["moved_merge_freezes"].([]models.MergeFreeze)) != 1 {
+		t.Errorf("Expected the report to list the changes, got %+v", report)
+	}
+
+	// platform keeps its own policy, so the one of backend is dropped
+	report, err = s.DeleteTeam("backend", "platform")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dropped := report["dropped_merge_policies"].([]models.MergePolicy); len(dropped) != 1 || dropped[0].TeamName != "backend" {
+		t.Errorf("Expected the backend policy to be dropped, got %+v", dropped)
+	}
+	var details string
+	if err := s.db.Get(&details, "SELECT details FROM audit_log WHERE action = 'team.delete' AND target = 'backend'"); err != nil {
+		t.Fatal(err)
+	}
+	var audited map[string]json.RawMessage
+	if err := json.Unmarshal([]byte(details), &audited); err != nil || len(audited["dropped_merge_policies"]) < 3 {
+		t.Errorf("Expected the dropped policy in the audit entry, got %s (%v)", details, err)
+	}
+}
+
+func TestUpdateTeamIsAtomic(t *testing.T) {
+	s := newTestStore(t)
+	err := s.CreateTeam("backend", []models.User{
+		{UserID: "u1", Username: "Alice", IsActive: true},
+		{UserID: "u2", Username: "Bob", IsActive: true},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.CreateTeam("frontend", []models.User{{UserID: "u3", Username: "Carol", IsActive: true}}); err != nil {
+		t.Fatal(err)
+	}
+
+	// The rename fails after the removal, which is rolled back with it
+	_, err = s.UpdateTeam("backend", []models.TeamChange{
+		{Op: models.TeamRemoveMembers, Members: []models.MemberSpec{{UserID: "u2"}}},
+		{Op: models.TeamRename, NewName: "frontend"},
+	})
+	if err != ErrTeamExists {
+		t.Fatalf("Expected ErrTeamExists, got %v", err)
+	}
+	if team, _ := s.GetTeam("backend"); len(team.Members) != 2 {
+		t.Errorf("Expected backend to keep both members, got %+v", team.Members)
+	}
+
+	team, err := s.UpdateTeam("backend", []models.TeamChange{
+		{Op: models.TeamAddMembers, Members: []models.MemberSpec{{UserID: "u3"}, {UserID: "u1"}}},
+		{Op: models.TeamRemoveMembers, Members: []models.MemberSpec{{UserID: "u2"}}},
+		{Op: models.TeamRename, NewName: "platform"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if team.Name != "platform" || len(team.Members) != 2 {
+		t.Errorf("Expected platform with u1 and u3, got %+v", team)
+	}
+}
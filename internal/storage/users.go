@@ -0,0 +1,356 @@
+package storage
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+
+	"pr-reviewer-service/internal/models"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+)
+
+const userColumns = `u.user_id, u.username, u.is_active, u.timezone, u.ooo_until, u.is_bot, COALESCE(u.bot_team, '') AS bot_team,
+		       COALESCE((SELECT team_name FROM team_members WHERE user_id = u.user_id ORDER BY team_name LIMIT 1), '') AS team_name`
+
+func (s *SQLStore) GetUser(userID string) (models.User, error) {
+	var u models.User
+	err := s.db.Get(&u, "SELECT "+userColumns+" FROM users u WHERE u.user_id = $1", userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return models.User{}, ErrNotFound
+	}
+	if err != nil {
+		return models.User{}, err
+	}
+	users := []models.User{u}
+	if err := s.loadSkills(users); err != nil {
+		return models.User{}, err
+	}
+	return users[0], nil
+}
+
+// ListUsers returns one page of users matching the filter and the total
+// number of matches
+func (s *SQLStore) ListUsers(f models.UserFilter) ([]models.User, int, error) {
+	const where = `
+		WHERE ($1 = '' OR u.user_id = $1)
+		AND ($2 = '' OR u.user_id IN (SELECT user_id FROM team_members WHERE team_name = $2))
+		AND ($3::boolean IS NULL OR u.is_active = $3)
+		AND ($4 = '' OR u.user_id IN (SELECT user_id FROM user_skills WHERE skill = $4))
+		AND ($5 = '' OR strpos(lower(u.user_id), lower($5)) > 0 OR strpos(lower(u.username), lower($5)) > 0)`
+
+	var total int
+	err := s.db.Get(&total, "SELECT COUNT(*) FROM users u"+where, f.UserID, f.TeamName, f.Active, f.Skill, f.Query)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	users := []models.User{}
+	err = s.db.Select(&users, "SELECT "+userColumns+" FROM users u"+where+`
+		ORDER BY u.user_id
+		OFFSET $6 LIMIT NULLIF($7, 0)`,
+		f.UserID, f.TeamName, f.Active, f.Skill, f.Query, f.Offset, f.Limit)
+	if err != nil {
+		return nil, 0, err
+	}
+	return users, total, s.loadSkills(users)
+}
+
+func (s *SQLStore) loadSkills(users []models.User) error {
+	ids := make([]string, len(users))
+	for i, u := range users {
+		ids[i] = u.UserID
+	}
+	var rows []struct {
+		UserID string `db:"user_id"`
+		Skill  string `db:"skill"`
+	}
+	err := s.db.Select(&rows, "SELECT user_id, skill FROM user_skills WHERE user_id = ANY($1) ORDER BY skill", pq.Array(ids))
+	if err != nil {
+		return err
+	}
+	skills := map[string][]string{}
+	for _, row := range rows {
+		skills[row.UserID] = append(skills[row.UserID], row.Skill)
+	}
+	for i := range users {
+		users[i].Skills = skills[users[i].UserID]
+	}
+	return nil
+}
+
+// GetUserProfile returns the user with their memberships, open review load
+// and the historyLimit most recent assignments
+func (s *SQLStore) GetUserProfile(userID string, historyLimit int) (models.UserProfile, error) {
+	var p models.UserProfile
+	user, err := s.GetUser(userID)
+	if err != nil {
+		return p, err
+	}
+	p.User = user
+	p.OutOfOffice = user.OOOUntil != nil && user.OOOUntil.After(time.Now())
+
+	p.Teams = []models.Membership{}
+	err = s.db.Select(&p.Teams, "SELECT team_name, role FROM team_members WHERE user_id = $1 ORDER BY team_name", userID)
+	if err != nil {
+		return p, err
+	}
+
+	err = s.db.Get(&p.OpenReviews, `
+		SELECT COUNT(*)
+		FROM pr_reviewers r
+		JOIN prs p ON p.pull_request_id = r.pull_request_id
+		WHERE r.user_id = $1 AND p.status = 'OPEN'`, userID)
+	if err != nil {
+		return p, err
+	}
+
+	p.RecentAssignments = []models.AssignmentRecord{}
+	err = s.db.Select(&p.RecentAssignments, `
+		SELECT p.pull_request_id, p.pull_request_name, p.status, r.state, r.assigned_at
+		FROM pr_reviewers r
+		JOIN prs p ON p.pull_request_id = r.pull_request_id
+		WHERE r.user_id = $1
+		ORDER BY r.assigned_at DESC
+		LIMIT $2`, userID, historyLimit)
+	return p, err
+}
+
+// CreateUser adds a user outside of any team
+func (s *SQLStore) CreateUser(u models.User) (models.User, error) {
+	result, err := s.db.Exec(`
+		INSERT INTO users (user_id, username, is_active, timezone, is_bot, bot_team)
+		VALUES ($1, $2, $3, COALESCE(NULLIF($4, ''), 'UTC'), $5, NULLIF($6, ''))
+		ON CONFLICT (user_id) DO NOTHING`,
+		u.UserID, u.Username, u.IsActive, u.Timezone, u.IsBot, u.BotTeam)
+	if err != nil {
+		return models.User{}, err
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return models.User{}, ErrUserExists
+	}
+	return s.GetUser(u.UserID)
+}
+
+// UpdateUser applies the patch to the username and profile fields: the
+// timezone, skills and out-of-office date. The user is locked while the
+// patch is applied, so concurrent updates of different fields do not undo
+// each other. The active flag is left to SetUserActive, DeactivateUser and
+// UpdateUserActive.
+func (s *SQLStore) UpdateUser(userID string, p models.UserPatch) (models.User, error) {
+	tx, err := s.db.Beginx()
+	if err != nil {
+		return models.User{}, err
+	}
+	defer tx.Rollback()
+
+	var u models.User
+	err = tx.Get(&u, "SELECT user_id, username, timezone, ooo_until FROM users WHERE user_id = $1 FOR UPDATE", userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return models.User{}, ErrNotFound
+	}
+	if err != nil {
+		return models.User{}, err
+	}
+	if err := tx.Select(&u.Skills, "SELECT skill FROM user_skills WHERE user_id = $1 ORDER BY skill", userID); err != nil {
+		return models.User{}, err
+	}
+
+	if p.Username != nil {
+		u.Username = *p.Username
+	}
+	if p.Timezone != nil {
+		u.Timezone = *p.Timezone
+	}
+	if p.Skills != nil {
+		u.Skills = *p.Skills
+	}
+	if p.SetOOOUntil {
+		u.OOOUntil = p.OOOUntil
+	}
+	if err := s.updateUserInTx(tx, u); err != nil {
+		return models.User{}, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return models.User{}, err
+	}
+	return s.GetUser(userID)
+}
+
+// UpdateUserActive is UpdateUser and the active flag in one transaction;
+// deactivation hands reviews over like DeactivateUser with a nil reassign
+func (s *SQLStore) UpdateUserActive(u models.User, active bool) (models.User, []models.Reassignment, error) {
+	tx, err := s.db.Beginx()
+	if err != nil {
+		return models.User{}, nil, err
+	}
+	defer tx.Rollback()
+
+	if err := s.updateUserInTx(tx, u); err != nil {
+		return models.User{}, nil, err
+	}
+
+	var wasActive bool
+	if err := tx.Get(&wasActive, "SELECT is_active FROM users WHERE user_id = $1", u.UserID); err != nil {
+		return models.User{}, nil, err
+	}
+	reassignments := []models.Reassignment{}
+	switch {
+	case wasActive && !active:
+		reassignments, err = s.deactivateInTx(tx, u.UserID, nil)
+	case !wasActive && active:
+		_, err = tx.Exec("UPDATE users SET is_active = true WHERE user_id = $1", u.UserID)
+	}
+	if err != nil {
+		return models.User{}, nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return models.User{}, nil, err
+	}
+	user, err := s.GetUser(u.UserID)
+	return user, reassignments, err
+}
+
+func (s *SQLStore) updateUserInTx(tx *sqlx.Tx, u models.User) error {
+	result, err := tx.Exec(`
+		UPDATE users
+		SET username = $1, timezone = COALESCE(NULLIF($2, ''), timezone), ooo_until = $3
+		WHERE user_id = $4`,
+		u.Username, u.Timezone, u.OOOUntil, u.UserID,
+	)
+	if err != nil {
+		return err
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return ErrNotFound
+	}
+
+	if _, err := tx.Exec("DELETE FROM user_skills WHERE user_id = $1", u.UserID); err != nil {
+		return err
+	}
+	for _, skill := range u.Skills {
+		_, err := tx.Exec("INSERT INTO user_skills (user_id, skill) VALUES ($1, $2) ON CONFLICT DO NOTHING", u.UserID, skill)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// DeactivateUser marks the user inactive and, unless reassign is false, hands
+// their open reviews to teammates the way MassDeactivate does. A nil
+// reassign follows the KeepReviewsOnDeactivate setting of the user's team.
+// Reviews without a candidate stay and are reported with a reason.
+func (s *SQLStore) DeactivateUser(userID string, reassign *bool) ([]models.Reassignment, error) {
+	tx, err := s.db.Beginx()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	reassignments, err := s.deactivateInTx(tx, userID, reassign)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return reassignments, nil
+}
+
+func (s *SQLStore) deactivateInTx(tx *sqlx.Tx, userID string, reassign *bool) ([]models.Reassignment, error) {
+	result, err := tx.Exec("UPDATE users SET is_active = false WHERE user_id = $1", userID)
+	if err != nil {
+		return nil, err
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return nil, ErrNotFound
+	}
+
+	if reassign == nil {
+		var keep bool
+		err := tx.Get(&keep, `
+			SELECT COALESCE((
+				SELECT t.keep_reviews_on_deactivate
+				FROM team_members tm
+				JOIN teams t ON t.name = tm.team_name
+				WHERE tm.user_id = $1
+				ORDER BY tm.team_name
+				LIMIT 1), false)`, userID)
+		if err != nil {
+			return nil, err
+		}
+		handOff := !keep
+		reassign = &handOff
+	}
+	if !*reassign {
+		return []models.Reassignment{}, nil
+	}
+	return s.handOverOpenReviews(tx, userID)
+}
+
+// handOverOpenReviews hands every open review of the user to a replacement
+func (s *SQLStore) handOverOpenReviews(tx *sqlx.Tx, userID string) ([]models.Reassignment, error) {
+	var prIDs []string
+	err := tx.Select(&prIDs, `
+		SELECT p.pull_request_id
+		FROM prs p
+		JOIN pr_reviewers r ON r.pull_request_id = p.pull_request_id
+		WHERE p.status = 'OPEN' AND r.user_id = $1
+		ORDER BY p.pull_request_id`, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	reassignments := []models.Reassignment{}
+	for _, prID := range prIDs {
+		r, err := s.handOverReview(tx, prID, userID)
+		if err != nil {
+			return nil, err
+		}
+		reassignments = append(reassignments, r)
+	}
+	return reassignments, nil
+}
+
+func (s *SQLStore) ListTeams() ([]string, error) {
+	teams := []string{}
+	err := s.db.Select(&teams, "SELECT name FROM teams ORDER BY name")
+	return teams, err
+}
+
+// handOverReview moves the user's review of the PR to a replacement. Without
+// a candidate the review stays and the reason is reported.
+func (s *SQLStore) handOverReview(tx *sqlx.Tx, prID, userID string) (models.Reassignment, error) {
+	r := models.Reassignment{PullRequestID: prID, OldReviewerID: userID}
+	newReviewerID, err := s.findReplacementReviewer(tx, userID, prID)
+	switch {
+	case errors.Is(err, ErrNoCandidate), errors.Is(err, ErrNotFound):
+		r.Reason = ErrNoCandidate.Error()
+	case err != nil:
+		return r, err
+	default:
+		if err := s.replaceReviewer(tx, prID, userID, newReviewerID); err != nil {
+			return r, err
+		}
+		r.NewReviewerID = newReviewerID
+	}
+	return r, nil
+}
+
+// SetTeamKeepReviews sets whether deactivated members of the team stay on
+// their open reviews by default
+func (s *SQLStore) SetTeamKeepReviews(teamName string, keep bool) (models.Team, error) {
+	if err := s.teamExists(teamName); err != nil {
+		return models.Team{}, err
+	}
+	_, err := s.db.Exec("UPDATE teams SET keep_reviews_on_deactivate = $1 WHERE name = $2", keep, teamName)
+	if err != nil {
+		return models.Team{}, err
+	}
+	return s.GetTeam(teamName)
+}
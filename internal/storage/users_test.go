@@ -0,0 +1,43 @@
+package storage
+
+import (
+	"testing"
+
+	"pr-reviewer-service/internal/models"
+)
+
+func TestUpdateUserPatch(t *testing.T) {
+	s := newTestStore(t)
+	err := s.CreateTeam("backend", []models.User{
+		{UserID: "u1", Username: "Alice", IsActive: true},
+		{UserID: "u2", Username: "Bob", IsActive: true},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	skills := []string{"go", "sql"}
+	if _, err := s.UpdateUser("u2", models.UserPatch{Skills: &skills}); err != nil {
+		t.Fatal(err)
+	}
+	// Fields left out of the patch keep the stored value
+	name := "Robert"
+	user, err := s.UpdateUser("u2", models.UserPatch{Username: &name})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if user.Username != "Robert" || len(user.Skills) != 2 || user.Timezone != "UTC" {
+		t.Errorf("Expected only the username to change, got %+v", user)
+	}
+	if _, err := s.UpdateUser("missing", models.UserPatch{Username: &name}); err != ErrNotFound {
+		t.Errorf("Expected ErrNotFound, got %v", err)
+	}
+
+	users, total, err := s.ListUsers(models.UserFilter{Query: "rob"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if total != 1 || len(users) != 1 || users[0].UserID != "u2" {
+		t.Errorf("Expected only Robert to match, got %d %+v", total, users)
+	}
+}
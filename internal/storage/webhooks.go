@@ -0,0 +1,60 @@
+package storage
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// deliveryLease is how long a delivery being handled holds off its
+// redeliveries. An attempt still unfinished after that is taken as lost.
+const deliveryLease = 10 * time.Minute
+
+// RecordDelivery claims a webhook delivery and reports whether it is new.
+// Code hosts retry deliveries with the same ID, so a false result means the
+// event was already handled. ErrDeliveryInProgress means an earlier attempt
+// is still being handled and may yet fail, so the redelivery must not be
+// acknowledged.
+func (s *SQLStore) RecordDelivery(provider, deliveryID, event string) (bool, error) {
+	var claimed bool
+	err := s.db.Get(&claimed, `
+		INSERT INTO webhook_deliveries (provider, delivery_id, event) VALUES ($1, $2, $3)
+		ON CONFLICT (provider, delivery_id) DO UPDATE SET received_at = NOW()
+		WHERE webhook_deliveries.completed_at IS NULL AND webhook_deliveries.received_at < $4
+		RETURNING true`,
+		provider, deliveryID, event, time.Now().Add(-deliveryLease))
+	if err == nil {
+		return true, nil
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return false, err
+	}
+
+	var completed bool
+	err = s.db.Get(&completed, `
+		SELECT completed_at IS NOT NULL FROM webhook_deliveries
+		WHERE provider = $1 AND delivery_id = $2`, provider, deliveryID)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return false, err
+	}
+	if !completed {
+		return false, ErrDeliveryInProgress
+	}
+	return false, nil
+}
+
+// FinishDelivery marks a claimed delivery as handled, so that redeliveries
+// are skipped from now on
+func (s *SQLStore) FinishDelivery(provider, deliveryID string) error {
+	_, err := s.db.Exec(
+		"UPDATE webhook_deliveries SET completed_at = NOW() WHERE provider = $1 AND delivery_id = $2",
+		provider, deliveryID)
+	return err
+}
+
+// ForgetDelivery drops a recorded delivery whose handling failed, so that a
+// redelivery is processed again
+func (s *SQLStore) ForgetDelivery(provider, deliveryID string) error {
+	_, err := s.db.Exec("DELETE FROM webhook_deliveries WHERE provider = $1 AND delivery_id = $2", provider, deliveryID)
+	return err
+}
@@ -0,0 +1,38 @@
+package storage
+
+import "testing"
+
+func TestRecordDelivery(t *testing.T) {
+	s := newTestStore(t)
+
+	fresh, err := s.RecordDelivery("github", "d-1", "pull_request")
+	if err != nil || !fresh {
+		t.Fatalf("Expected a new delivery, got %v (%v)", fresh, err)
+	}
+	// Still being handled: the redelivery must not be acknowledged
+	if _, err := s.RecordDelivery("github", "d-1", "pull_request"); err != ErrDeliveryInProgress {
+		t.Errorf("Expected ErrDeliveryInProgress, got %v", err)
+	}
+
+	// An attempt that never finished is taken over after the lease
+	s.db.MustExec("UPDATE webhook_deliveries SET received_at = NOW() - INTERVAL '1 hour'")
+	if fresh, err := s.RecordDelivery("github", "d-1", "pull_request"); err != nil || !fresh {
+		t.Errorf("Expected an abandoned delivery to be claimed again, got %v (%v)", fresh, err)
+	}
+
+	if err := s.FinishDelivery("github", "d-1"); err != nil {
+		t.Fatal(err)
+	}
+	if fresh, err := s.RecordDelivery("github", "d-1", "pull_request"); err != nil || fresh {
+		t.Errorf("Expected a handled delivery to be a duplicate, got %v (%v)", fresh, err)
+	}
+
+	// A failed delivery is forgotten and runs again
+	s.RecordDelivery("gitlab", "d-2", "merge_request")
+	if err := s.ForgetDelivery("gitlab", "d-2"); err != nil {
+		t.Fatal(err)
+	}
+	if fresh, err := s.RecordDelivery("gitlab", "d-2", "merge_request"); err != nil || !fresh {
+		t.Errorf("Expected a forgotten delivery to be new, got %v (%v)", fresh, err)
+	}
+}
@@ -0,0 +1,75 @@
+package vcs
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// GitHub requests reviewers through the pull request review requests API
+type GitHub struct {
+	// BaseURL is https://api.github.com, or https://HOST/api/v3 for GitHub
+	// Enterprise Server
+	BaseURL    string
+	Token      string
+	HTTPClient *http.Client
+}
+
+func NewGitHub(baseURL, token string) *GitHub {
+	if baseURL == "" {
+		baseURL = "https://api.github.com"
+	}
+	return &GitHub{
+		BaseURL:    strings.TrimSuffix(baseURL, "/"),
+		Token:      token,
+		HTTPClient: &http.Client{Timeout: defaultTimeout},
+	}
+}
+
+// SetReviewers requests reviews from the logins not pushed before and
+// withdraws the pending requests of pushed logins no longer assigned. GitHub
+// drops a reviewer from the requested ones once they review, so logins
+// already pushed are never requested again: that would notify them anew.
+func (g *GitHub) SetReviewers(ctx context.Context, repository string, number int, logins, pushed []string) error {
+	url := fmt.Sprintf("%s/repos/%s/pulls/%d/requested_reviewers", g.BaseURL, repository, number)
+	header := http.Header{
+		"Accept":               {"application/vnd.github+json"},
+		"Authorization":        {"Bearer " + g.Token},
+		"X-Github-Api-Version": {"2022-11-28"},
+	}
+
+	var current struct {
+		Users []struct {
+			Login string `json:"login"`
+		} `json:"users"`
+	}
+	if err := doJSON(ctx, g.HTTPClient, "GET", url, header, nil, &current); err != nil {
+		return err
+	}
+
+	withdraw := withdrawn(logins, pushed)
+	var stale []string
+	for _, u := range current.Users {
+		if containsFold(withdraw, u.Login) {
+			stale = append(stale, u.Login)
+		}
+	}
+	if len(stale) > 0 {
+		err := doJSON(ctx, g.HTTPClient, "DELETE", url, header, map[string][]string{"reviewers": stale}, nil)
+		if err != nil {
+			return err
+		}
+	}
+
+	var added []string
+	for _, login := range logins {
+		if !containsFold(pushed, login) {
+			added = append(added, login)
+		}
+	}
+	if len(added) == 0 {
+		return nil
+	}
+	return doJSON(ctx, g.HTTPClient, "POST", url, header, map[string][]string{"reviewers": added}, nil)
+}
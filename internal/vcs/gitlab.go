@@ -0,0 +1,85 @@
+package vcs
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// GitLab sets merge request reviewers through the REST API v4
+type GitLab struct {
+	// BaseURL is the instance root, e.g. https://gitlab.com
+	BaseURL    string
+	Token      string
+	HTTPClient *http.Client
+}
+
+func NewGitLab(baseURL, token string) *GitLab {
+	if baseURL == "" {
+		baseURL = "https://gitlab.com"
+	}
+	return &GitLab{
+		BaseURL:    strings.TrimSuffix(baseURL, "/"),
+		Token:      token,
+		HTTPClient: &http.Client{Timeout: defaultTimeout},
+	}
+}
+
+// SetReviewers sets the reviewers of merge request number of project
+// repository (its path with namespace) to logins plus the current reviewers
+// the previous push did not set. GitLab wants user IDs, so every username is looked up
+// first; numeric logins are taken as user IDs.
+func (g *GitLab) SetReviewers(ctx context.Context, repository string, number int, logins, pushed []string) error {
+	header := http.Header{"Private-Token": {g.Token}}
+	mrURL := fmt.Sprintf("%s/api/v4/projects/%s/merge_requests/%d", g.BaseURL, url.PathEscape(repository), number)
+
+	var mr struct {
+		Reviewers []struct {
+			ID       int    `json:"id"`
+			Username string `json:"username"`
+		} `json:"reviewers"`
+	}
+	if err := doJSON(ctx, g.HTTPClient, "GET", mrURL, header, nil, &mr); err != nil {
+		return err
+	}
+
+	withdraw := withdrawn(logins, pushed)
+	ids := []int{}
+	for _, r := range mr.Reviewers {
+		if !containsFold(withdraw, r.Username) && !containsFold(withdraw, strconv.Itoa(r.ID)) {
+			ids = append(ids, r.ID)
+		}
+	}
+	for _, login := range logins {
+		if id, err := strconv.Atoi(login); err == nil {
+			ids = appendID(ids, id)
+			continue
+		}
+		var users []struct {
+			ID int `json:"id"`
+		}
+		err := doJSON(ctx, g.HTTPClient, "GET", g.BaseURL+"/api/v4/users?username="+url.QueryEscape(login), header, nil, &users)
+		if err != nil {
+			return err
+		}
+		if len(users) == 0 {
+			return &StatusError{StatusCode: http.StatusNotFound, Body: "unknown GitLab user " + login}
+		}
+		ids = appendID(ids, users[0].ID)
+	}
+
+	return doJSON(ctx, g.HTTPClient, "PUT", mrURL, header, map[string][]int{"reviewer_ids": ids}, nil)
+}
+
+// appendID adds id to ids unless it is already there
+func appendID(ids []int, id int) []int {
+	for _, v := range ids {
+		if v == id {
+			return ids
+		}
+	}
+	return append(ids, id)
+}
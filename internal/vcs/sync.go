@@ -0,0 +1,86 @@
+package vcs
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"pr-reviewer-service/internal/models"
+)
+
+// Store is the part of storage.Store the Syncer works with
+type Store interface {
+	PendingReviewerSyncs(limit int) ([]models.ReviewerSyncTask, error)
+	FinishReviewerSync(t models.ReviewerSyncTask) error
+}
+
+// Syncer pushes queued reviewer assignments to the code hosts. Temporary
+// failures are retried with exponential backoff, starting at Backoff, until
+// MaxAttempts pushes were made.
+type Syncer struct {
+	Store Store
+	// Clients by provider, e.g. models.ProviderGitHub
+	Clients     map[string]Client
+	MaxAttempts int
+	Backoff     time.Duration
+	BatchSize   int
+
+	now func() time.Time
+}
+
+func NewSyncer(store Store, clients map[string]Client) *Syncer {
+	return &Syncer{
+		Store:       store,
+		Clients:     clients,
+		MaxAttempts: 5,
+		Backoff:     30 * time.Second,
+		BatchSize:   100,
+		now:         time.Now,
+	}
+}
+
+// Run pushes one batch of due PRs and returns them with their outcome
+func (s *Syncer) Run(ctx context.Context) ([]models.ReviewerSyncTask, error) {
+	tasks, err := s.Store.PendingReviewerSyncs(s.BatchSize)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range tasks {
+		s.push(ctx, &tasks[i])
+		if err := s.Store.FinishReviewerSync(tasks[i]); err != nil {
+			return tasks[:i+1], err
+		}
+	}
+	return tasks, nil
+}
+
+func (s *Syncer) push(ctx context.Context, t *models.ReviewerSyncTask) {
+	t.NextAttempt = s.now()
+
+	client, ok := s.Clients[t.Provider]
+	if !ok {
+		t.Status, t.Error = models.ReviewerSyncFailed, "no "+t.Provider+" client configured"
+		return
+	}
+
+	// Reviewers with an alias are pushed even when others have none. Only
+	// what an earlier push requested is withdrawn; requests made on the code
+	// host itself stay.
+	err := client.SetReviewers(ctx, t.Repository, t.Number, t.Logins, t.Pushed)
+	if err == nil {
+		t.Pushed = t.Logins
+	}
+	switch {
+	case err != nil && Temporary(err) && t.Attempts+1 < s.MaxAttempts:
+		t.Status, t.Error = models.ReviewerSyncPending, err.Error()
+		t.NextAttempt = t.NextAttempt.Add(s.Backoff << t.Attempts)
+	case err != nil:
+		t.Status, t.Error = models.ReviewerSyncFailed, err.Error()
+	case len(t.Missing) > 0:
+		t.Status = models.ReviewerSyncFailed
+		t.Error = "no " + t.Provider + " alias for " + strings.Join(t.Missing, ", ")
+	default:
+		t.Status, t.Error = models.ReviewerSyncSynced, ""
+	}
+}
@@ -0,0 +1,118 @@
+package vcs
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"pr-reviewer-service/internal/models"
+)
+
+// fakeStore hands out its tasks while they are pending and due
+type fakeStore struct {
+	tasks map[string]models.ReviewerSyncTask
+	now   func() time.Time
+}
+
+func (f *fakeStore) PendingReviewerSyncs(limit int) ([]models.ReviewerSyncTask, error) {
+	tasks := []models.ReviewerSyncTask{}
+	for _, t := range f.tasks {
+		if t.Status == models.ReviewerSyncPending && !t.NextAttempt.After(f.now()) {
+			tasks = append(tasks, t)
+		}
+	}
+	return tasks, nil
+}
+
+func (f *fakeStore) FinishReviewerSync(t models.ReviewerSyncTask) error {
+	t.Attempts++
+	f.tasks[t.PullRequestID] = t
+	return nil
+}
+
+func TestSyncerRetries(t *testing.T) {
+	failures := 2
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/repos/acme/down/pulls/1/requested_reviewers":
+			w.WriteHeader(http.StatusBadGateway)
+		case r.URL.Path == "/repos/acme/gone/pulls/1/requested_reviewers":
+			w.WriteHeader(http.StatusNotFound)
+		case r.Method == "POST" && failures > 0:
+			failures--
+			w.WriteHeader(http.StatusServiceUnavailable)
+		default:
+			w.Write([]byte(`{"users": []}`))
+		}
+	}))
+	defer server.Close()
+
+	now := time.Now()
+	clock := func() time.Time { return now }
+	store := &fakeStore{tasks: map[string]models.ReviewerSyncTask{}, now: clock}
+	for _, task := range []models.ReviewerSyncTask{
+		{PullRequestID: "flaky", Repository: "acme/api", Logins: []string{"alice-gh"}},
+		{PullRequestID: "down", Repository: "acme/down", Logins: []string{"alice-gh"}},
+		{PullRequestID: "gone", Repository: "acme/gone", Logins: []string{"alice-gh"}},
+		{PullRequestID: "partial", Repository: "acme/api", Logins: []string{"alice-gh"}, Missing: []string{"u2"}},
+		{PullRequestID: "elsewhere", Repository: "acme/web", Provider: models.ProviderGitLab},
+	} {
+		task.Number, task.Status = 1, models.ReviewerSyncPending
+		if task.Provider == "" {
+			task.Provider = models.ProviderGitHub
+		}
+		store.tasks[task.PullRequestID] = task
+	}
+
+	syncer := NewSyncer(store, map[string]Client{models.ProviderGitHub: NewGitHub(server.URL, "token")})
+	syncer.MaxAttempts, syncer.Backoff = 3, time.Millisecond
+	syncer.now = clock
+
+	// First run: temporary failures are rescheduled with growing backoff
+	if _, err := syncer.Run(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if task := store.tasks["flaky"]; task.Status != models.ReviewerSyncPending || !task.NextAttempt.Equal(now.Add(time.Millisecond)) {
+		t.Errorf("Expected flaky to be retried after 1ms, got %+v", task)
+	}
+	if task := store.tasks["partial"]; task.Status != models.ReviewerSyncPending {
+		t.Errorf("Expected partial to be retried after the 503, got %+v", task)
+	}
+
+	for i := 0; i < 3; i++ {
+		now = now.Add(time.Second)
+		if _, err := syncer.Run(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	want := map[string]string{
+		"flaky":     models.ReviewerSyncSynced,
+		"down":      models.ReviewerSyncFailed,
+		"gone":      models.ReviewerSyncFailed,
+		"partial":   models.ReviewerSyncFailed,
+		"elsewhere": models.ReviewerSyncFailed,
+	}
+	for id, status := range want {
+		if got := store.tasks[id]; got.Status != status {
+			t.Errorf("Expected %s to be %s, got %+v", id, status, got)
+		}
+	}
+	if got := store.tasks["down"].Attempts; got != 3 {
+		t.Errorf("Expected down to give up after 3 attempts, got %d", got)
+	}
+	if got := store.tasks["gone"].Attempts; got != 1 {
+		t.Errorf("Expected gone to fail without retries, got %d attempts", got)
+	}
+	if got := store.tasks["partial"].Error; got != "no github alias for u2" {
+		t.Errorf("Expected the missing alias to be reported, got %q", got)
+	}
+	if got := store.tasks["flaky"].Pushed; len(got) != 1 || got[0] != "alice-gh" {
+		t.Errorf("Expected flaky to remember alice-gh as pushed, got %v", got)
+	}
+	if got := store.tasks["down"].Pushed; len(got) != 0 {
+		t.Errorf("Expected nothing pushed for down, got %v", got)
+	}
+}
@@ -0,0 +1,106 @@
+// Package vcs pushes reviewer assignments to code hosts
+package vcs
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// defaultTimeout bounds a single request to a code host
+const defaultTimeout = 10 * time.Second
+
+// Client sets the reviewers of a PR on a code host. logins are the
+// reviewers' identities there and pushed the logins the previous push set.
+// Only pushed logins missing from logins are withdrawn, so requests made by
+// anyone else stay.
+type Client interface {
+	SetReviewers(ctx context.Context, repository string, number int, logins, pushed []string) error
+}
+
+// StatusError is an unexpected HTTP response from a code host
+type StatusError struct {
+	StatusCode int
+	Body       string
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("code host responded %d: %s", e.StatusCode, e.Body)
+}
+
+// Temporary reports whether retrying may succeed: rate limits, server errors
+// and network failures are temporary, other responses are not
+func Temporary(err error) bool {
+	var se *StatusError
+	if errors.As(err, &se) {
+		return se.StatusCode == http.StatusTooManyRequests || se.StatusCode >= 500
+	}
+	return err != nil
+}
+
+// doJSON sends in as the JSON body and decodes a successful response into
+// out. Either may be nil.
+func doJSON(ctx context.Context, client *http.Client, method, url string, header http.Header, in, out interface{}) error {
+	var body io.Reader
+	if in != nil {
+		data, err := json.Marshal(in)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, url, body)
+	if err != nil {
+		return err
+	}
+	for name, values := range header {
+		req.Header[name] = values
+	}
+	if in != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		data, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return &StatusError{StatusCode: resp.StatusCode, Body: string(bytes.TrimSpace(data))}
+	}
+	if out == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+// withdrawn returns the pushed logins that are no longer in logins
+func withdrawn(logins, pushed []string) []string {
+	var withdraw []string
+	for _, login := range pushed {
+		if !containsFold(logins, login) {
+			withdraw = append(withdraw, login)
+		}
+	}
+	return withdraw
+}
+
+// containsFold reports whether values holds value, ignoring case like
+// GitHub and GitLab usernames do
+func containsFold(values []string, value string) bool {
+	for _, v := range values {
+		if strings.EqualFold(v, value) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,145 @@
+package vcs
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestGitHubSetReviewers(t *testing.T) {
+	var calls []string
+	var added, removed []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls = append(calls, r.Method+" "+r.URL.Path)
+		if r.Header.Get("Authorization") != "Bearer token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		var body struct {
+			Reviewers []string `json:"reviewers"`
+		}
+		json.NewDecoder(r.Body).Decode(&body)
+		switch r.Method {
+		case "GET":
+			w.Write([]byte(`{"users": [{"login": "Bob-GH"}, {"login": "carol-gh"}, {"login": "dave-gh"}], "teams": []}`))
+		case "DELETE":
+			removed = body.Reviewers
+			w.Write([]byte(`{}`))
+		case "POST":
+			added = body.Reviewers
+			w.WriteHeader(http.StatusCreated)
+			w.Write([]byte(`{}`))
+		}
+	}))
+	defer server.Close()
+
+	// dave-gh was pushed before; carol-gh was requested on GitHub itself
+	client := NewGitHub(server.URL, "token")
+	if err := client.SetReviewers(context.Background(), "acme/api", 42, []string{"alice-gh", "bob-gh"}, []string{"dave-gh"}); err != nil {
+		t.Fatal(err)
+	}
+
+	path := "/repos/acme/api/pulls/42/requested_reviewers"
+	if want := []string{"GET " + path, "DELETE " + path, "POST " + path}; !reflect.DeepEqual(calls, want) {
+		t.Errorf("Expected calls %v, got %v", want, calls)
+	}
+	if !reflect.DeepEqual(removed, []string{"dave-gh"}) || !reflect.DeepEqual(added, []string{"alice-gh", "bob-gh"}) {
+		t.Errorf("Expected dave-gh removed and alice-gh, bob-gh requested, got %v and %v", removed, added)
+	}
+
+	client.Token = "wrong"
+	err := client.SetReviewers(context.Background(), "acme/api", 42, []string{"alice-gh"}, nil)
+	if err == nil || Temporary(err) {
+		t.Errorf("Expected a permanent error for a bad token, got %v", err)
+	}
+}
+
+func TestGitHubSetReviewersSkipsPushed(t *testing.T) {
+	var added, removed []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			Reviewers []string `json:"reviewers"`
+		}
+		json.NewDecoder(r.Body).Decode(&body)
+		switch r.Method {
+		case "GET":
+			// alice-gh already reviewed, so GitHub no longer lists her
+			w.Write([]byte(`{"users": [{"login": "dave-gh"}], "teams": []}`))
+		case "DELETE":
+			removed = body.Reviewers
+			w.Write([]byte(`{}`))
+		case "POST":
+			added = body.Reviewers
+			w.WriteHeader(http.StatusCreated)
+			w.Write([]byte(`{}`))
+		}
+	}))
+	defer server.Close()
+
+	// bob-gh replaced dave-gh; alice-gh must not be asked to review again
+	client := NewGitHub(server.URL, "token")
+	if err := client.SetReviewers(context.Background(), "acme/api", 42, []string{"alice-gh", "bob-gh"}, []string{"alice-gh", "dave-gh"}); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(removed, []string{"dave-gh"}) || !reflect.DeepEqual(added, []string{"bob-gh"}) {
+		t.Errorf("Expected dave-gh removed and only bob-gh requested, got %v and %v", removed, added)
+	}
+
+	added = nil
+	if err := client.SetReviewers(context.Background(), "acme/api", 42, []string{"alice-gh", "bob-gh"}, []string{"alice-gh", "bob-gh"}); err != nil {
+		t.Fatal(err)
+	}
+	if added != nil {
+		t.Errorf("Expected nothing requested when every login was pushed, got %v", added)
+	}
+}
+
+func TestGitLabSetReviewers(t *testing.T) {
+	var reviewerIDs []int
+	var mrPath string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Private-Token") != "token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		switch {
+		case r.Method == "GET" && r.URL.Path == "/api/v4/users":
+			ids := map[string]int{"alice.gl": 31, "bob.gl": 32}
+			if id, ok := ids[r.URL.Query().Get("username")]; ok {
+				json.NewEncoder(w).Encode([]map[string]int{{"id": id}})
+			} else {
+				w.Write([]byte(`[]`))
+			}
+		case r.Method == "GET" && r.URL.EscapedPath() == "/api/v4/projects/acme%2Fweb/merge_requests/17":
+			w.Write([]byte(`{"reviewers": [{"id": 32, "username": "bob.gl"}, {"id": 34, "username": "carol.gl"}, {"id": 35, "username": "dave.gl"}]}`))
+		case r.Method == "PUT":
+			mrPath = r.URL.EscapedPath()
+			var body struct {
+				ReviewerIDs []int `json:"reviewer_ids"`
+			}
+			json.NewDecoder(r.Body).Decode(&body)
+			reviewerIDs = body.ReviewerIDs
+			w.Write([]byte(`{}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	// dave.gl was pushed before; carol.gl was added on GitLab itself
+	client := NewGitLab(server.URL, "token")
+	if err := client.SetReviewers(context.Background(), "acme/web", 17, []string{"alice.gl", "bob.gl", "33"}, []string{"dave.gl"}); err != nil {
+		t.Fatal(err)
+	}
+	if mrPath != "/api/v4/projects/acme%2Fweb/merge_requests/17" || !reflect.DeepEqual(reviewerIDs, []int{32, 34, 31, 33}) {
+		t.Errorf("Expected reviewers 32, 34, 31, 33 on acme%%2Fweb!17, got %v on %s", reviewerIDs, mrPath)
+	}
+
+	err := client.SetReviewers(context.Background(), "acme/web", 17, []string{"ghost"}, nil)
+	if err == nil || Temporary(err) {
+		t.Errorf("Expected a permanent error for an unknown user, got %v", err)
+	}
+}
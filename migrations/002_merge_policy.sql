@@ -0,0 +1,33 @@
+CREATE TYPE review_state AS ENUM ('PENDING','APPROVED','CHANGES_REQUESTED');
+
+ALTER TABLE pr_reviewers
+    ADD COLUMN state review_state NOT NULL DEFAULT 'PENDING',
+    ADD COLUMN reviewed_at TIMESTAMP WITH TIME ZONE;
+
+CREATE TABLE merge_policies (
+    id SERIAL PRIMARY KEY,
+    team_name TEXT UNIQUE REFERENCES teams(name) ON DELETE CASCADE,
+    min_approvals INT NOT NULL DEFAULT 0,
+    block_on_changes_requested BOOLEAN NOT NULL DEFAULT false,
+    min_age_minutes INT NOT NULL DEFAULT 0,
+    required_reviewer_team TEXT REFERENCES teams(name) ON DELETE SET NULL
+);
+
+-- team_name NULL means the freeze applies to every team.
+CREATE TABLE merge_freezes (
+    id SERIAL PRIMARY KEY,
+    team_name TEXT REFERENCES teams(name) ON DELETE CASCADE,
+    starts_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    ends_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    reason TEXT NOT NULL DEFAULT '',
+    CHECK (ends_at > starts_at)
+);
+
+CREATE TABLE audit_log (
+    id BIGSERIAL PRIMARY KEY,
+    action TEXT NOT NULL,
+    actor TEXT NOT NULL,
+    target TEXT NOT NULL,
+    details JSONB,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
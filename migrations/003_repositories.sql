@@ -0,0 +1,21 @@
+CREATE TABLE repositories (
+    name TEXT PRIMARY KEY,
+    owning_team TEXT REFERENCES teams(name) ON DELETE SET NULL,
+    reviewer_count INT NOT NULL DEFAULT 2 CHECK (reviewer_count >= 0),
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
+
+-- Repository PRs get pull_request_id = '<repository>#<number>'; the ID the
+-- client used before repositories existed is kept in alias.
+ALTER TABLE prs
+    ADD COLUMN repository TEXT REFERENCES repositories(name),
+    ADD COLUMN number INT,
+    ADD COLUMN alias TEXT,
+    ADD CONSTRAINT prs_repository_number_key UNIQUE (repository, number),
+    ADD CONSTRAINT prs_repository_number_check CHECK ((repository IS NULL) = (number IS NULL));
+
+CREATE INDEX prs_alias_idx ON prs (alias);
+
+ALTER TABLE merge_policies
+    ADD COLUMN repository TEXT UNIQUE REFERENCES repositories(name) ON DELETE CASCADE,
+    ADD CONSTRAINT merge_policies_scope_check CHECK ((team_name IS NULL) <> (repository IS NULL));
@@ -0,0 +1,15 @@
+CREATE TYPE pr_priority AS ENUM ('LOW','MEDIUM','HIGH','CRITICAL');
+
+ALTER TABLE prs
+    ADD COLUMN lines_added INT NOT NULL DEFAULT 0 CHECK (lines_added >= 0),
+    ADD COLUMN lines_removed INT NOT NULL DEFAULT 0 CHECK (lines_removed >= 0),
+    ADD COLUMN files_changed INT NOT NULL DEFAULT 0 CHECK (files_changed >= 0),
+    ADD COLUMN priority pr_priority NOT NULL DEFAULT 'MEDIUM';
+
+-- A PR with more than min_lines changed lines gets reviewer_count reviewers.
+CREATE TABLE team_size_rules (
+    team_name TEXT NOT NULL REFERENCES teams(name) ON DELETE CASCADE,
+    min_lines INT NOT NULL CHECK (min_lines >= 0),
+    reviewer_count INT NOT NULL CHECK (reviewer_count >= 0),
+    PRIMARY KEY (team_name, min_lines)
+);
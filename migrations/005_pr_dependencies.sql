@@ -0,0 +1,6 @@
+-- A stacked PR depends on its parent and can only be merged after it.
+ALTER TABLE prs
+    ADD COLUMN depends_on TEXT REFERENCES prs(pull_request_id) ON DELETE SET NULL,
+    ADD CONSTRAINT prs_depends_on_self_check CHECK (depends_on <> pull_request_id);
+
+CREATE INDEX prs_depends_on_idx ON prs (depends_on);
@@ -0,0 +1,14 @@
+ALTER TABLE pr_reviewers
+    ADD COLUMN assigned_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    ADD COLUMN accepted_at TIMESTAMP WITH TIME ZONE;
+
+-- Reviewers who declined a PR (or let the acknowledgement time out) are
+-- never picked for it again.
+CREATE TABLE pr_declines (
+    pull_request_id TEXT NOT NULL REFERENCES prs(pull_request_id) ON DELETE CASCADE,
+    user_id TEXT NOT NULL REFERENCES users(user_id) ON DELETE CASCADE,
+    reason TEXT NOT NULL DEFAULT '',
+    automatic BOOLEAN NOT NULL DEFAULT false,
+    declined_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (pull_request_id, user_id)
+);
@@ -0,0 +1,25 @@
+ALTER TABLE teams ADD COLUMN review_sla_hours INT CHECK (review_sla_hours > 0);
+
+ALTER TABLE users ADD COLUMN timezone TEXT NOT NULL DEFAULT 'UTC';
+
+CREATE TABLE team_holidays (
+    team_name TEXT NOT NULL REFERENCES teams(name) ON DELETE CASCADE,
+    day DATE NOT NULL,
+    PRIMARY KEY (team_name, day)
+);
+
+-- due_at is computed on the business-hours calendar when a reviewer is
+-- assigned; first_reviewed_at is what the SLA is measured against.
+ALTER TABLE pr_reviewers
+    ADD COLUMN due_at TIMESTAMP WITH TIME ZONE,
+    ADD COLUMN first_reviewed_at TIMESTAMP WITH TIME ZONE;
+
+CREATE TABLE sla_breaches (
+    pull_request_id TEXT NOT NULL REFERENCES prs(pull_request_id) ON DELETE CASCADE,
+    user_id TEXT NOT NULL REFERENCES users(user_id) ON DELETE CASCADE,
+    team_name TEXT NOT NULL,
+    due_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    detected_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    reviewed_at TIMESTAMP WITH TIME ZONE,
+    PRIMARY KEY (pull_request_id, user_id, due_at)
+);
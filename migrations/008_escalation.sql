@@ -0,0 +1,21 @@
+CREATE TYPE member_role AS ENUM ('member', 'lead');
+
+ALTER TABLE team_members ADD COLUMN role member_role NOT NULL DEFAULT 'member';
+
+-- Assignments left unanswered for escalation_minutes get the team lead (or
+-- escalation_user_id when set) added as an extra reviewer.
+ALTER TABLE teams
+    ADD COLUMN escalation_minutes INT CHECK (escalation_minutes > 0),
+    ADD COLUMN escalation_user_id TEXT REFERENCES users(user_id) ON DELETE SET NULL;
+
+-- One escalation per stalled assignment; escalated_to is NULL when nobody
+-- eligible was found.
+CREATE TABLE pr_escalations (
+    id BIGSERIAL PRIMARY KEY,
+    pull_request_id TEXT NOT NULL REFERENCES prs(pull_request_id) ON DELETE CASCADE,
+    team_name TEXT NOT NULL,
+    stalled_reviewer_id TEXT NOT NULL REFERENCES users(user_id) ON DELETE CASCADE,
+    escalated_to TEXT REFERENCES users(user_id) ON DELETE SET NULL,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    UNIQUE (pull_request_id, stalled_reviewer_id)
+);
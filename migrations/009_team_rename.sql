@@ -0,0 +1,33 @@
+-- Team renames propagate to every table referencing the team.
+ALTER TABLE team_members
+    DROP CONSTRAINT team_members_team_name_fkey,
+    ADD CONSTRAINT team_members_team_name_fkey FOREIGN KEY (team_name)
+        REFERENCES teams(name) ON DELETE CASCADE ON UPDATE CASCADE;
+
+ALTER TABLE merge_policies
+    DROP CONSTRAINT merge_policies_team_name_fkey,
+    ADD CONSTRAINT merge_policies_team_name_fkey FOREIGN KEY (team_name)
+        REFERENCES teams(name) ON DELETE CASCADE ON UPDATE CASCADE,
+    DROP CONSTRAINT merge_policies_required_reviewer_team_fkey,
+    ADD CONSTRAINT merge_policies_required_reviewer_team_fkey FOREIGN KEY (required_reviewer_team)
+        REFERENCES teams(name) ON DELETE SET NULL ON UPDATE CASCADE;
+
+ALTER TABLE merge_freezes
+    DROP CONSTRAINT merge_freezes_team_name_fkey,
+    ADD CONSTRAINT merge_freezes_team_name_fkey FOREIGN KEY (team_name)
+        REFERENCES teams(name) ON DELETE CASCADE ON UPDATE CASCADE;
+
+ALTER TABLE repositories
+    DROP CONSTRAINT repositories_owning_team_fkey,
+    ADD CONSTRAINT repositories_owning_team_fkey FOREIGN KEY (owning_team)
+        REFERENCES teams(name) ON DELETE SET NULL ON UPDATE CASCADE;
+
+ALTER TABLE team_size_rules
+    DROP CONSTRAINT team_size_rules_team_name_fkey,
+    ADD CONSTRAINT team_size_rules_team_name_fkey FOREIGN KEY (team_name)
+        REFERENCES teams(name) ON DELETE CASCADE ON UPDATE CASCADE;
+
+ALTER TABLE team_holidays
+    DROP CONSTRAINT team_holidays_team_name_fkey,
+    ADD CONSTRAINT team_holidays_team_name_fkey FOREIGN KEY (team_name)
+        REFERENCES teams(name) ON DELETE CASCADE ON UPDATE CASCADE;
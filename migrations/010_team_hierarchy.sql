@@ -0,0 +1,7 @@
+-- Teams form a tree; reviewer search falls back to sibling and parent teams
+-- and statistics roll up from sub-teams.
+ALTER TABLE teams
+    ADD COLUMN parent_team TEXT REFERENCES teams(name) ON DELETE SET NULL ON UPDATE CASCADE,
+    ADD CONSTRAINT teams_parent_not_self CHECK (parent_team <> name);
+
+CREATE INDEX idx_teams_parent_team ON teams(parent_team);
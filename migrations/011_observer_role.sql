@@ -0,0 +1,2 @@
+-- Observers follow a team without being picked as reviewers automatically.
+ALTER TYPE member_role ADD VALUE 'observer';
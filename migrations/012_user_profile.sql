@@ -0,0 +1,9 @@
+-- Profile fields edited through /users/update. A user is out of office
+-- while ooo_until is in the future.
+ALTER TABLE users ADD COLUMN ooo_until TIMESTAMP WITH TIME ZONE;
+
+CREATE TABLE user_skills (
+    user_id TEXT NOT NULL REFERENCES users(user_id) ON DELETE CASCADE,
+    skill TEXT NOT NULL,
+    PRIMARY KEY (user_id, skill)
+);
@@ -0,0 +1,3 @@
+-- Deactivating a user hands their open reviews to teammates unless their
+-- team keeps them assigned.
+ALTER TABLE teams ADD COLUMN keep_reviews_on_deactivate BOOLEAN NOT NULL DEFAULT false;
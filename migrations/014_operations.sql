@@ -0,0 +1,17 @@
+-- Reversible operations. Each change is either a deactivated user
+-- (pull_request_id NULL) or a reviewer swap on a PR.
+CREATE TABLE operations (
+    id BIGSERIAL PRIMARY KEY,
+    kind TEXT NOT NULL,
+    target TEXT NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    reverted_at TIMESTAMP WITH TIME ZONE
+);
+
+CREATE TABLE operation_changes (
+    id BIGSERIAL PRIMARY KEY,
+    operation_id BIGINT NOT NULL REFERENCES operations(id) ON DELETE CASCADE,
+    user_id TEXT NOT NULL REFERENCES users(user_id) ON DELETE CASCADE,
+    pull_request_id TEXT REFERENCES prs(pull_request_id) ON DELETE CASCADE,
+    new_reviewer_id TEXT REFERENCES users(user_id) ON DELETE CASCADE
+);
@@ -0,0 +1,22 @@
+CREATE TYPE scheduled_change_kind AS ENUM ('deactivate', 'activate', 'move_team');
+CREATE TYPE scheduled_change_status AS ENUM ('PENDING', 'APPLIED', 'FAILED', 'CANCELLED');
+
+-- User state changes applied by a worker once run_at has passed. team_name
+-- is the destination of a move; from_team limits which team the user leaves
+-- (every other team when NULL).
+CREATE TABLE scheduled_changes (
+    id BIGSERIAL PRIMARY KEY,
+    kind scheduled_change_kind NOT NULL,
+    user_id TEXT NOT NULL REFERENCES users(user_id) ON DELETE CASCADE,
+    team_name TEXT REFERENCES teams(name) ON UPDATE CASCADE ON DELETE CASCADE,
+    from_team TEXT REFERENCES teams(name) ON UPDATE CASCADE ON DELETE CASCADE,
+    reassign_reviews BOOLEAN,
+    run_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    status scheduled_change_status NOT NULL DEFAULT 'PENDING',
+    error TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    applied_at TIMESTAMP WITH TIME ZONE,
+    CHECK ((kind = 'move_team') = (team_name IS NOT NULL))
+);
+
+CREATE INDEX scheduled_changes_due ON scheduled_changes (run_at) WHERE status = 'PENDING';
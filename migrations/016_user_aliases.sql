@@ -0,0 +1,12 @@
+-- External identities such as GitHub logins, GitLab usernames and commit
+-- emails. external_id is stored lower-cased since all three are matched
+-- case-insensitively.
+CREATE TABLE user_aliases (
+    provider TEXT NOT NULL,
+    external_id TEXT NOT NULL,
+    user_id TEXT NOT NULL REFERENCES users(user_id) ON DELETE CASCADE,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (provider, external_id)
+);
+
+CREATE INDEX user_aliases_user_id ON user_aliases (user_id);
@@ -0,0 +1,5 @@
+-- Bots such as Dependabot and Renovate author PRs but never review them.
+-- Their PRs are reviewed by bot_team since bots belong to no team.
+ALTER TABLE users
+    ADD COLUMN is_bot BOOLEAN NOT NULL DEFAULT false,
+    ADD COLUMN bot_team TEXT REFERENCES teams(name) ON DELETE SET NULL ON UPDATE CASCADE;
@@ -0,0 +1,14 @@
+-- PRs closed without merging on the code host
+ALTER TYPE pr_status ADD VALUE IF NOT EXISTS 'CLOSED';
+ALTER TABLE prs ADD COLUMN closed_at TIMESTAMP WITH TIME ZONE;
+
+-- Webhook deliveries being or already handled, so that redeliveries are
+-- skipped. completed_at stays NULL while a delivery is being handled.
+CREATE TABLE webhook_deliveries (
+    provider TEXT NOT NULL,
+    delivery_id TEXT NOT NULL,
+    event TEXT NOT NULL,
+    received_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    completed_at TIMESTAMP WITH TIME ZONE,
+    PRIMARY KEY (provider, delivery_id)
+);
@@ -0,0 +1,16 @@
+-- Reviewers of PRs in repositories with a provider are pushed to the code
+-- host. reviewer_sync_queued_at identifies the assignment being pushed, so
+-- a sync finishing after a newer reassignment does not mark it synced.
+-- reviewer_sync_pushed holds the logins the last push requested; a later
+-- push withdraws only these, never requests made on the code host itself.
+ALTER TABLE repositories ADD COLUMN provider TEXT CHECK (provider IN ('github', 'gitlab'));
+
+ALTER TABLE prs
+    ADD COLUMN reviewer_sync TEXT CHECK (reviewer_sync IN ('PENDING', 'SYNCED', 'FAILED')),
+    ADD COLUMN reviewer_sync_error TEXT,
+    ADD COLUMN reviewer_sync_attempts INTEGER NOT NULL DEFAULT 0,
+    ADD COLUMN reviewer_sync_queued_at TIMESTAMP WITH TIME ZONE,
+    ADD COLUMN reviewer_sync_next_at TIMESTAMP WITH TIME ZONE,
+    ADD COLUMN reviewer_sync_pushed TEXT[] NOT NULL DEFAULT '{}';
+
+CREATE INDEX prs_reviewer_sync_pending ON prs (reviewer_sync_next_at) WHERE reviewer_sync = 'PENDING';
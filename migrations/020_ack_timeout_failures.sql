@@ -0,0 +1,3 @@
+-- Stale assignments the timeout worker found no replacement for. They are
+-- retried once another ack timeout has passed since the failed attempt.
+ALTER TABLE pr_reviewers ADD COLUMN ack_timeout_failed_at TIMESTAMP WITH TIME ZONE;
@@ -0,0 +1,27 @@
+#!/bin/sh
+# Applies the migrations from the given directory (default /migrations) that
+# are not recorded in schema_migrations yet, each in its own transaction.
+# Connection settings come from the PG* environment variables.
+set -e
+
+dir=${1:-/migrations}
+
+psql -v ON_ERROR_STOP=1 -q <<'SQL'
+CREATE TABLE IF NOT EXISTS schema_migrations (
+    version TEXT PRIMARY KEY,
+    applied_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+-- Databases created before migrations were recorded only ran 001_init.sql
+INSERT INTO schema_migrations (version)
+SELECT '001_init.sql' WHERE to_regclass('public.users') IS NOT NULL
+ON CONFLICT DO NOTHING;
+SQL
+
+for f in "$dir"/*.sql; do
+    version=$(basename "$f")
+    if [ -n "$(psql -tAq -c "SELECT 1 FROM schema_migrations WHERE version = '$version'")" ]; then
+        continue
+    fi
+    echo "Applying $version"
+    psql -v ON_ERROR_STOP=1 -q -1 -f "$f" -c "INSERT INTO schema_migrations (version) VALUES ('$version')"
+done